@@ -298,6 +298,24 @@ func (c *Client) List(ctx context.Context) (*ListResponse, error) {
 	return &lr, nil
 }
 
+// DiskUsage reports the disk space used by models and the blobs they share.
+func (c *Client) DiskUsage(ctx context.Context) (*DiskUsageResponse, error) {
+	var resp DiskUsageResponse
+	if err := c.do(ctx, http.MethodGet, "/api/du", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Prune removes blobs that aren't used by any model and partial downloads.
+func (c *Client) Prune(ctx context.Context, req *PruneRequest) (*PruneResponse, error) {
+	var resp PruneResponse
+	if err := c.do(ctx, http.MethodPost, "/api/prune", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 // ListRunning lists running models.
 func (c *Client) ListRunning(ctx context.Context) (*ProcessResponse, error) {
 	var lr ProcessResponse
@@ -333,6 +351,16 @@ func (c *Client) Show(ctx context.Context, req *ShowRequest) (*ShowResponse, err
 	return &resp, nil
 }
 
+// Diff compares two models and returns a unified diff of their manifests,
+// Modelfiles, metadata and tensors.
+func (c *Client) Diff(ctx context.Context, req *DiffRequest) (*DiffResponse, error) {
+	var resp DiffResponse
+	if err := c.do(ctx, http.MethodPost, "/api/diff", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 // Heartbeat checks if the server has started and is responsive; if yes, it
 // returns nil, otherwise an error.
 func (c *Client) Heartbeat(ctx context.Context) error {
@@ -351,6 +379,54 @@ func (c *Client) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse,
 	return &resp, nil
 }
 
+// Tokenize returns the tokens the model's tokenizer splits the content, or
+// the messages rendered with the model's chat template, into. The model
+// doesn't need to be running.
+func (c *Client) Tokenize(ctx context.Context, req *TokenizeRequest) (*TokenizeResponse, error) {
+	var resp TokenizeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/tokenize", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Detokenize returns the text the tokens stand for in the model's tokenizer.
+// The model doesn't need to be running.
+func (c *Client) Detokenize(ctx context.Context, req *DetokenizeRequest) (*DetokenizeResponse, error) {
+	var resp DetokenizeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/detokenize", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// LogsFunc is a function that [Client.Logs] invokes for each record returned.
+type LogsFunc func(LogRecord) error
+
+// Logs returns the records most recently logged by the server. When
+// req.Follow is set it keeps returning records as they're logged until ctx
+// is canceled or fn returns an error.
+func (c *Client) Logs(ctx context.Context, req *LogsRequest, fn LogsFunc) error {
+	return c.stream(ctx, http.MethodPost, "/api/logs", req, func(bts []byte) error {
+		var record LogRecord
+		if err := json.Unmarshal(bts, &record); err != nil {
+			return err
+		}
+
+		return fn(record)
+	})
+}
+
+// Status returns the state of the server: its version, GPUs, queued requests
+// and configuration.
+func (c *Client) Status(ctx context.Context) (*StatusResponse, error) {
+	var resp StatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/status", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 // Embeddings generates an embedding from a model.
 func (c *Client) Embeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
 	var resp EmbeddingResponse
@@ -80,6 +80,25 @@ type GenerateRequest struct {
 	// Options lists model-specific options. For example, temperature can be
 	// set through this field, if the model supports it.
 	Options map[string]interface{} `json:"options"`
+
+	// Adapters selects the LoRA adapters to apply to this request. If not
+	// set, the adapters of the requested model are used.
+	Adapters []Adapter `json:"adapters,omitempty"`
+
+	// DryRun renders the prompt and returns it in the response's PromptInfo
+	// without loading the model or generating a response.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Progress streams Status responses while the request waits in the
+	// queue, the model loads and the prompt is processed, before the
+	// response's content. It's ignored if Stream is false.
+	Progress bool `json:"progress,omitempty"`
+
+	// Timeout stops the response with the done reason "timeout" once the
+	// model has spent this long on it, returning what was generated so far.
+	// If it's unset or zero the server's default is used, and a negative
+	// value means only the server's maximum applies.
+	Timeout *Duration `json:"timeout,omitempty"`
 }
 
 // ChatRequest describes a request sent by [Client.Chat].
@@ -105,6 +124,29 @@ type ChatRequest struct {
 
 	// Options lists model-specific options.
 	Options map[string]interface{} `json:"options"`
+
+	// Adapters selects the LoRA adapters to apply, as in [GenerateRequest].
+	Adapters []Adapter `json:"adapters,omitempty"`
+
+	// DryRun renders the prompt, as in [GenerateRequest].
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Progress streams Status responses, as in [GenerateRequest].
+	Progress bool `json:"progress,omitempty"`
+
+	// Timeout limits how long the response takes, as in [GenerateRequest].
+	Timeout *Duration `json:"timeout,omitempty"`
+}
+
+// Adapter selects a LoRA adapter for a single request. Adapters are switched
+// per request, so a loaded model can serve many adapters without reloading.
+type Adapter struct {
+	// Model is the name of a local model created with an ADAPTER on top of
+	// the requested model. Its adapter layers are applied to the request.
+	Model string `json:"model"`
+
+	// Scale is the strength the adapter is applied with; 1.0 if not set.
+	Scale float32 `json:"scale,omitempty"`
 }
 
 type Tools []Tool
@@ -192,9 +234,74 @@ type ChatResponse struct {
 
 	Done bool `json:"done"`
 
+	// TruncatedMessages are the indexes of the request's messages which were
+	// left out of the prompt because they didn't fit in the context window.
+	TruncatedMessages []int `json:"truncated_messages,omitempty"`
+
+	// TruncatedTokens is the number of tokens the model left out of the
+	// prompt because it still didn't fit in the context window, set in the
+	// final response.
+	TruncatedTokens int `json:"truncated_tokens,omitempty"`
+
+	// PromptInfo describes the rendered prompt. It's only set for dry runs.
+	PromptInfo *PromptInfo `json:"prompt_info,omitempty"`
+
+	// Status is the progress of the request before its content starts. It's
+	// only set when Progress is requested, in responses without content.
+	Status *Status `json:"status,omitempty"`
+
+	// Seed is the seed used to sample the response, as in [GenerateResponse].
+	Seed *int `json:"seed,omitempty"`
+
 	Metrics
 }
 
+// Status is the progress of a chat or generate request before the model
+// starts responding.
+type Status struct {
+	// Status is one of the Status constants.
+	Status string `json:"status"`
+
+	// Position is the request's place in the queue while it's queued,
+	// starting at 1.
+	Position int `json:"position,omitempty"`
+
+	// Completed and Total are the percent of the model loaded while it's
+	// loading, and the number of prompt tokens processed while processing.
+	Completed int `json:"completed,omitempty"`
+	Total     int `json:"total,omitempty"`
+}
+
+// States for [Status.Status]
+const (
+	// StatusQueued is waiting for other requests to be scheduled or for a
+	// free slot on a loaded model
+	StatusQueued = "queued"
+
+	// StatusLoading is waiting for the model to load
+	StatusLoading = "loading"
+
+	// StatusProcessing is processing the prompt
+	StatusProcessing = "processing"
+)
+
+// PromptInfo describes the prompt rendered for a dry run of a chat or
+// generate request.
+type PromptInfo struct {
+	// Prompt is the prompt rendered with the model's template.
+	Prompt string `json:"prompt"`
+
+	// Tokens is the number of tokens the prompt takes up in the context
+	// window, including ImageTokens.
+	Tokens int `json:"tokens"`
+
+	// ImageTokens is the number of tokens taken up by images.
+	ImageTokens int `json:"image_tokens,omitempty"`
+
+	// ContextLength is the size of the context window the prompt has to fit in.
+	ContextLength int `json:"context_length"`
+}
+
 type Metrics struct {
 	TotalDuration      time.Duration `json:"total_duration,omitempty"`
 	LoadDuration       time.Duration `json:"load_duration,omitempty"`
@@ -202,6 +309,12 @@ type Metrics struct {
 	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
 	EvalCount          int           `json:"eval_count,omitempty"`
 	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
+
+	// PromptCacheHitCount is the number of prompt tokens loaded from the
+	// cache instead of being evaluated, and PromptCacheSavedDuration an
+	// estimate of the time it would have taken to evaluate them.
+	PromptCacheHitCount      int           `json:"prompt_cache_hit_count,omitempty"`
+	PromptCacheSavedDuration time.Duration `json:"prompt_cache_saved_duration,omitempty"`
 }
 
 // Options specified in [GenerateRequest].  If you add a new option here, also
@@ -226,8 +339,71 @@ type Options struct {
 	MirostatTau      float32  `json:"mirostat_tau,omitempty"`
 	MirostatEta      float32  `json:"mirostat_eta,omitempty"`
 	Stop             []string `json:"stop,omitempty"`
+
+	// DRY penalizes tokens that would extend a sequence repeated from
+	// earlier in the context. It's disabled when DryMultiplier is 0.
+	DryMultiplier       float32  `json:"dry_multiplier,omitempty"`
+	DryBase             float32  `json:"dry_base,omitempty"`
+	DryAllowedLength    int      `json:"dry_allowed_length,omitempty"`
+	DryPenaltyLastN     int      `json:"dry_penalty_last_n,omitempty"`
+	DrySequenceBreakers []string `json:"dry_sequence_breakers,omitempty"`
+
+	// XTC removes the most likely tokens above XTCThreshold with a chance of
+	// XTCProbability. It's disabled when XTCProbability is 0.
+	XTCProbability float32 `json:"xtc_probability,omitempty"`
+	XTCThreshold   float32 `json:"xtc_threshold,omitempty"`
+
+	// Samplers is the order the samplers are applied in, any of the Sampler
+	// constants. Samplers that aren't listed aren't applied.
+	Samplers []string `json:"samplers,omitempty"`
+
+	// ContextOverflow is what happens when the context window fills up, one
+	// of the ContextOverflow constants. The default is [ContextOverflowShift].
+	ContextOverflow string `json:"context_overflow,omitempty"`
+
+	// Deterministic makes a request's response for the same seed independent
+	// of how it's batched with other requests running in parallel. Its prompt
+	// is processed without reusing the prompt cache and always split the same
+	// way, and it's decoded in batches of its own, so it's slower while the
+	// model is busy. Where its entries are placed in the KV cache still
+	// depends on the other requests, so the response can differ slightly on
+	// backends where that changes the results.
+	Deterministic bool `json:"deterministic,omitempty"`
 }
 
+// Samplers for [Options.Samplers], in their default order
+const (
+	SamplerPenalties   = "penalties"
+	SamplerDry         = "dry"
+	SamplerTopK        = "top_k"
+	SamplerTypicalP    = "typical_p"
+	SamplerTopP        = "top_p"
+	SamplerMinP        = "min_p"
+	SamplerXTC         = "xtc"
+	SamplerTemperature = "temperature"
+)
+
+// Strategies for [Options.ContextOverflow]
+const (
+	// ContextOverflowShift drops the oldest messages that don't fit in the
+	// prompt and the oldest tokens after num_keep while generating.
+	ContextOverflowShift = "shift"
+
+	// ContextOverflowError stops with the done reason "context_length"
+	// instead of dropping anything.
+	ContextOverflowError = "error"
+
+	// ContextOverflowTruncateMiddle keeps the start of the conversation,
+	// the first message or the first quarter of the context window, and
+	// drops from the middle.
+	ContextOverflowTruncateMiddle = "truncate_middle"
+
+	// ContextOverflowSummarize replaces the chat messages that don't fit
+	// with a summary written by the model, added to the system message.
+	// Otherwise it's the same as [ContextOverflowShift].
+	ContextOverflowSummarize = "summarize"
+)
+
 // Runner options which must be set when the model is loaded into memory
 type Runner struct {
 	NumCtx    int   `json:"num_ctx,omitempty"`
@@ -271,6 +447,44 @@ type EmbedResponse struct {
 	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
 }
 
+// TokenizeRequest is the request passed to [Client.Tokenize].
+type TokenizeRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Content is the text to tokenize.
+	Content string `json:"content,omitempty"`
+
+	// Messages, when set instead of Content, are rendered with the model's
+	// chat template and the resulting prompt is tokenized, counting the
+	// tokens a chat request with the same messages would use.
+	Messages []Message `json:"messages,omitempty"`
+
+	// Tools are the tools passed to the chat template along with Messages.
+	Tools `json:"tools,omitempty"`
+}
+
+// TokenizeResponse is the response from [Client.Tokenize].
+type TokenizeResponse struct {
+	Model  string `json:"model"`
+	Tokens []int  `json:"tokens"`
+}
+
+// DetokenizeRequest is the request passed to [Client.Detokenize].
+type DetokenizeRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Tokens are the tokens to turn back into text.
+	Tokens []int `json:"tokens"`
+}
+
+// DetokenizeResponse is the response from [Client.Detokenize].
+type DetokenizeResponse struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+}
+
 // EmbeddingRequest is the request passed to [Client.Embeddings].
 type EmbeddingRequest struct {
 	// Model is the model name.
@@ -348,6 +562,22 @@ type ShowResponse struct {
 	ModelInfo     map[string]any `json:"model_info,omitempty"`
 	ProjectorInfo map[string]any `json:"projector_info,omitempty"`
 	ModifiedAt    time.Time      `json:"modified_at,omitempty"`
+
+	// Capabilities are what the model can be used for, such as
+	// "completion", "tools" and "insert" for requests with a suffix.
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// DiffRequest is the request passed to [Client.Diff].
+type DiffRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DiffResponse is the response returned from [Client.Diff]. Diff is a unified
+// diff of the two models and is empty if they are identical.
+type DiffResponse struct {
+	Diff string `json:"diff"`
 }
 
 // CopyRequest is the request passed to [Client.Copy].
@@ -381,6 +611,7 @@ type ProgressResponse struct {
 type PushRequest struct {
 	Model    string `json:"model"`
 	Insecure bool   `json:"insecure,omitempty"`
+	Sign     bool   `json:"sign,omitempty"`
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Stream   *bool  `json:"stream,omitempty"`
@@ -420,6 +651,112 @@ type ProcessModelResponse struct {
 	SizeVRAM  int64        `json:"size_vram"`
 }
 
+// LogsRequest is the request passed to [Client.Logs].
+type LogsRequest struct {
+	// Level is the lowest level of the records returned: debug, info, warn
+	// or error. Defaults to info. Debug records are only logged when the
+	// server runs with OLLAMA_DEBUG set.
+	Level string `json:"level,omitempty"`
+
+	// Tail is the number of the most recent records returned, or every record
+	// the server has kept if 0.
+	Tail int `json:"tail,omitempty"`
+
+	// Follow keeps the request open and returns records as they're logged.
+	Follow bool `json:"follow,omitempty"`
+}
+
+// LogRecord is a record logged by the server, returned by [Client.Logs].
+type LogRecord struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Source  string    `json:"source,omitempty"`
+	Message string    `json:"msg"`
+	Attrs   []LogAttr `json:"attrs,omitempty"`
+}
+
+// LogAttr is an attribute of a [LogRecord].
+type LogAttr struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// StatusResponse is the response from [Client.Status].
+type StatusResponse struct {
+	Version string `json:"version"`
+
+	// Queued is the number of requests waiting for a model to be scheduled.
+	Queued int `json:"queued"`
+
+	GPUs []GPUStatus `json:"gpus"`
+
+	// Env holds the effective value of each environment variable the server
+	// reads.
+	Env map[string]string `json:"env"`
+}
+
+// GPUStatus describes a GPU, or the CPU if there are none, in [StatusResponse].
+type GPUStatus struct {
+	ID            string `json:"id"`
+	Name          string `json:"name,omitempty"`
+	Library       string `json:"library"`
+	Variant       string `json:"variant,omitempty"`
+	Compute       string `json:"compute,omitempty"`
+	DriverVersion string `json:"driver_version,omitempty"`
+	TotalMemory   uint64 `json:"total_memory"`
+	FreeMemory    uint64 `json:"free_memory"`
+}
+
+// DiskUsageResponse is the response from [Client.DiskUsage].
+type DiskUsageResponse struct {
+	Models []ModelDiskUsage `json:"models"`
+	Blobs  []BlobDiskUsage  `json:"blobs"`
+
+	// Partial lists files in the blobs directory that aren't complete blobs,
+	// such as interrupted downloads
+	Partial []PartialBlob `json:"partial,omitempty"`
+
+	// Size is the total size of the blobs directory in bytes
+	Size int64 `json:"size"`
+}
+
+// ModelDiskUsage is the disk usage of a single model in [DiskUsageResponse].
+// Unique is the size of the blobs only used by this model and would be freed
+// if the model was removed.
+type ModelDiskUsage struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Unique int64  `json:"unique"`
+	Shared int64  `json:"shared"`
+}
+
+// BlobDiskUsage is a single blob in [DiskUsageResponse]. Blobs that aren't
+// referenced by any model have no Models.
+type BlobDiskUsage struct {
+	Digest string   `json:"digest"`
+	Size   int64    `json:"size"`
+	Models []string `json:"models"`
+}
+
+// PartialBlob is a file in the blobs directory that isn't a complete blob.
+type PartialBlob struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// PruneRequest is the request passed to [Client.Prune].
+type PruneRequest struct {
+	// DryRun reports what would be removed without removing anything
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// PruneResponse is the response from [Client.Prune]. Removed lists the files
+// removed, relative to the models directory.
+type PruneResponse struct {
+	Removed []string `json:"removed"`
+	Size    int64    `json:"size"`
+}
+
 type RetrieveModelResponse struct {
 	Id      string `json:"id"`
 	Object  string `json:"object"`
@@ -452,6 +789,23 @@ type GenerateResponse struct {
 	// can be sent in the next request to keep a conversational memory.
 	Context []int `json:"context,omitempty"`
 
+	// TruncatedTokens is the number of tokens left out of the middle of the
+	// prompt because it didn't fit in the context window, set in the final
+	// response.
+	TruncatedTokens int `json:"truncated_tokens,omitempty"`
+
+	// PromptInfo describes the rendered prompt. It's only set for dry runs.
+	PromptInfo *PromptInfo `json:"prompt_info,omitempty"`
+
+	// Status is the progress of the request before its content starts. It's
+	// only set when Progress is requested, in responses without content.
+	Status *Status `json:"status,omitempty"`
+
+	// Seed is the seed used to sample the response, set in the final
+	// response. It's chosen at random unless the request sets the seed
+	// option, and setting the seed option to it reproduces the response.
+	Seed *int `json:"seed,omitempty"`
+
 	Metrics
 }
 
@@ -483,6 +837,11 @@ func (m *Metrics) Summary() {
 		fmt.Fprintf(os.Stderr, "prompt eval rate:     %.2f tokens/s\n", float64(m.PromptEvalCount)/m.PromptEvalDuration.Seconds())
 	}
 
+	if m.PromptCacheHitCount > 0 {
+		fmt.Fprintf(os.Stderr, "prompt cache hits:    %d token(s)\n", m.PromptCacheHitCount)
+		fmt.Fprintf(os.Stderr, "prompt cache saved:   %s\n", m.PromptCacheSavedDuration)
+	}
+
 	if m.EvalCount > 0 {
 		fmt.Fprintf(os.Stderr, "eval count:           %d token(s)\n", m.EvalCount)
 	}
@@ -606,6 +965,10 @@ func DefaultOptions() Options {
 		MirostatTau:      5.0,
 		MirostatEta:      0.1,
 		Seed:             -1,
+		DryBase:          1.75,
+		DryAllowedLength: 2,
+		DryPenaltyLastN:  -1,
+		XTCThreshold:     0.1,
 
 		Runner: Runner{
 			// options set when the model is loaded
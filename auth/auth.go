@@ -90,3 +90,33 @@ func Sign(ctx context.Context, bts []byte) (string, error) {
 	// signature is <pubkey>:<signature>
 	return fmt.Sprintf("%s:%s", bytes.TrimSpace(parts[1]), base64.StdEncoding.EncodeToString(signedData.Blob)), nil
 }
+
+// Verify checks a signature created by [Sign] against bts and returns the
+// public key of the signer in authorized_keys format.
+func Verify(bts []byte, signature string) (string, error) {
+	encodedKey, encodedSig, ok := strings.Cut(signature, ":")
+	if !ok {
+		return "", errors.New("malformed signature")
+	}
+
+	rawKey, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := ssh.ParsePublicKey(rawKey)
+	if err != nil {
+		return "", err
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", err
+	}
+
+	if err := publicKey.Verify(bts, &ssh.Signature{Format: publicKey.Type(), Blob: blob}); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), nil
+}
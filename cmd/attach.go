@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ollama/ollama/api"
+)
+
+// maxAttachmentFileSize is the size of the largest file which is attached,
+// larger files in directories are skipped
+const maxAttachmentFileSize = 1 << 20
+
+var errBinaryFile = errors.New("binary files can't be attached")
+
+// attachment is a file, directory or PDF whose text is added to the next
+// message sent from the interactive REPL
+type attachment struct {
+	Path    string
+	Content string
+	Files   int
+	Skipped int
+	Tokens  int
+}
+
+func newAttachment(p string) (*attachment, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	a := attachment{Path: p}
+	if fi.IsDir() {
+		var sb strings.Builder
+		a.Files, a.Skipped, err = readDirText(p, func(name, text string) {
+			sb.WriteString(fencedFile(name, text))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if a.Files == 0 {
+			return nil, fmt.Errorf("no text files found in '%s'", p)
+		}
+
+		a.Content = sb.String()
+		return &a, nil
+	}
+
+	if fi.Size() > 100*maxAttachmentFileSize {
+		return nil, errors.New("file size exceeds maximum limit (100MB)")
+	}
+
+	bts, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(bts)
+	if bytes.HasPrefix(bts, []byte("%PDF-")) {
+		if text, err = pdfText(bts); err != nil {
+			return nil, err
+		}
+	} else if !isText(bts) {
+		return nil, errBinaryFile
+	}
+
+	a.Files = 1
+	a.Content = fencedFile(filepath.Base(p), text)
+	return &a, nil
+}
+
+// isText reports whether data looks like text rather than a binary file
+func isText(data []byte) bool {
+	sample := data[:min(len(data), 8000)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+
+	// allow a multi-byte rune to be cut off at the end of the sample
+	for len(sample) > 0 && !utf8.Valid(sample) && len(data) > len(sample) {
+		sample = sample[:len(sample)-1]
+	}
+
+	return utf8.Valid(sample)
+}
+
+// fencedFile formats a file for a prompt. The fence is longer than any run of
+// backticks in the text so it can't be ended early.
+func fencedFile(name, text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n\n", name, fence, strings.TrimRight(text, "\n"), fence)
+}
+
+// readDirText calls fn with the path relative to root and contents of each
+// text file under root. Files ignored by .gitignore files, binary files and
+// files over maxAttachmentFileSize are skipped.
+func readDirText(root string, fn func(name, text string)) (files, skipped int, err error) {
+	var ignore gitignore
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" || (rel != "." && ignore.match(rel, true)) {
+				return filepath.SkipDir
+			}
+
+			return ignore.load(p, rel)
+		}
+
+		if ignore.match(rel, false) || !d.Type().IsRegular() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if fi.Size() > maxAttachmentFileSize {
+			skipped++
+			return nil
+		}
+
+		bts, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		if !isText(bts) {
+			skipped++
+			return nil
+		}
+
+		files++
+		fn(rel, string(bts))
+		return nil
+	})
+
+	return files, skipped, err
+}
+
+type gitignoreRule struct {
+	// dir is the directory of the .gitignore file the rule is from
+	dir      string
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// gitignore matches paths against the rules of the .gitignore files found
+// while walking a directory
+type gitignore []gitignoreRule
+
+// load adds the rules of the .gitignore file in dir, if there is one
+func (g *gitignore) load(dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := gitignoreRule{dir: rel}
+		if rule.dir == "." {
+			rule.dir = ""
+		}
+
+		if p, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate, line = true, p
+		}
+
+		line = strings.TrimPrefix(line, `\`)
+
+		if p, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly, line = true, p
+		}
+
+		// patterns with a slash other than at the end are relative to the
+		// directory of the .gitignore, others match at any depth
+		if strings.Contains(line, "/") {
+			rule.anchored, line = true, strings.TrimPrefix(line, "/")
+		}
+
+		rule.re, err = globRegexp(line)
+		if err != nil {
+			continue
+		}
+
+		*g = append(*g, rule)
+	}
+
+	return scanner.Err()
+}
+
+// match reports whether the path relative to the root of the walk is ignored.
+// Later rules take precedence over earlier ones.
+func (g gitignore) match(rel string, isDir bool) bool {
+	var ignored bool
+	for _, rule := range g {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		p := rel
+		if rule.dir != "" {
+			var ok bool
+			if p, ok = strings.CutPrefix(rel, rule.dir+"/"); !ok {
+				continue
+			}
+		}
+
+		if !rule.anchored {
+			p = path.Base(p)
+		}
+
+		if rule.re.MatchString(p) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// globRegexp converts a gitignore glob to a regular expression
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				sb.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := strings.Replace(glob[i+1:i+end], "!", "^", 1)
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// a pattern matching a directory also matches everything in it
+	sb.WriteString("(?:/.*)?$")
+
+	return regexp.Compile(sb.String())
+}
+
+// attachmentPrompt prepends the attachments to the prompt
+func attachmentPrompt(attachments []*attachment, prompt string) string {
+	var sb strings.Builder
+	for _, a := range attachments {
+		sb.WriteString(a.Content)
+	}
+	sb.WriteString(prompt)
+	return sb.String()
+}
+
+// contextLength returns the context length requests for the model will use
+func contextLength(ctx context.Context, client *api.Client, opts runOptions) int {
+	if n, ok := opts.Options["num_ctx"]; ok {
+		if n, err := strconv.Atoi(fmt.Sprint(n)); err == nil {
+			return n
+		}
+	}
+
+	if resp, err := client.Show(ctx, &api.ShowRequest{Model: opts.Model}); err == nil {
+		for _, line := range strings.Split(resp.Parameters, "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "num_ctx" {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					return n
+				}
+			}
+		}
+	}
+
+	return api.DefaultOptions().NumCtx
+}
+
+// addAttachment reads the attachment at p and counts its tokens with the
+// model's tokenizer. It fails if the attachments would no longer fit in the
+// model's context along with the conversation so far.
+func addAttachment(ctx context.Context, attachments []*attachment, p string, opts runOptions) ([]*attachment, error) {
+	a, err := newAttachment(p)
+	if err != nil {
+		return attachments, err
+	}
+
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return attachments, err
+	}
+
+	resp, err := client.Tokenize(ctx, &api.TokenizeRequest{Model: opts.Model, Content: a.Content})
+	if err != nil {
+		return attachments, err
+	}
+	a.Tokens = len(resp.Tokens)
+
+	var used int
+	for _, a := range attachments {
+		used += a.Tokens
+	}
+
+	var history int
+	if len(opts.Messages) > 0 {
+		resp, err := client.Tokenize(ctx, &api.TokenizeRequest{Model: opts.Model, Messages: opts.Messages})
+		if err != nil {
+			return attachments, err
+		}
+		history = len(resp.Tokens)
+	}
+
+	if budget := contextLength(ctx, client, opts); history+used+a.Tokens > budget {
+		return attachments, fmt.Errorf("'%s' is %d tokens which, with %d tokens already attached and %d tokens of conversation, is more than the context length of %d. Use /set parameter num_ctx to increase it", p, a.Tokens, used, history, budget)
+	}
+
+	return append(attachments, a), nil
+}
+
+// removeAttachment removes an attachment by its position in /attach list or its path
+func removeAttachment(attachments []*attachment, arg string) ([]*attachment, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(attachments) {
+			return attachments, fmt.Errorf("no attachment %d", n)
+		}
+		return append(attachments[:n-1], attachments[n:]...), nil
+	}
+
+	for i, a := range attachments {
+		if a.Path == arg {
+			return append(attachments[:i], attachments[i+1:]...), nil
+		}
+	}
+
+	return attachments, fmt.Errorf("'%s' is not attached", arg)
+}
+
+// expandPath removes quotes or escapes from a path typed or dragged into the
+// terminal and expands ~ to the home directory
+func expandPath(p string) string {
+	p = strings.TrimSpace(p)
+	if len(p) > 1 && (p[0] == '\'' || p[0] == '"') && p[len(p)-1] == p[0] {
+		p = p[1 : len(p)-1]
+	} else {
+		p = normalizeFilePath(p)
+	}
+
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, rest)
+		}
+	}
+
+	return p
+}
+
+// droppedPath returns the path if line is only the path of an existing file or
+// directory dragged into the terminal, or "" otherwise. Terminals paste these
+// paths quoted or with special characters escaped, so a bare path typed as a
+// message isn't mistaken for one.
+func droppedPath(line string) string {
+	line = strings.TrimSpace(line)
+	quoted := len(line) > 1 && (line[0] == '\'' || line[0] == '"') && line[len(line)-1] == line[0]
+	if !quoted && normalizeFilePath(line) == line {
+		return ""
+	}
+
+	p := expandPath(line)
+	if !filepath.IsAbs(p) && !strings.HasPrefix(p, "./") && !strings.HasPrefix(p, `.\`) {
+		return ""
+	}
+
+	if _, err := os.Stat(p); err != nil {
+		return ""
+	}
+
+	return p
+}
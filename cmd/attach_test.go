@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestReadDirText(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":          "*.log\n!keep.log\nbuild/\n/top.txt\n",
+		"main.go":             "package main\n",
+		"debug.log":           "ignored",
+		"keep.log":            "kept",
+		"top.txt":             "ignored",
+		"build/out.txt":       "ignored",
+		"pkg/top.txt":         "kept",
+		"pkg/.gitignore":      "secret*\n",
+		"pkg/secret.txt":      "ignored",
+		"pkg/deep/secret.txt": "ignored",
+		"pkg/image.png":       "\x89PNG\r\n\x1a\n\x00\x00",
+		".git/config":         "ignored",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	var names []string
+	files, skipped, err := readDirText(root, func(name, text string) {
+		names = append(names, name)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{".gitignore", "keep.log", "main.go", "pkg/.gitignore", "pkg/top.txt"}, names)
+	assert.Equal(t, 5, files)
+	assert.Equal(t, 1, skipped)
+}
+
+func TestFencedFile(t *testing.T) {
+	assert.Equal(t, "a.md\n````\nuse ```go\n````\n\n", fencedFile("a.md", "use ```go\n"))
+}
+
+func TestPDFText(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("BT /F1 12 Tf 72 700 Td [(Second) -300 (page)] TJ 0 -14 Td <48692028636F646529> Tj ET")) //nolint:errcheck
+	zw.Close()
+
+	page1 := `BT /F1 12 Tf 72 720 Td (Hello \(PDF\) world) Tj T* (caf\351) Tj ET`
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(page1), page1)
+	fmt.Fprintf(&pdf, "5 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n", compressed.Len(), compressed.Bytes())
+	pdf.WriteString("6 0 obj\n<< /Length 4 /Subtype /Image /Filter /DCTDecode >>\nstream\n\xff\xd8BT\nendstream\nendobj\n%%EOF\n")
+
+	text, err := pdfText(pdf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello (PDF) world\ncafé\nSecond page\nHi (code)", text)
+
+	_, err = pdfText([]byte("%PDF-1.4\n%%EOF"))
+	assert.ErrorIs(t, err, errNoPDFText)
+
+	_, err = pdfText([]byte("hello"))
+	assert.ErrorIs(t, err, errNotPDF)
+}
+
+func TestDroppedPath(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "my notes.txt")
+	require.NoError(t, os.WriteFile(p, []byte("notes"), 0o644))
+
+	// paths are pasted quoted or escaped
+	assert.Equal(t, p, droppedPath("'"+p+"' "))
+	assert.Equal(t, p, droppedPath(`"`+p+`"`))
+	assert.Equal(t, p, droppedPath(strings.ReplaceAll(p, " ", `\ `)))
+	assert.Equal(t, "", droppedPath("why is the sky blue?"))
+	assert.Equal(t, "", droppedPath("'"+filepath.Join(dir, "missing.txt")+"'"))
+
+	// a bare path is a message, even if the file exists
+	bare := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(bare, []byte("notes"), 0o644))
+	assert.Equal(t, "", droppedPath(bare))
+	assert.Equal(t, "", droppedPath(p))
+	assert.Equal(t, bare, droppedPath("'"+bare+"'"))
+}
+
+func TestRemoveAttachment(t *testing.T) {
+	attachments := []*attachment{{Path: "a"}, {Path: "b"}, {Path: "c"}}
+
+	attachments, err := removeAttachment(attachments, "2")
+	require.NoError(t, err)
+	assert.Equal(t, []*attachment{{Path: "a"}, {Path: "c"}}, attachments)
+
+	attachments, err = removeAttachment(attachments, "c")
+	require.NoError(t, err)
+	assert.Equal(t, []*attachment{{Path: "a"}}, attachments)
+
+	_, err = removeAttachment(attachments, "3")
+	assert.Error(t, err)
+	_, err = removeAttachment(attachments, "missing")
+	assert.Error(t, err)
+}
+
+func TestAddAttachment(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tokenize" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req api.TokenizeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		// a token per byte of content or message
+		n := len(req.Content)
+		for _, m := range req.Messages {
+			n += len(m.Content)
+		}
+
+		json.NewEncoder(w).Encode(api.TokenizeResponse{Tokens: make([]int, n)}) //nolint:errcheck
+	}))
+	defer mockServer.Close()
+	t.Setenv("OLLAMA_HOST", mockServer.URL)
+
+	p := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(p, bytes.Repeat([]byte("a"), 60), 0o644))
+
+	opts := runOptions{Model: "test", Options: map[string]any{"num_ctx": 100}}
+	attachments, err := addAttachment(context.Background(), nil, p, opts)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+
+	// the conversation counts against the context too
+	opts.Messages = []api.Message{{Role: "user", Content: string(bytes.Repeat([]byte("b"), 50))}}
+	_, err = addAttachment(context.Background(), nil, p, opts)
+	assert.ErrorContains(t, err, "50 tokens of conversation")
+}
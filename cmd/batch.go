@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/progress"
+)
+
+// batchRequest is a single line of the input to ollama run --jsonl. Requests
+// with messages are sent to the chat endpoint, with any images attached to the
+// last user message, otherwise the prompt is generated from.
+type batchRequest struct {
+	ID       string          `json:"id,omitempty"`
+	Prompt   string          `json:"prompt,omitempty"`
+	System   string          `json:"system,omitempty"`
+	Messages []api.Message   `json:"messages,omitempty"`
+	Images   []api.ImageData `json:"images,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+// batchResponse is a single line of the output of ollama run --jsonl. Line is
+// the line of the input the response is for since responses are written in
+// the order they complete.
+type batchResponse struct {
+	Line       int          `json:"line"`
+	ID         string       `json:"id,omitempty"`
+	Model      string       `json:"model"`
+	Response   string       `json:"response,omitempty"`
+	Message    *api.Message `json:"message,omitempty"`
+	DoneReason string       `json:"done_reason,omitempty"`
+	Error      string       `json:"error,omitempty"`
+
+	api.Metrics
+}
+
+type batchOptions struct {
+	Input       string
+	Output      string
+	Concurrency int
+}
+
+type batchStats struct {
+	Completed int
+	Failed    int
+	Skipped   int
+	EvalCount int
+}
+
+// completedLines reads the output of a previous run and returns the input
+// lines which completed without an error. A partially written last line is
+// removed so new responses can be appended.
+func completedLines(f *os.File) (map[int]bool, error) {
+	bts, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := bytes.LastIndexByte(bts, '\n'); i+1 < len(bts) {
+		bts = bts[:i+1]
+		if err := f.Truncate(int64(len(bts))); err != nil {
+			return nil, err
+		}
+	}
+
+	done := make(map[int]bool)
+	for _, line := range bytes.Split(bts, []byte("\n")) {
+		var resp batchResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			continue
+		}
+
+		if resp.Error == "" {
+			done[resp.Line] = true
+		}
+	}
+
+	return done, nil
+}
+
+// runBatch runs every request in bopts.Input against the model and appends the
+// responses to bopts.Output. Requests which already completed in a previous run
+// writing to the same output are skipped.
+func runBatch(ctx context.Context, client *api.Client, opts runOptions, bopts batchOptions, fn func(batchStats)) (*batchStats, error) {
+	var in io.Reader = os.Stdin
+	if bopts.Input != "-" {
+		f, err := os.Open(bopts.Input)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var out io.Writer = os.Stdout
+	done := make(map[int]bool)
+	if bopts.Output != "" {
+		f, err := os.OpenFile(bopts.Output, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		done, err = completedLines(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		out = f
+	}
+
+	var mu sync.Mutex
+	var stats batchStats
+	write := func(resp batchResponse) error {
+		bts, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, err := out.Write(append(bts, '\n')); err != nil {
+			return err
+		}
+
+		if resp.Error != "" {
+			stats.Failed++
+		} else {
+			stats.Completed++
+			stats.EvalCount += resp.EvalCount
+		}
+
+		fn(stats)
+		return nil
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(bopts.Concurrency, 1))
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	var n int
+	for scanner.Scan() {
+		n++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		if done[n] {
+			mu.Lock()
+			stats.Skipped++
+			mu.Unlock()
+			continue
+		}
+
+		line := n
+		var req batchRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			if err := write(batchResponse{Line: line, Model: opts.Model, Error: err.Error()}); err != nil {
+				g.Wait() //nolint:errcheck
+				return &stats, err
+			}
+			continue
+		}
+
+		g.Go(func() error {
+			resp, err := runBatchRequest(ctx, client, opts, req)
+			if errors.Is(err, context.Canceled) {
+				// leave the request to be retried when the run is resumed
+				return err
+			}
+
+			resp.Line = line
+			resp.ID = req.ID
+			resp.Model = opts.Model
+			if err != nil {
+				resp.Error = err.Error()
+			}
+
+			return write(resp)
+		})
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		return &stats, err
+	}
+
+	return &stats, scanner.Err()
+}
+
+func runBatchRequest(ctx context.Context, client *api.Client, opts runOptions, req batchRequest) (batchResponse, error) {
+	options := maps.Clone(opts.Options)
+	if options == nil {
+		options = map[string]any{}
+	}
+	maps.Copy(options, req.Options)
+
+	format := req.Format
+	if len(format) == 0 && opts.Format == "json" {
+		format = json.RawMessage(`"json"`)
+	} else if len(format) == 0 && opts.Format != "" {
+		// a JSON schema
+		format = json.RawMessage(opts.Format)
+	}
+
+	stream := false
+
+	var resp batchResponse
+	if len(req.Messages) > 0 {
+		messages := slices.Clone(req.Messages)
+		if len(req.Images) > 0 {
+			// images go with the last user message
+			i := len(messages) - 1
+			for i >= 0 && messages[i].Role != "user" {
+				i--
+			}
+
+			if i < 0 {
+				return resp, errors.New("request has images but no user message")
+			}
+
+			messages[i].Images = slices.Concat(messages[i].Images, req.Images)
+		}
+
+		if req.System != "" {
+			messages = append([]api.Message{{Role: "system", Content: req.System}}, messages...)
+		}
+
+		err := client.Chat(ctx, &api.ChatRequest{
+			Model:     opts.Model,
+			Messages:  messages,
+			Format:    format,
+			Options:   options,
+			KeepAlive: opts.KeepAlive,
+			Stream:    &stream,
+		}, func(r api.ChatResponse) error {
+			resp.Message = &r.Message
+			resp.DoneReason = r.DoneReason
+			resp.Metrics = r.Metrics
+			return nil
+		})
+
+		return resp, err
+	}
+
+	if req.Prompt == "" {
+		return resp, errors.New("request has neither a prompt nor messages")
+	}
+
+	err := client.Generate(ctx, &api.GenerateRequest{
+		Model:     opts.Model,
+		Prompt:    req.Prompt,
+		System:    req.System,
+		Images:    req.Images,
+		Format:    format,
+		Options:   options,
+		KeepAlive: opts.KeepAlive,
+		Stream:    &stream,
+	}, func(r api.GenerateResponse) error {
+		resp.Response = r.Response
+		resp.DoneReason = r.DoneReason
+		resp.Metrics = r.Metrics
+		return nil
+	})
+
+	return resp, err
+}
+
+func batch(ctx context.Context, opts runOptions, bopts batchOptions) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	// stop on Ctrl-C without writing responses for the interrupted requests
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	p := progress.NewProgress(os.Stderr)
+
+	spinner := progress.NewSpinner("")
+	p.Add("", spinner)
+
+	start := time.Now()
+	stats, err := runBatch(ctx, client, opts, bopts, func(s batchStats) {
+		spinner.SetMessage(fmt.Sprintf("completed %d, failed %d", s.Completed, s.Failed))
+	})
+	p.StopAndClear()
+	if stats == nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	fmt.Fprintf(os.Stderr, "completed:  %d\n", stats.Completed)
+	fmt.Fprintf(os.Stderr, "failed:     %d\n", stats.Failed)
+	if stats.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "skipped:    %d (completed by a previous run)\n", stats.Skipped)
+	}
+	fmt.Fprintf(os.Stderr, "duration:   %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(os.Stderr, "eval rate:  %.2f tokens/s\n", float64(stats.EvalCount)/elapsed.Seconds())
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errors.New("interrupted, run the same command again to resume")
+	case err != nil:
+		return err
+	case stats.Failed > 0:
+		return fmt.Errorf("%d request(s) failed, run the same command again to retry them", stats.Failed)
+	}
+
+	return nil
+}
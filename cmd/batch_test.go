@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestRunBatch(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/api/generate":
+			var req api.GenerateRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, 0.5, req.Options["temperature"])
+			json.NewEncoder(w).Encode(api.GenerateResponse{Response: "re: " + req.Prompt, Done: true, DoneReason: "stop", Metrics: api.Metrics{EvalCount: 3}}) //nolint:errcheck
+		case "/api/chat":
+			var req api.ChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "system", req.Messages[0].Role)
+			json.NewEncoder(w).Encode(api.ChatResponse{Message: api.Message{Role: "assistant", Content: "hi"}, Done: true}) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+
+	t.Setenv("OLLAMA_HOST", mockServer.URL)
+	client, err := api.ClientFromEnvironment()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.jsonl")
+	require.NoError(t, os.WriteFile(in, []byte(`{"id":"a","prompt":"one"}
+{"id":"b","prompt":"two"}
+
+{"id":"c","system":"be brief","messages":[{"role":"user","content":"hello"}]}
+not json
+{"id":"e"}
+`), 0o644))
+
+	// a previous run completed the first line and was interrupted while writing the second
+	out := filepath.Join(dir, "out.jsonl")
+	require.NoError(t, os.WriteFile(out, []byte(`{"line":1,"id":"a","model":"test","response":"re: one"}
+{"line":2,"id":"b","mod`), 0o644))
+
+	opts := runOptions{Model: "test", Options: map[string]any{"temperature": 0.5}}
+	stats, err := runBatch(context.Background(), client, opts, batchOptions{Input: in, Output: out, Concurrency: 2}, func(batchStats) {})
+	require.NoError(t, err)
+
+	assert.Equal(t, batchStats{Completed: 2, Failed: 2, Skipped: 1, EvalCount: 3}, *stats)
+	assert.EqualValues(t, 2, requests.Load(), "invalid requests aren't sent")
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var responses []batchResponse
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var resp batchResponse
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &resp), scanner.Text())
+		responses = append(responses, resp)
+	}
+
+	sort.Slice(responses, func(i, j int) bool { return responses[i].Line < responses[j].Line })
+	require.Len(t, responses, 5)
+
+	assert.Equal(t, "re: one", responses[0].Response)
+	assert.Equal(t, "re: two", responses[1].Response)
+	assert.Equal(t, "b", responses[1].ID)
+	assert.Equal(t, "stop", responses[1].DoneReason)
+	assert.Equal(t, 3, responses[1].EvalCount)
+	assert.Equal(t, "hi", responses[2].Message.Content)
+	assert.Equal(t, 4, responses[2].Line)
+	assert.NotEmpty(t, responses[3].Error)
+	assert.Equal(t, "e", responses[4].ID)
+	assert.NotEmpty(t, responses[4].Error)
+
+	// running again only retries the failed requests
+	stats, err = runBatch(context.Background(), client, opts, batchOptions{Input: in, Output: out}, func(batchStats) {})
+	require.NoError(t, err)
+	assert.Equal(t, batchStats{Failed: 2, Skipped: 3}, *stats)
+}
+
+func TestRunBatchRequest(t *testing.T) {
+	var chat api.ChatRequest
+	var generate api.GenerateRequest
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/generate":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&generate))
+			json.NewEncoder(w).Encode(api.GenerateResponse{Done: true}) //nolint:errcheck
+		case "/api/chat":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chat))
+			json.NewEncoder(w).Encode(api.ChatResponse{Done: true}) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+
+	t.Setenv("OLLAMA_HOST", mockServer.URL)
+	client, err := api.ClientFromEnvironment()
+	require.NoError(t, err)
+
+	t.Run("format", func(t *testing.T) {
+		schema := `{"type":"object","properties":{"age":{"type":"integer"}}}`
+		for _, format := range []string{"json", schema} {
+			_, err := runBatchRequest(context.Background(), client, runOptions{Model: "test", Format: format}, batchRequest{Prompt: "hi"})
+			require.NoError(t, err)
+
+			want := format
+			if format == "json" {
+				want = `"json"`
+			}
+			assert.JSONEq(t, want, string(generate.Format))
+		}
+
+		_, err := runBatchRequest(context.Background(), client, runOptions{Model: "test", Format: "json"}, batchRequest{Prompt: "hi", Format: json.RawMessage(schema)})
+		require.NoError(t, err)
+		assert.JSONEq(t, schema, string(generate.Format), "the request's format takes precedence")
+	})
+
+	t.Run("images", func(t *testing.T) {
+		messages := []api.Message{
+			{Role: "user", Content: "what is this?", Images: []api.ImageData{[]byte("a")}},
+			{Role: "assistant", Content: "a cat"},
+			{Role: "user", Content: "and this?"},
+			{Role: "assistant", Content: "hmm"},
+		}
+
+		_, err := runBatchRequest(context.Background(), client, runOptions{Model: "test"}, batchRequest{Messages: messages, Images: []api.ImageData{[]byte("b")}})
+		require.NoError(t, err)
+		require.Len(t, chat.Messages, 4)
+		assert.Equal(t, []api.ImageData{[]byte("a")}, chat.Messages[0].Images)
+		assert.Equal(t, []api.ImageData{[]byte("b")}, chat.Messages[2].Images)
+		assert.Empty(t, messages[2].Images, "the request's messages aren't modified")
+
+		_, err = runBatchRequest(context.Background(), client, runOptions{Model: "test"}, batchRequest{Messages: messages[1:2], Images: []api.ImageData{[]byte("b")}})
+		assert.Error(t, err)
+	})
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
@@ -263,6 +264,37 @@ func StopHandler(cmd *cobra.Command, args []string) error {
 func RunHandler(cmd *cobra.Command, args []string) error {
 	interactive := true
 
+	compareModels, err := cmd.Flags().GetStringSlice("compare")
+	if err != nil {
+		return err
+	}
+
+	if len(compareModels) > 0 {
+		// every argument is part of the prompt when the models are given with --compare
+		return runCompare(cmd, compareModels, args)
+	}
+
+	sessionName, err := cmd.Flags().GetString("session")
+	if err != nil {
+		return err
+	}
+
+	var sess *session
+	if sessionName != "" {
+		sess, err = loadSession(sessionName)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			if len(args) == 0 {
+				return fmt.Errorf("session '%s' not found", sessionName)
+			}
+			sess = nil
+		case err != nil:
+			return err
+		case len(args) == 0:
+			args = []string{sess.Model}
+		}
+	}
+
 	opts := runOptions{
 		Model:    args[0],
 		WordWrap: os.Getenv("TERM") == "xterm-256color",
@@ -287,9 +319,18 @@ func RunHandler(cmd *cobra.Command, args []string) error {
 		opts.KeepAlive = &api.Duration{Duration: d}
 	}
 
+	jsonl, err := cmd.Flags().GetString("jsonl")
+	if err != nil {
+		return err
+	}
+
+	if jsonl != "" && len(args) > 1 {
+		return errors.New("prompts can't be given with --jsonl")
+	}
+
 	prompts := args[1:]
 	// prepend stdin to the prompt if provided
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
+	if jsonl == "" && !term.IsTerminal(int(os.Stdin.Fd())) {
 		in, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return err
@@ -314,6 +355,21 @@ func RunHandler(cmd *cobra.Command, args []string) error {
 	}
 	opts.WordWrap = !nowrap
 
+	markdown, err := cmd.Flags().GetBool("markdown")
+	if err != nil {
+		return err
+	}
+	// only render Markdown for a terminal so piped output is left as is
+	opts.Markdown = markdown && term.IsTerminal(int(os.Stdout.Fd()))
+
+	if sess != nil {
+		sess.apply(&opts)
+		// the model and format given on the command line take precedence
+		opts.Model = args[0]
+		opts.Format = cmp.Or(format, opts.Format)
+	}
+	opts.Session = sessionName
+
 	// Fill out the rest of the options based on information about the
 	// model.
 	client, err := api.ClientFromEnvironment()
@@ -321,19 +377,7 @@ func RunHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	name := args[0]
-	info, err := func() (*api.ShowResponse, error) {
-		showReq := &api.ShowRequest{Name: name}
-		info, err := client.Show(cmd.Context(), showReq)
-		var se api.StatusError
-		if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
-			if err := PullHandler(cmd, []string{name}); err != nil {
-				return nil, err
-			}
-			return client.Show(cmd.Context(), &api.ShowRequest{Name: name})
-		}
-		return info, err
-	}()
+	info, err := showOrPull(cmd, client, args[0])
 	if err != nil {
 		return err
 	}
@@ -341,28 +385,77 @@ func RunHandler(cmd *cobra.Command, args []string) error {
 	opts.MultiModal = len(info.ProjectorInfo) != 0
 	opts.ParentModel = info.Details.ParentModel
 
+	if jsonl != "" {
+		out, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			return err
+		}
+
+		return batch(cmd.Context(), opts, batchOptions{Input: jsonl, Output: out, Concurrency: concurrency})
+	}
+
 	if interactive {
 		if err := loadOrUnloadModel(cmd, &opts); err != nil {
 			return err
 		}
 
-		for _, msg := range info.Messages {
-			switch msg.Role {
-			case "user":
-				fmt.Printf(">>> %s\n", msg.Content)
-			case "assistant":
-				state := &displayResponseState{}
-				displayResponse(msg.Content, opts.WordWrap, state)
-				fmt.Println()
-				fmt.Println()
-			}
-		}
+		displayMessages(info.Messages, opts)
+		displayMessages(opts.Messages, opts)
 
 		return generateInteractive(cmd, opts)
 	}
+
+	if opts.Session != "" {
+		opts.Messages = append(opts.Messages, api.Message{Role: "user", Content: opts.Prompt})
+		assistant, err := chat(cmd, opts)
+		if err != nil {
+			return err
+		}
+
+		if assistant != nil {
+			opts.Messages = append(opts.Messages, *assistant)
+		}
+
+		return saveSession(opts.Session, newSession(opts))
+	}
+
 	return generate(cmd, opts)
 }
 
+// showOrPull returns information about the model, pulling it first if it
+// hasn't been
+func showOrPull(cmd *cobra.Command, client *api.Client, name string) (*api.ShowResponse, error) {
+	info, err := client.Show(cmd.Context(), &api.ShowRequest{Name: name})
+	var se api.StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		if err := PullHandler(cmd, []string{name}); err != nil {
+			return nil, err
+		}
+		return client.Show(cmd.Context(), &api.ShowRequest{Name: name})
+	}
+	return info, err
+}
+
+func displayMessages(msgs []api.Message, opts runOptions) {
+	for _, msg := range msgs {
+		switch msg.Role {
+		case "user":
+			fmt.Printf(">>> %s\n", msg.Content)
+		case "assistant":
+			display := newResponseDisplay(opts)
+			display.Write(msg.Content)
+			display.Flush()
+			fmt.Println()
+			fmt.Println()
+		}
+	}
+}
+
 func PushHandler(cmd *cobra.Command, args []string) error {
 	client, err := api.ClientFromEnvironment()
 	if err != nil {
@@ -408,7 +501,12 @@ func PushHandler(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	request := api.PushRequest{Name: args[0], Insecure: insecure}
+	sign, err := cmd.Flags().GetBool("sign")
+	if err != nil {
+		return err
+	}
+
+	request := api.PushRequest{Name: args[0], Insecure: insecure, Sign: sign}
 
 	n := model.ParseName(args[0])
 	if err := client.Push(cmd.Context(), &request, fn); err != nil {
@@ -467,6 +565,113 @@ func ListHandler(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func DiskUsageHandler(cmd *cobra.Command, args []string) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	blobs, err := cmd.Flags().GetBool("blobs")
+	if err != nil {
+		return err
+	}
+
+	usage, err := client.DiskUsage(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	newTable := func(header ...string) *tablewriter.Table {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(header)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetNoWhiteSpace(true)
+		table.SetTablePadding("    ")
+		return table
+	}
+
+	if blobs {
+		table := newTable("DIGEST", "SIZE", "MODELS")
+		for _, b := range usage.Blobs {
+			models := strings.Join(b.Models, ", ")
+			if len(b.Models) == 0 {
+				models = "(unused)"
+			}
+
+			table.Append([]string{strings.TrimPrefix(b.Digest, "sha256:")[:12], format.HumanBytes(b.Size), models})
+		}
+
+		for _, p := range usage.Partial {
+			table.Append([]string{p.Name, format.HumanBytes(p.Size), "(partial)"})
+		}
+
+		table.Render()
+		return nil
+	}
+
+	table := newTable("NAME", "SIZE", "UNIQUE", "SHARED")
+	for _, m := range usage.Models {
+		table.Append([]string{m.Name, format.HumanBytes(m.Size), format.HumanBytes(m.Unique), format.HumanBytes(m.Shared)})
+	}
+	table.Render()
+
+	var unused int64
+	for _, b := range usage.Blobs {
+		if len(b.Models) == 0 {
+			unused += b.Size
+		}
+	}
+
+	for _, p := range usage.Partial {
+		unused += p.Size
+	}
+
+	fmt.Printf("\nTotal: %s", format.HumanBytes(usage.Size))
+	if unused > 0 {
+		fmt.Printf(" (%s can be removed with 'ollama prune')", format.HumanBytes(unused))
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func PruneHandler(cmd *cobra.Command, args []string) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Prune(cmd.Context(), &api.PruneRequest{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+
+	for _, name := range resp.Removed {
+		fmt.Printf("%s %s\n", verb, name)
+	}
+
+	if dryRun {
+		fmt.Printf("%s would be freed\n", format.HumanBytes(resp.Size))
+	} else {
+		fmt.Printf("%s freed\n", format.HumanBytes(resp.Size))
+	}
+
+	return nil
+}
+
 func ListRunningHandler(cmd *cobra.Command, args []string) error {
 	client, err := api.ClientFromEnvironment()
 	if err != nil {
@@ -478,33 +683,43 @@ func ListRunningHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var data [][]string
-
+	var running []api.ProcessModelResponse
 	for _, m := range models.Models {
 		if len(args) == 0 || strings.HasPrefix(m.Name, args[0]) {
-			var procStr string
-			switch {
-			case m.SizeVRAM == 0:
-				procStr = "100% CPU"
-			case m.SizeVRAM == m.Size:
-				procStr = "100% GPU"
-			case m.SizeVRAM > m.Size || m.Size == 0:
-				procStr = "Unknown"
-			default:
-				sizeCPU := m.Size - m.SizeVRAM
-				cpuPercent := math.Round(float64(sizeCPU) / float64(m.Size) * 100)
-				procStr = fmt.Sprintf("%d%%/%d%% CPU/GPU", int(cpuPercent), int(100-cpuPercent))
-			}
+			running = append(running, m)
+		}
+	}
 
-			var until string
-			delta := time.Since(m.ExpiresAt)
-			if delta > 0 {
-				until = "Stopping..."
-			} else {
-				until = format.HumanTime(m.ExpiresAt, "Never")
-			}
-			data = append(data, []string{m.Name, m.Digest[:12], format.HumanBytes(m.Size), procStr, until})
+	printRunningModels(running)
+	return nil
+}
+
+func printRunningModels(models []api.ProcessModelResponse) {
+	var data [][]string
+
+	for _, m := range models {
+		var procStr string
+		switch {
+		case m.SizeVRAM == 0:
+			procStr = "100% CPU"
+		case m.SizeVRAM == m.Size:
+			procStr = "100% GPU"
+		case m.SizeVRAM > m.Size || m.Size == 0:
+			procStr = "Unknown"
+		default:
+			sizeCPU := m.Size - m.SizeVRAM
+			cpuPercent := math.Round(float64(sizeCPU) / float64(m.Size) * 100)
+			procStr = fmt.Sprintf("%d%%/%d%% CPU/GPU", int(cpuPercent), int(100-cpuPercent))
+		}
+
+		var until string
+		delta := time.Since(m.ExpiresAt)
+		if delta > 0 {
+			until = "Stopping..."
+		} else {
+			until = format.HumanTime(m.ExpiresAt, "Never")
 		}
+		data = append(data, []string{m.Name, m.Digest[:12], format.HumanBytes(m.Size), procStr, until})
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
@@ -517,8 +732,6 @@ func ListRunningHandler(cmd *cobra.Command, args []string) error {
 	table.SetTablePadding("    ")
 	table.AppendBulk(data)
 	table.Render()
-
-	return nil
 }
 
 func DeleteHandler(cmd *cobra.Command, args []string) error {
@@ -624,6 +837,21 @@ func ShowHandler(cmd *cobra.Command, args []string) error {
 	return showInfo(resp, os.Stdout)
 }
 
+func DiffHandler(cmd *cobra.Command, args []string) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Diff(cmd.Context(), &api.DiffRequest{From: args[0], To: args[1]})
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(resp.Diff)
+	return nil
+}
+
 func showInfo(resp *api.ShowResponse, w io.Writer) error {
 	tableRender := func(header string, rows func() [][]string) {
 		fmt.Fprintln(w, " ", header)
@@ -669,6 +897,15 @@ func showInfo(resp *api.ShowResponse, w io.Writer) error {
 		})
 	}
 
+	if len(resp.Capabilities) > 0 {
+		tableRender("Capabilities", func() (rows [][]string) {
+			for _, capability := range resp.Capabilities {
+				rows = append(rows, []string{"", capability})
+			}
+			return
+		})
+	}
+
 	if resp.Parameters != "" {
 		tableRender("Parameters", func() (rows [][]string) {
 			scanner := bufio.NewScanner(strings.NewReader(resp.Parameters))
@@ -782,12 +1019,16 @@ type runOptions struct {
 	Prompt      string
 	Messages    []api.Message
 	WordWrap    bool
+	Markdown    bool
 	Format      string
 	System      string
 	Images      []api.ImageData
 	Options     map[string]interface{}
 	MultiModal  bool
 	KeepAlive   *api.Duration
+
+	// Session is the name the conversation is saved under after each response
+	Session string
 }
 
 type displayResponseState struct {
@@ -866,7 +1107,7 @@ func chat(cmd *cobra.Command, opts runOptions) (*api.Message, error) {
 		cancel()
 	}()
 
-	var state *displayResponseState = &displayResponseState{}
+	display := newResponseDisplay(opts)
 	var latest api.ChatResponse
 	var fullResponse strings.Builder
 	var role string
@@ -880,7 +1121,7 @@ func chat(cmd *cobra.Command, opts runOptions) (*api.Message, error) {
 		content := response.Message.Content
 		fullResponse.WriteString(content)
 
-		displayResponse(content, opts.WordWrap, state)
+		display.Write(content)
 
 		return nil
 	}
@@ -900,7 +1141,9 @@ func chat(cmd *cobra.Command, opts runOptions) (*api.Message, error) {
 		req.KeepAlive = opts.KeepAlive
 	}
 
-	if err := client.Chat(cancelCtx, req, fn); err != nil {
+	err = client.Chat(cancelCtx, req, fn)
+	display.Flush()
+	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			return nil, nil
 		}
@@ -954,7 +1197,7 @@ func generate(cmd *cobra.Command, opts runOptions) error {
 		cancel()
 	}()
 
-	var state *displayResponseState = &displayResponseState{}
+	display := newResponseDisplay(opts)
 
 	fn := func(response api.GenerateResponse) error {
 		p.StopAndClear()
@@ -962,7 +1205,7 @@ func generate(cmd *cobra.Command, opts runOptions) error {
 		latest = response
 		content := response.Response
 
-		displayResponse(content, opts.WordWrap, state)
+		display.Write(content)
 
 		return nil
 	}
@@ -989,7 +1232,9 @@ func generate(cmd *cobra.Command, opts runOptions) error {
 		KeepAlive: opts.KeepAlive,
 	}
 
-	if err := client.Generate(ctx, &request, fn); err != nil {
+	err = client.Generate(ctx, &request, fn)
+	display.Flush()
+	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			return nil
 		}
@@ -1151,8 +1396,12 @@ func NewCLI() *cobra.Command {
 		CompletionOptions: cobra.CompletionOptions{
 			DisableDefaultCmd: true,
 		},
+		PersistentPreRunE: loadConfig,
+		// --version still works with a broken config file
+		Annotations: map[string]string{reportsConfigError: ""},
 		Run: func(cmd *cobra.Command, args []string) {
 			if version, _ := cmd.Flags().GetBool("version"); version {
+				reportConfigError(os.Stderr)
 				versionHandler(cmd, args)
 				return
 			}
@@ -1162,6 +1411,7 @@ func NewCLI() *cobra.Command {
 	}
 
 	rootCmd.Flags().BoolP("version", "v", false, "Show version information")
+	rootCmd.PersistentFlags().String("config", "", "Path to the config file (default ~/.ollama/config.toml or config.yaml)")
 
 	createCmd := &cobra.Command{
 		Use:     "create MODEL",
@@ -1173,13 +1423,16 @@ func NewCLI() *cobra.Command {
 
 	createCmd.Flags().StringP("file", "f", "", "Name of the Modelfile (default \"Modelfile\"")
 	createCmd.Flags().StringP("quantize", "q", "", "Quantize model to this level (e.g. q4_0)")
+	createCmd.RegisterFlagCompletionFunc("file", completeModelfiles)            //nolint:errcheck
+	createCmd.RegisterFlagCompletionFunc("quantize", completeQuantizationTypes) //nolint:errcheck
 
 	showCmd := &cobra.Command{
-		Use:     "show MODEL",
-		Short:   "Show information for a model",
-		Args:    cobra.ExactArgs(1),
-		PreRunE: checkServerHeartbeat,
-		RunE:    ShowHandler,
+		Use:               "show MODEL",
+		Short:             "Show information for a model",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModels(1),
+		PreRunE:           checkServerHeartbeat,
+		RunE:              ShowHandler,
 	}
 
 	showCmd.Flags().Bool("license", false, "Show license of a model")
@@ -1188,26 +1441,53 @@ func NewCLI() *cobra.Command {
 	showCmd.Flags().Bool("template", false, "Show template of a model")
 	showCmd.Flags().Bool("system", false, "Show system message of a model")
 
+	diffCmd := &cobra.Command{
+		Use:               "diff MODEL MODEL",
+		Short:             "Compare two models",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModels(2),
+		PreRunE:           checkServerHeartbeat,
+		RunE:              DiffHandler,
+	}
+
 	runCmd := &cobra.Command{
-		Use:     "run MODEL [PROMPT]",
-		Short:   "Run a model",
-		Args:    cobra.MinimumNArgs(1),
-		PreRunE: checkServerHeartbeat,
-		RunE:    RunHandler,
+		Use:   "run MODEL [PROMPT]",
+		Short: "Run a model",
+		Args: func(cmd *cobra.Command, args []string) error {
+			// the model can be omitted when resuming a session or comparing models
+			if name, _ := cmd.Flags().GetString("session"); name != "" {
+				return nil
+			}
+			if models, _ := cmd.Flags().GetStringSlice("compare"); len(models) > 0 {
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		PreRunE:           checkServerHeartbeat,
+		RunE:              RunHandler,
+		ValidArgsFunction: completeModels(1),
 	}
 
 	runCmd.Flags().String("keepalive", "", "Duration to keep a model loaded (e.g. 5m)")
 	runCmd.Flags().Bool("verbose", false, "Show timings for response")
 	runCmd.Flags().Bool("insecure", false, "Use an insecure registry")
 	runCmd.Flags().Bool("nowordwrap", false, "Don't wrap words to the next line automatically")
+	runCmd.Flags().Bool("markdown", false, "Render Markdown in responses")
 	runCmd.Flags().String("format", "", "Response format (e.g. json)")
+	runCmd.Flags().String("session", "", "Resume a saved conversation and save new messages to it")
+	runCmd.Flags().String("jsonl", "", "Run each request in a JSONL file (- for stdin) instead of a prompt")
+	runCmd.Flags().String("out", "", "Append --jsonl responses to this file and resume from it if it exists (default stdout)")
+	runCmd.Flags().Int("concurrency", 1, "Number of --jsonl requests to run at once")
+	runCmd.Flags().StringSlice("compare", nil, "Send each prompt to these comma separated models and show the responses side by side")
+	runCmd.RegisterFlagCompletionFunc("compare", completeModelList) //nolint:errcheck
 
 	stopCmd := &cobra.Command{
-		Use:     "stop MODEL",
-		Short:   "Stop a running model",
-		Args:    cobra.ExactArgs(1),
-		PreRunE: checkServerHeartbeat,
-		RunE:    StopHandler,
+		Use:               "stop MODEL",
+		Short:             "Stop a running model",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeRunningModels,
+		PreRunE:           checkServerHeartbeat,
+		RunE:              StopHandler,
 	}
 
 	serveCmd := &cobra.Command{
@@ -1219,24 +1499,27 @@ func NewCLI() *cobra.Command {
 	}
 
 	pullCmd := &cobra.Command{
-		Use:     "pull MODEL",
-		Short:   "Pull a model from a registry",
-		Args:    cobra.ExactArgs(1),
-		PreRunE: checkServerHeartbeat,
-		RunE:    PullHandler,
+		Use:               "pull MODEL",
+		Short:             "Pull a model from a registry",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModels(1),
+		PreRunE:           checkServerHeartbeat,
+		RunE:              PullHandler,
 	}
 
 	pullCmd.Flags().Bool("insecure", false, "Use an insecure registry")
 
 	pushCmd := &cobra.Command{
-		Use:     "push MODEL",
-		Short:   "Push a model to a registry",
-		Args:    cobra.ExactArgs(1),
-		PreRunE: checkServerHeartbeat,
-		RunE:    PushHandler,
+		Use:               "push MODEL",
+		Short:             "Push a model to a registry",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModels(1),
+		PreRunE:           checkServerHeartbeat,
+		RunE:              PushHandler,
 	}
 
 	pushCmd.Flags().Bool("insecure", false, "Use an insecure registry")
+	pushCmd.Flags().Bool("sign", false, "Sign the model with your ollama key")
 
 	listCmd := &cobra.Command{
 		Use:     "list",
@@ -1253,20 +1536,111 @@ func NewCLI() *cobra.Command {
 		RunE:    ListRunningHandler,
 	}
 
-	copyCmd := &cobra.Command{
-		Use:     "cp SOURCE DESTINATION",
-		Short:   "Copy a model",
-		Args:    cobra.ExactArgs(2),
+	duCmd := &cobra.Command{
+		Use:     "du",
+		Short:   "Show disk usage of models",
+		Args:    cobra.ExactArgs(0),
 		PreRunE: checkServerHeartbeat,
-		RunE:    CopyHandler,
+		RunE:    DiskUsageHandler,
 	}
 
-	deleteCmd := &cobra.Command{
-		Use:     "rm MODEL [MODEL...]",
-		Short:   "Remove a model",
-		Args:    cobra.MinimumNArgs(1),
+	duCmd.Flags().Bool("blobs", false, "Show the models that use each blob")
+
+	pruneCmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "Remove unused blobs and partial downloads",
+		Args:    cobra.ExactArgs(0),
 		PreRunE: checkServerHeartbeat,
-		RunE:    DeleteHandler,
+		RunE:    PruneHandler,
+	}
+
+	pruneCmd.Flags().Bool("dry-run", false, "Show what would be removed without removing anything")
+
+	logsCmd := &cobra.Command{
+		Use:     "logs",
+		Short:   "Show the server log",
+		Args:    cobra.ExactArgs(0),
+		PreRunE: checkServerHeartbeat,
+		RunE:    LogsHandler,
+	}
+
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep showing records as they're logged")
+	logsCmd.Flags().String("level", "info", "Lowest level of records to show (debug, info, warn or error), debug needs the server run with OLLAMA_DEBUG=1")
+	logsCmd.Flags().IntP("tail", "n", 0, "Number of the most recent records to show (default all kept by the server)")
+	logsCmd.RegisterFlagCompletionFunc("level", cobra.FixedCompletions([]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp)) //nolint:errcheck
+
+	statusCmd := &cobra.Command{
+		Use:     "status",
+		Short:   "Show the state of the server",
+		Args:    cobra.ExactArgs(0),
+		PreRunE: checkServerHeartbeat,
+		RunE:    StatusHandler,
+	}
+
+	copyCmd := &cobra.Command{
+		Use:               "cp SOURCE DESTINATION",
+		Short:             "Copy a model",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModels(1),
+		PreRunE:           checkServerHeartbeat,
+		RunE:              CopyHandler,
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:               "rm MODEL [MODEL...]",
+		Short:             "Remove a model",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeModels(0),
+		PreRunE:           checkServerHeartbeat,
+		RunE:              DeleteHandler,
+	}
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Print(cmd.UsageString())
+		},
+	}
+
+	configShowCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective value of each setting and where it's set",
+		Long: `Show the effective value of each setting and where it's set.
+
+Settings are read from the environment, then the config file, then the
+defaults. The config file is TOML or YAML, e.g.
+
+  keep_alive = "10m"
+  num_parallel = 2
+  origins = ["https://example.com"]
+
+Keys are the environment variable names, or the names in lower case without
+the OLLAMA_ prefix. Unknown keys are an error.`,
+		Args:        cobra.ExactArgs(0),
+		Annotations: map[string]string{reportsConfigError: ""},
+		RunE:        ConfigShowHandler,
+	}
+
+	configCmd.AddCommand(configShowCmd)
+
+	completionCmd := &cobra.Command{
+		Use:   "completion SHELL",
+		Short: "Generate a shell completion script",
+		Long: `Generate a completion script for bash, zsh or fish.
+
+To load completions in the current shell:
+
+  bash:  source <(ollama completion bash)
+  zsh:   source <(ollama completion zsh)
+  fish:  ollama completion fish | source
+
+To load them in every new shell, add the line to ~/.bashrc or ~/.zshrc, or for
+fish run: ollama completion fish > ~/.config/fish/completions/ollama.fish`,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		RunE:      CompletionHandler,
 	}
 
 	runnerCmd := &cobra.Command{
@@ -1289,12 +1663,17 @@ func NewCLI() *cobra.Command {
 	for _, cmd := range []*cobra.Command{
 		createCmd,
 		showCmd,
+		diffCmd,
 		runCmd,
 		stopCmd,
 		pullCmd,
 		pushCmd,
 		listCmd,
 		psCmd,
+		duCmd,
+		pruneCmd,
+		logsCmd,
+		statusCmd,
 		copyCmd,
 		deleteCmd,
 		serveCmd,
@@ -1304,17 +1683,24 @@ func NewCLI() *cobra.Command {
 			appendEnvDocs(cmd, []envconfig.EnvVar{envVars["OLLAMA_HOST"], envVars["OLLAMA_NOHISTORY"]})
 		case serveCmd:
 			appendEnvDocs(cmd, []envconfig.EnvVar{
+				envVars["OLLAMA_CONFIG"],
 				envVars["OLLAMA_DEBUG"],
 				envVars["OLLAMA_HOST"],
 				envVars["OLLAMA_KEEP_ALIVE"],
 				envVars["OLLAMA_MAX_LOADED_MODELS"],
+				envVars["OLLAMA_MAX_LORAS"],
 				envVars["OLLAMA_MAX_QUEUE"],
+				envVars["OLLAMA_MAX_REQUEST_TIMEOUT"],
 				envVars["OLLAMA_MODELS"],
 				envVars["OLLAMA_NUM_PARALLEL"],
 				envVars["OLLAMA_NOPRUNE"],
 				envVars["OLLAMA_ORIGINS"],
+				envVars["OLLAMA_PREFILL_CHUNK_SIZE"],
+				envVars["OLLAMA_REQUEST_TIMEOUT"],
+				envVars["OLLAMA_REQUIRE_SIGNATURES"],
 				envVars["OLLAMA_SCHED_SPREAD"],
 				envVars["OLLAMA_TMPDIR"],
+				envVars["OLLAMA_TRUSTED_KEYS"],
 				envVars["OLLAMA_FLASH_ATTENTION"],
 				envVars["OLLAMA_KV_CACHE_TYPE"],
 				envVars["OLLAMA_LLM_LIBRARY"],
@@ -1330,14 +1716,21 @@ func NewCLI() *cobra.Command {
 		serveCmd,
 		createCmd,
 		showCmd,
+		diffCmd,
 		runCmd,
 		stopCmd,
 		pullCmd,
 		pushCmd,
 		listCmd,
 		psCmd,
+		duCmd,
+		pruneCmd,
+		logsCmd,
+		statusCmd,
 		copyCmd,
 		deleteCmd,
+		configCmd,
+		completionCmd,
 		runnerCmd,
 	)
 
@@ -0,0 +1,486 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+	"github.com/ollama/ollama/readline"
+)
+
+// minCompareColumnWidth is the narrowest column responses are shown side by
+// side in, narrower terminals show them one after another
+const minCompareColumnWidth = 30
+
+const compareColumnSeparator = " │ "
+
+// compareResponse is the response of one of the models being compared
+type compareResponse struct {
+	Model   string
+	Content string
+	Done    bool
+	Err     error
+
+	api.Metrics
+}
+
+// stats summarizes the response with the evaluation rate of the model
+func (r compareResponse) stats() string {
+	switch {
+	case r.Err != nil:
+		return "error: " + r.Err.Error()
+	case r.EvalDuration > 0:
+		return fmt.Sprintf("%d tokens, %.2f tokens/s", r.EvalCount, float64(r.EvalCount)/r.EvalDuration.Seconds())
+	default:
+		return fmt.Sprintf("%d tokens", r.EvalCount)
+	}
+}
+
+// compareDisplay shows the responses of the models as they're streamed
+type compareDisplay interface {
+	// update is called with the index of a model whenever its response changes
+	update(i int, responses []compareResponse)
+	// finish is called once every response is done
+	finish(responses []compareResponse)
+}
+
+// runComparison sends the conversation with each model to it concurrently.
+// histories holds the messages for each model. update is called whenever a
+// response changes and calls to it are serialized.
+func runComparison(ctx context.Context, client *api.Client, opts runOptions, histories [][]api.Message, models []string, update func(i int, responses []compareResponse)) []compareResponse {
+	responses := make([]compareResponse, len(models))
+	for i, model := range models {
+		responses[i].Model = model
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := &api.ChatRequest{
+				Model:     model,
+				Messages:  histories[i],
+				Options:   opts.Options,
+				KeepAlive: opts.KeepAlive,
+			}
+
+			if opts.Format == "json" {
+				req.Format = json.RawMessage(`"json"`)
+			} else if opts.Format != "" {
+				req.Format = json.RawMessage(opts.Format)
+			}
+
+			var sb strings.Builder
+			err := client.Chat(ctx, req, func(resp api.ChatResponse) error {
+				sb.WriteString(resp.Message.Content)
+
+				mu.Lock()
+				defer mu.Unlock()
+				responses[i].Content = sb.String()
+				responses[i].Metrics = resp.Metrics
+				update(i, responses)
+				return nil
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			responses[i].Done = true
+			if err != nil && !errors.Is(err, context.Canceled) {
+				responses[i].Err = err
+			}
+			update(i, responses)
+		}()
+	}
+
+	wg.Wait()
+	return responses
+}
+
+// wrapText breaks text into lines no wider than width, wrapping at spaces
+// where possible
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line strings.Builder
+		lineWidth := 0
+		for _, word := range strings.SplitAfter(paragraph, " ") {
+			wordWidth := runewidth.StringWidth(strings.TrimRight(word, " "))
+			if lineWidth > 0 && lineWidth+wordWidth > width {
+				lines = append(lines, strings.TrimRight(line.String(), " "))
+				line.Reset()
+				lineWidth = 0
+			}
+
+			// words longer than a line are broken wherever they reach the end
+			for _, r := range word {
+				w := runewidth.RuneWidth(r)
+				if lineWidth+w > width && r != ' ' {
+					lines = append(lines, line.String())
+					line.Reset()
+					lineWidth = 0
+				}
+				line.WriteRune(r)
+				lineWidth += w
+			}
+		}
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+	}
+
+	return lines
+}
+
+// joinColumns lays out columns of lines side by side, padding each to width
+func joinColumns(columns [][]string, width int) []string {
+	var rows int
+	for _, c := range columns {
+		rows = max(rows, len(c))
+	}
+
+	lines := make([]string, rows)
+	for row := range rows {
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			var cell string
+			if row < len(c) {
+				cell = c[row]
+			}
+
+			// the last column isn't padded so lines don't end in spaces
+			if i < len(columns)-1 {
+				cell = runewidth.FillRight(cell, width)
+			}
+			cells[i] = cell
+		}
+		lines[row] = strings.TrimRight(strings.Join(cells, compareColumnSeparator), " ")
+	}
+
+	return lines
+}
+
+// compareColumns renders the responses in side by side columns headed by the
+// model names
+func compareColumns(responses []compareResponse, width int, withStats bool) []string {
+	columns := make([][]string, len(responses))
+	for i, r := range responses {
+		column := append(wrapText(r.Model, width), strings.Repeat("─", width))
+		if r.Content != "" {
+			column = append(column, wrapText(strings.TrimSpace(r.Content), width)...)
+		}
+
+		columns[i] = column
+	}
+
+	if withStats {
+		// line up the stats below the longest response
+		var rows int
+		for _, c := range columns {
+			rows = max(rows, len(c))
+		}
+
+		for i, r := range responses {
+			for len(columns[i]) < rows {
+				columns[i] = append(columns[i], "")
+			}
+			columns[i] = append(columns[i], "")
+			columns[i] = append(columns[i], wrapText(r.stats(), width)...)
+		}
+	}
+
+	return joinColumns(columns, width)
+}
+
+// columnsDisplay shows responses side by side. While they're streamed only
+// the end of each one which fits in the terminal is shown, and once they're
+// all done the full responses are printed.
+type columnsDisplay struct {
+	w      io.Writer
+	width  int
+	height int
+
+	// drawn is the number of lines shown while the responses are streamed
+	drawn int
+}
+
+func (d *columnsDisplay) clear() {
+	if d.drawn > 0 {
+		fmt.Fprintf(d.w, "\x1b[%dA", d.drawn)
+	}
+	fmt.Fprint(d.w, "\r\x1b[J")
+	d.drawn = 0
+}
+
+func (d *columnsDisplay) update(_ int, responses []compareResponse) {
+	lines := compareColumns(responses, d.width, false)
+
+	// keep the headings and show the end of the responses which fit
+	if maxLines := d.height - 1; len(lines) > maxLines && maxLines > 2 {
+		lines = append(lines[:2], lines[len(lines)-(maxLines-2):]...)
+	}
+
+	d.clear()
+	for _, line := range lines {
+		fmt.Fprintln(d.w, line)
+	}
+	d.drawn = len(lines)
+}
+
+func (d *columnsDisplay) finish(responses []compareResponse) {
+	d.clear()
+	for _, line := range compareColumns(responses, d.width, true) {
+		fmt.Fprintln(d.w, line)
+	}
+	fmt.Fprintln(d.w)
+}
+
+// blocksDisplay shows responses one after another. The first response is
+// streamed and the others are shown once the ones before them are done.
+type blocksDisplay struct {
+	w io.Writer
+
+	// current is the index of the response being shown and written how much
+	// of it has been
+	current int
+	written int
+	headed  bool
+}
+
+func (d *blocksDisplay) update(_ int, responses []compareResponse) {
+	for d.current < len(responses) {
+		r := responses[d.current]
+		if !d.headed {
+			fmt.Fprintf(d.w, "── %s ──\n", r.Model)
+			d.headed = true
+		}
+
+		if len(r.Content) > d.written {
+			fmt.Fprint(d.w, r.Content[d.written:])
+			d.written = len(r.Content)
+		}
+
+		if !r.Done {
+			return
+		}
+
+		fmt.Fprintf(d.w, "\n\n%s\n\n", r.stats())
+		d.current++
+		d.written = 0
+		d.headed = false
+	}
+}
+
+func (d *blocksDisplay) finish([]compareResponse) {}
+
+// newCompareDisplay shows responses in columns if the terminal is wide enough
+// for them, or one after another otherwise
+func newCompareDisplay(models []string) compareDisplay {
+	fd := int(os.Stdout.Fd())
+	if term.IsTerminal(fd) {
+		if termWidth, termHeight, err := term.GetSize(fd); err == nil {
+			width := (termWidth - 1 - (len(models)-1)*runewidth.StringWidth(compareColumnSeparator)) / len(models)
+			if width >= minCompareColumnWidth {
+				return &columnsDisplay{w: os.Stdout, width: width, height: termHeight}
+			}
+		}
+	}
+
+	return &blocksDisplay{w: os.Stdout}
+}
+
+// compare sends the prompt to each model, adding it and the responses to the
+// conversations in histories
+func compare(cmd *cobra.Command, opts runOptions, models []string, histories [][]api.Message, prompt string) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT)
+	defer signal.Stop(sigChan)
+
+	go func() {
+		select {
+		case <-sigChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for i := range histories {
+		histories[i] = append(histories[i], api.Message{Role: "user", Content: prompt})
+	}
+
+	display := newCompareDisplay(models)
+	responses := runComparison(ctx, client, opts, histories, models, display.update)
+	display.finish(responses)
+
+	for i, r := range responses {
+		if r.Err == nil {
+			histories[i] = append(histories[i], api.Message{Role: "assistant", Content: r.Content})
+		}
+	}
+
+	return nil
+}
+
+// runCompare sends the prompt in args, or each message typed, to every model
+func runCompare(cmd *cobra.Command, models []string, args []string) error {
+	if len(models) < 2 {
+		return errors.New("--compare needs at least two models, e.g. --compare llama3.2,mistral")
+	}
+
+	for _, flag := range []string{"session", "jsonl"} {
+		if v, _ := cmd.Flags().GetString(flag); v != "" {
+			return fmt.Errorf("--compare can't be used with --%s", flag)
+		}
+	}
+
+	opts := runOptions{Options: map[string]any{}}
+
+	var err error
+	if opts.Format, err = cmd.Flags().GetString("format"); err != nil {
+		return err
+	}
+
+	keepAlive, err := cmd.Flags().GetString("keepalive")
+	if err != nil {
+		return err
+	}
+	if keepAlive != "" {
+		d, err := time.ParseDuration(keepAlive)
+		if err != nil {
+			return err
+		}
+		opts.KeepAlive = &api.Duration{Duration: d}
+	}
+
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	for _, model := range models {
+		if _, err := showOrPull(cmd, client, model); err != nil {
+			return err
+		}
+	}
+
+	prompts := args
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		in, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		prompts = append([]string{string(in)}, prompts...)
+	}
+
+	if len(prompts) == 0 && term.IsTerminal(int(os.Stdout.Fd())) {
+		return compareInteractive(cmd, opts, models)
+	}
+
+	return compare(cmd, opts, models, make([][]api.Message, len(models)), strings.Join(prompts, " "))
+}
+
+// compareInteractive sends each message typed to every model, keeping a
+// separate conversation with each
+func compareInteractive(cmd *cobra.Command, opts runOptions, models []string) error {
+	scanner, err := readline.New(readline.Prompt{
+		Prompt:         ">>> ",
+		AltPrompt:      "... ",
+		Placeholder:    fmt.Sprintf("Send a message to %s (/bye to exit)", strings.Join(models, ", ")),
+		AltPlaceholder: `Use """ to end multi-line input`,
+	})
+	if err != nil {
+		return err
+	}
+
+	if envconfig.NoHistory() {
+		scanner.HistoryDisable()
+	}
+
+	fmt.Print(readline.StartBracketedPaste)
+	defer fmt.Printf(readline.EndBracketedPaste)
+
+	histories := make([][]api.Message, len(models))
+
+	var sb strings.Builder
+	for {
+		line, err := scanner.Readline()
+		switch {
+		case errors.Is(err, io.EOF):
+			fmt.Println()
+			return nil
+		case errors.Is(err, readline.ErrInterrupt):
+			if line == "" {
+				fmt.Println("\nUse Ctrl + d or /bye to exit.")
+			}
+
+			scanner.Prompt.UseAlt = false
+			sb.Reset()
+			continue
+		case err != nil:
+			return err
+		}
+
+		switch {
+		case scanner.Prompt.UseAlt:
+			before, ok := strings.CutSuffix(line, `"""`)
+			sb.WriteString(before)
+			if !ok {
+				fmt.Fprintln(&sb)
+				continue
+			}
+			scanner.Prompt.UseAlt = false
+		case strings.HasPrefix(line, `"""`):
+			line, ok := strings.CutSuffix(strings.TrimPrefix(line, `"""`), `"""`)
+			sb.WriteString(line)
+			if !ok {
+				fmt.Fprintln(&sb)
+				scanner.Prompt.UseAlt = true
+				continue
+			}
+		case scanner.Pasting:
+			fmt.Fprintln(&sb, line)
+			continue
+		case strings.HasPrefix(line, "/exit"), strings.HasPrefix(line, "/bye"):
+			return nil
+		case strings.HasPrefix(line, "/clear"):
+			histories = make([][]api.Message, len(models))
+			fmt.Println("Cleared session context")
+			continue
+		case strings.HasPrefix(line, "/"):
+			fmt.Println("Only /clear and /bye can be used when comparing models.")
+			continue
+		default:
+			sb.WriteString(line)
+		}
+
+		if sb.Len() > 0 {
+			if err := compare(cmd, opts, models, histories, sb.String()); err != nil {
+				return err
+			}
+			sb.Reset()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestWrapText(t *testing.T) {
+	cases := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"the quick brown fox", 10, []string{"the quick", "brown fox"}},
+		{"first\n\nsecond line", 10, []string{"first", "", "second", "line"}},
+		{"supercalifragilistic", 8, []string{"supercal", "ifragili", "stic"}},
+		{"日本語のテキスト", 6, []string{"日本語", "のテキ", "スト"}},
+	}
+
+	for _, tt := range cases {
+		assert.Equal(t, tt.want, wrapText(tt.text, tt.width), tt.text)
+	}
+}
+
+func TestCompareColumns(t *testing.T) {
+	responses := []compareResponse{
+		{Model: "a", Content: "one two three", Done: true, Metrics: api.Metrics{EvalCount: 10, EvalDuration: 2 * time.Second}},
+		{Model: "b", Content: "four", Done: true, Metrics: api.Metrics{EvalCount: 3}},
+	}
+
+	assert.Equal(t, []string{
+		"a        │ b",
+		"──────── │ ────────",
+		"one two  │ four",
+		"three    │",
+		"         │",
+		"10       │ 3 tokens",
+		"tokens,  │",
+		"5.00     │",
+		"tokens/s │",
+	}, compareColumns(responses, 8, true))
+}
+
+func TestBlocksDisplay(t *testing.T) {
+	var b bytes.Buffer
+	d := &blocksDisplay{w: &b}
+
+	responses := []compareResponse{{Model: "a"}, {Model: "b"}}
+	d.update(0, responses)
+
+	// the second response is held back until the first is done
+	responses[1].Content = "world"
+	d.update(1, responses)
+	responses[0].Content = "hello"
+	d.update(0, responses)
+	assert.Equal(t, "── a ──\nhello", b.String())
+
+	responses[0].Done = true
+	d.update(0, responses)
+	responses[1].Done = true
+	d.update(1, responses)
+	assert.Equal(t, "── a ──\nhello\n\n0 tokens\n\n── b ──\nworld\n\n0 tokens\n\n", b.String())
+}
+
+func TestRunComparison(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "hi", req.Messages[len(req.Messages)-1].Content)
+
+		if req.Model == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "out of memory"}) //nolint:errcheck
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		enc.Encode(api.ChatResponse{Model: req.Model, Message: api.Message{Role: "assistant", Content: "hello "}})                                                            //nolint:errcheck
+		enc.Encode(api.ChatResponse{Model: req.Model, Message: api.Message{Role: "assistant", Content: "from " + req.Model}, Done: true, Metrics: api.Metrics{EvalCount: 2}}) //nolint:errcheck
+	}))
+	defer mockServer.Close()
+
+	t.Setenv("OLLAMA_HOST", mockServer.URL)
+	client, err := api.ClientFromEnvironment()
+	require.NoError(t, err)
+
+	models := []string{"a", "b", "broken"}
+	histories := [][]api.Message{{{Role: "user", Content: "hi"}}, {{Role: "user", Content: "hi"}}, {{Role: "user", Content: "hi"}}}
+
+	var updates int
+	responses := runComparison(context.Background(), client, runOptions{}, histories, models, func(int, []compareResponse) { updates++ })
+
+	assert.Equal(t, "hello from a", responses[0].Content)
+	assert.Equal(t, "hello from b", responses[1].Content)
+	assert.Equal(t, 2, responses[1].EvalCount)
+	assert.EqualError(t, responses[2].Err, "out of memory")
+	for _, r := range responses {
+		assert.True(t, r.Done)
+	}
+	assert.Equal(t, 7, updates)
+}
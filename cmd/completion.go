@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/llm"
+)
+
+// completionTimeout bounds how long completions wait for the server so a
+// server which isn't running doesn't hang the shell
+const completionTimeout = 2 * time.Second
+
+func CompletionHandler(cmd *cobra.Command, args []string) error {
+	root, w := cmd.Root(), cmd.OutOrStdout()
+	switch args[0] {
+	case "bash":
+		return root.GenBashCompletionV2(w, true)
+	case "zsh":
+		return root.GenZshCompletion(w)
+	case "fish":
+		return root.GenFishCompletion(w, true)
+	default:
+		return fmt.Errorf("unsupported shell '%s', use bash, zsh or fish", args[0])
+	}
+}
+
+// matchPrefix returns the names which start with prefix and haven't been given
+// as arguments already
+func matchPrefix(names []string, prefix string, exclude []string) []string {
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !slices.Contains(exclude, name) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+func completionContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, completionTimeout)
+}
+
+// localModels returns the names of the models which have been pulled or created
+func localModels(cmd *cobra.Command) []string {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := completionContext(cmd)
+	defer cancel()
+
+	resp, err := client.List(ctx)
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// runningModels returns the names of the models which are loaded
+func runningModels(cmd *cobra.Command) []string {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := completionContext(cmd)
+	defer cancel()
+
+	resp, err := client.ListRunning(ctx)
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// completeModels completes the names of local models for the first n
+// arguments, or every argument if n is 0
+func completeModels(n int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if n > 0 && len(args) >= n {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		return matchPrefix(localModels(cmd), toComplete, args), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func completeRunningModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return matchPrefix(runningModels(cmd), toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeModelList completes the last model in a comma separated list
+func completeModelList(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var given []string
+	prefix := ""
+	if i := strings.LastIndexByte(toComplete, ','); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+		given = strings.Split(prefix[:i], ",")
+	}
+
+	var completions []string
+	for _, name := range matchPrefix(localModels(cmd), toComplete, given) {
+		completions = append(completions, prefix+name)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// isModelfile reports whether a file is likely a Modelfile from its name
+func isModelfile(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "modelfile") || strings.HasSuffix(name, ".modelfile")
+}
+
+// completeModelfiles completes paths to Modelfiles and the directories which
+// may contain them
+func completeModelfiles(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dir, base := filepath.Split(toComplete)
+
+	entries, err := os.ReadDir(cmp.Or(dir, "."))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []string
+	directive := cobra.ShellCompDirectiveNoFileComp
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+
+		switch {
+		case e.IsDir():
+			completions = append(completions, dir+name+string(filepath.Separator))
+			// let the directory be completed into rather than finished with a space
+			directive |= cobra.ShellCompDirectiveNoSpace
+		case isModelfile(name):
+			completions = append(completions, dir+name)
+		}
+	}
+
+	return completions, directive
+}
+
+// completeQuantizationTypes completes the types models can be quantized to,
+// in lower case if that's how they're being typed
+func completeQuantizationTypes(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	types := llm.QuantizationTypes()
+	if toComplete != strings.ToUpper(toComplete) {
+		for i := range types {
+			types[i] = strings.ToLower(types[i])
+		}
+	}
+
+	return matchPrefix(types, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestCompletion(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			json.NewEncoder(w).Encode(api.ListResponse{Models: []api.ListModelResponse{ //nolint:errcheck
+				{Name: "llama3.2:latest"},
+				{Name: "llava:latest"},
+				{Name: "mistral:latest"},
+			}})
+		case "/api/ps":
+			json.NewEncoder(w).Encode(api.ProcessResponse{Models: []api.ProcessModelResponse{{Name: "mistral:latest"}}}) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+
+	t.Setenv("OLLAMA_HOST", mockServer.URL)
+
+	dir := t.TempDir()
+	for _, name := range []string{"Modelfile", "llama.modelfile", "README.md", "models/Modelfile"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, nil, 0o644))
+	}
+	dir += string(filepath.Separator)
+
+	cases := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"run", "ll"}, []string{"llama3.2:latest", "llava:latest"}},
+		{[]string{"run", "llama3.2", ""}, nil},
+		{[]string{"rm", "llava:latest", "ll"}, []string{"llama3.2:latest"}},
+		{[]string{"stop", ""}, []string{"mistral:latest"}},
+		{[]string{"run", "--compare", "llama3.2:latest,"}, []string{"llama3.2:latest,llava:latest", "llama3.2:latest,mistral:latest"}},
+		{[]string{"create", "test", "-f", dir}, []string{dir + "Modelfile", dir + "llama.modelfile", dir + "models" + string(filepath.Separator)}},
+		{[]string{"create", "test", "-f", dir + "m"}, []string{dir + "models" + string(filepath.Separator)}},
+		{[]string{"create", "test", "-f", filepath.Join(dir, "models") + string(filepath.Separator)}, []string{filepath.Join(dir, "models", "Modelfile")}},
+		{[]string{"create", "test", "-q", "q4_k"}, []string{"q4_k_s", "q4_k_m"}},
+		{[]string{"create", "test", "-q", "Q8"}, []string{"Q8_0"}},
+		{[]string{"completion", ""}, []string{"bash", "zsh", "fish"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			cli := NewCLI()
+			cli.SetOut(&out)
+			cli.SetArgs(append([]string{cobra.ShellCompRequestCmd}, tt.args...))
+			require.NoError(t, cli.Execute())
+
+			// the last line is the directive
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			var got []string
+			got = append(got, lines[:len(lines)-1]...)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		cmd := NewCLI()
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"completion", shell})
+		require.NoError(t, cmd.Execute(), shell)
+		assert.Contains(t, out.String(), "ollama", shell)
+	}
+
+	cmd := NewCLI()
+	cmd.SetArgs([]string{"completion", "tcsh"})
+	assert.Error(t, cmd.Execute())
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/ollama/ollama/envconfig"
+)
+
+// reportsConfigError is the annotation of commands which run even if the
+// config file can't be loaded, reporting the error with reportConfigError
+const reportsConfigError = "reports-config-error"
+
+// configErr is the error loading the config file for commands annotated with
+// reportsConfigError
+var configErr error
+
+// loadConfig reads the config file into the environment before a command runs
+func loadConfig(cmd *cobra.Command, args []string) error {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return err
+	}
+
+	if err := envconfig.LoadConfig(path); err != nil {
+		if _, ok := cmd.Annotations[reportsConfigError]; !ok {
+			return err
+		}
+		configErr = err
+	}
+
+	return nil
+}
+
+// reportConfigError prints the error loading the config file, if there was one
+func reportConfigError(w io.Writer) {
+	if configErr != nil {
+		fmt.Fprintf(w, "Warning: config file not loaded: %v\n", configErr)
+	}
+}
+
+func ConfigShowHandler(cmd *cobra.Command, args []string) error {
+	reportConfigError(cmd.ErrOrStderr())
+	printConfig(cmd.OutOrStdout(), envconfig.AsMap())
+	return nil
+}
+
+// printConfig prints the effective value of each setting and where it's set
+func printConfig(w io.Writer, vars map[string]envconfig.EnvVar) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var data [][]string
+	for _, name := range names {
+		data = append(data, []string{name, fmt.Sprint(vars[name].Value), envconfig.Source(name)})
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"NAME", "VALUE", "SOURCE"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetNoWhiteSpace(true)
+	table.SetTablePadding("    ")
+	table.SetAutoWrapText(false)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/envconfig"
+)
+
+func TestPrintConfig(t *testing.T) {
+	t.Setenv("OLLAMA_NUM_PARALLEL", "2")
+	t.Setenv("OLLAMA_NOHISTORY", "")
+
+	var b bytes.Buffer
+	printConfig(&b, map[string]envconfig.EnvVar{
+		"OLLAMA_NUM_PARALLEL": {Name: "OLLAMA_NUM_PARALLEL", Value: 2},
+		"OLLAMA_NOHISTORY":    {Name: "OLLAMA_NOHISTORY", Value: false},
+	})
+
+	assert.Equal(t, `NAME                   VALUE    SOURCE  
+OLLAMA_NOHISTORY       false    default    
+OLLAMA_NUM_PARALLEL    2        env        
+`, b.String())
+}
+
+func TestLoadConfigReportsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`not_a_setting = 1`), 0o644))
+	t.Cleanup(func() { configErr = nil })
+
+	newCmd := func(annotations map[string]string) *cobra.Command {
+		cmd := &cobra.Command{Annotations: annotations}
+		cmd.Flags().String("config", "", "")
+		require.NoError(t, cmd.Flags().Set("config", path))
+		return cmd
+	}
+
+	assert.ErrorContains(t, loadConfig(newCmd(nil), nil), "unknown setting 'not_a_setting'")
+	assert.NoError(t, configErr)
+
+	require.NoError(t, loadConfig(newCmd(map[string]string{reportsConfigError: ""}), nil))
+
+	var b bytes.Buffer
+	reportConfigError(&b)
+	assert.Contains(t, b.String(), "unknown setting 'not_a_setting'")
+}
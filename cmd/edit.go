@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+var errNoUserMessage = errors.New("there are no messages to retry")
+
+// userTurns returns the indexes in msgs of the messages the user sent. Turn N
+// in /edit N is the message at userTurns(msgs)[N-1].
+func userTurns(msgs []api.Message) []int {
+	var turns []int
+	for i, m := range msgs {
+		if m.Role == "user" {
+			turns = append(turns, i)
+		}
+	}
+	return turns
+}
+
+// undoTurn removes the last message the user sent along with the responses to it
+func undoTurn(msgs []api.Message) ([]api.Message, bool) {
+	turns := userTurns(msgs)
+	if len(turns) == 0 {
+		return msgs, false
+	}
+
+	return msgs[:turns[len(turns)-1]], true
+}
+
+// retryMessages removes the responses to the last message the user sent so it
+// can be sent again
+func retryMessages(msgs []api.Message) ([]api.Message, error) {
+	turns := userTurns(msgs)
+	if len(turns) == 0 {
+		return msgs, errNoUserMessage
+	}
+
+	return msgs[:turns[len(turns)-1]+1], nil
+}
+
+// editTurn returns the index of turn n, counting from 1, or the last turn if n
+// is 0
+func editTurn(msgs []api.Message, n int) (int, error) {
+	turns := userTurns(msgs)
+	switch {
+	case len(turns) == 0:
+		return 0, errors.New("there are no messages to edit")
+	case n == 0:
+		return turns[len(turns)-1], nil
+	case n < 0 || n > len(turns):
+		return 0, fmt.Errorf("there is no message %d, choose one from 1 to %d", n, len(turns))
+	}
+
+	return turns[n-1], nil
+}
+
+// retryOptions returns the options to retry with, which are the options of
+// the conversation overridden by the key value pairs in args
+func retryOptions(options map[string]any, args []string) (map[string]any, error) {
+	if len(args)%2 != 0 {
+		return nil, errors.New("parameters must be given as name value pairs, e.g. /retry temperature 1.2")
+	}
+
+	params := make(map[string][]string)
+	for i := 0; i < len(args); i += 2 {
+		params[args[i]] = append(params[args[i]], args[i+1])
+	}
+
+	fp, err := api.FormatParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := maps.Clone(options)
+	if retry == nil {
+		retry = map[string]any{}
+	}
+	maps.Copy(retry, fp)
+	return retry, nil
+}
+
+// editor returns the command to edit files with from $VISUAL or $EDITOR
+func editor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.TrimSpace(os.Getenv(env)); e != "" {
+			return e
+		}
+	}
+	return ""
+}
+
+// editText opens text in the user's editor and returns it once the editor exits
+func editText(text string) (string, error) {
+	f, err := os.CreateTemp("", "ollama-edit-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	// the editor may include arguments, e.g. "code --wait"
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/c"
+	}
+
+	c := exec.Command(shell, flag, editor()+` "$0"`, f.Name())
+	if runtime.GOOS == "windows" {
+		c = exec.Command(shell, flag, editor()+` "`+f.Name()+`"`)
+	}
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("editor: %w", err)
+	}
+
+	bts, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(bts), "\n"), nil
+}
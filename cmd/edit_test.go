@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestEditMessages(t *testing.T) {
+	msgs := []api.Message{
+		{Role: "system", Content: "You are a pirate."},
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "ahoy"},
+		{Role: "user", Content: "what's the weather?"},
+		{Role: "assistant", Content: "", ToolCalls: []api.ToolCall{{Function: api.ToolCallFunction{Name: "weather"}}}},
+		{Role: "tool", Content: "sunny"},
+		{Role: "assistant", Content: "sunny, matey"},
+	}
+
+	assert.Equal(t, []int{1, 3}, userTurns(msgs))
+
+	t.Run("undo", func(t *testing.T) {
+		undone, ok := undoTurn(msgs)
+		require.True(t, ok)
+		assert.Equal(t, msgs[:3], undone)
+
+		undone, ok = undoTurn(undone)
+		require.True(t, ok)
+		assert.Equal(t, msgs[:1], undone)
+
+		_, ok = undoTurn(undone)
+		assert.False(t, ok)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		retry, err := retryMessages(msgs)
+		require.NoError(t, err)
+		assert.Equal(t, msgs[:4], retry)
+
+		// the last message was sent but has no response yet
+		retry, err = retryMessages(retry)
+		require.NoError(t, err)
+		assert.Equal(t, msgs[:4], retry)
+
+		_, err = retryMessages(msgs[:1])
+		assert.ErrorIs(t, err, errNoUserMessage)
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		i, err := editTurn(msgs, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, i)
+
+		i, err = editTurn(msgs, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, i)
+
+		_, err = editTurn(msgs, 3)
+		assert.EqualError(t, err, "there is no message 3, choose one from 1 to 2")
+
+		_, err = editTurn(msgs[:1], 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestRetryOptions(t *testing.T) {
+	options := map[string]any{"temperature": float32(0.2), "num_ctx": 4096}
+
+	retry, err := retryOptions(options, []string{"temperature", "1.2", "stop", "###"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"temperature": float32(1.2), "num_ctx": 4096, "stop": []string{"###"}}, retry)
+
+	// the options of the conversation are unchanged
+	assert.Equal(t, float32(0.2), options["temperature"])
+
+	retry, err = retryOptions(nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, retry)
+
+	_, err = retryOptions(options, []string{"temperature"})
+	assert.Error(t, err)
+
+	_, err = retryOptions(options, []string{"not_a_parameter", "1"})
+	assert.Error(t, err)
+}
+
+func TestEditText(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sed as the editor")
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/world/there/")
+
+	edited, err := editText("hello world\n")
+	require.NoError(t, err)
+	assert.Equal(t, "hello there", edited)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"cmp"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -28,17 +30,33 @@ const (
 	MultilineSystem
 )
 
+// interactiveHelp is the list of commands /help shows, which the commands
+// completed in the REPL are also taken from
+var interactiveHelp = []struct {
+	usage       string
+	description string
+}{
+	{"/set", "Set session variables"},
+	{"/show", "Show model information"},
+	{"/load <model>", "Load a session or model"},
+	{"/save <model>", "Save your current session"},
+	{"/session", "Save, load and export conversations"},
+	{"/attach", "Attach files to the next message"},
+	{"/retry", "Regenerate the last response"},
+	{"/edit [n]", "Edit a message and continue from it"},
+	{"/undo", "Remove the last message and its response"},
+	{"/clear", "Clear session context"},
+	{"/bye", "Exit"},
+	{"/?, /help", "Help for a command"},
+	{"/? shortcuts", "Help for keyboard shortcuts"},
+}
+
 func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 	usage := func() {
 		fmt.Fprintln(os.Stderr, "Available Commands:")
-		fmt.Fprintln(os.Stderr, "  /set            Set session variables")
-		fmt.Fprintln(os.Stderr, "  /show           Show model information")
-		fmt.Fprintln(os.Stderr, "  /load <model>   Load a session or model")
-		fmt.Fprintln(os.Stderr, "  /save <model>   Save your current session")
-		fmt.Fprintln(os.Stderr, "  /clear          Clear session context")
-		fmt.Fprintln(os.Stderr, "  /bye            Exit")
-		fmt.Fprintln(os.Stderr, "  /?, /help       Help for a command")
-		fmt.Fprintln(os.Stderr, "  /? shortcuts    Help for keyboard shortcuts")
+		for _, c := range interactiveHelp {
+			fmt.Fprintf(os.Stderr, "  %-16s%s\n", c.usage, c.description)
+		}
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Use \"\"\" to begin a multi-line message.")
 
@@ -57,6 +75,8 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 		fmt.Fprintln(os.Stderr, "  /set nohistory         Disable history")
 		fmt.Fprintln(os.Stderr, "  /set wordwrap          Enable wordwrap")
 		fmt.Fprintln(os.Stderr, "  /set nowordwrap        Disable wordwrap")
+		fmt.Fprintln(os.Stderr, "  /set markdown          Render Markdown in responses")
+		fmt.Fprintln(os.Stderr, "  /set nomarkdown        Show responses as plain text")
 		fmt.Fprintln(os.Stderr, "  /set format json       Enable JSON mode")
 		fmt.Fprintln(os.Stderr, "  /set noformat          Disable formatting")
 		fmt.Fprintln(os.Stderr, "  /set verbose           Show LLM stats")
@@ -73,6 +93,8 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 		fmt.Fprintln(os.Stderr, "  Ctrl + k            Delete the sentence after the cursor")
 		fmt.Fprintln(os.Stderr, "  Ctrl + u            Delete the sentence before the cursor")
 		fmt.Fprintln(os.Stderr, "  Ctrl + w            Delete the word before the cursor")
+		fmt.Fprintln(os.Stderr, "  Ctrl + r            Search the history")
+		fmt.Fprintln(os.Stderr, "       Tab            Complete commands, parameters and model names")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "  Ctrl + l            Clear the screen")
 		fmt.Fprintln(os.Stderr, "  Ctrl + c            Stop the model from responding")
@@ -91,6 +113,38 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 		fmt.Fprintln(os.Stderr, "")
 	}
 
+	usageSession := func() {
+		fmt.Fprintln(os.Stderr, "Available Commands:")
+		fmt.Fprintln(os.Stderr, "  /session save [name]               Save the conversation and keep saving it after each response")
+		fmt.Fprintln(os.Stderr, "  /session load <name>               Resume a saved conversation")
+		fmt.Fprintln(os.Stderr, "  /session list                      List saved conversations")
+		fmt.Fprintln(os.Stderr, "  /session export <file> [format]    Export the conversation as json, openai or markdown")
+		fmt.Fprintln(os.Stderr, "")
+	}
+
+	usageAttach := func() {
+		fmt.Fprintln(os.Stderr, "Available Commands:")
+		fmt.Fprintln(os.Stderr, "  /attach <path>            Attach a text file, directory or PDF to the next message")
+		fmt.Fprintln(os.Stderr, "  /attach list              List attachments")
+		fmt.Fprintln(os.Stderr, "  /attach remove <n|path>   Remove an attachment")
+		fmt.Fprintln(os.Stderr, "  /attach clear             Remove all attachments")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Directories are attached without files ignored by .gitignore. Paths dragged")
+		fmt.Fprintln(os.Stderr, "into the terminal are attached too.")
+		fmt.Fprintln(os.Stderr, "")
+	}
+
+	usageRetry := func() {
+		fmt.Fprintln(os.Stderr, "Available Commands:")
+		fmt.Fprintln(os.Stderr, "  /retry                        Regenerate the last response")
+		fmt.Fprintln(os.Stderr, "  /retry <parameter> <value>    Regenerate it with different parameters, e.g. /retry temperature 1.2")
+		fmt.Fprintln(os.Stderr, "  /edit [n]                     Edit message n, or the last message, and discard the messages after it")
+		fmt.Fprintln(os.Stderr, "  /undo                         Remove the last message and its response")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Messages are edited in $VISUAL or $EDITOR if set, otherwise on the prompt.")
+		fmt.Fprintln(os.Stderr, "")
+	}
+
 	// only list out the most common parameters
 	usageParameters := func() {
 		fmt.Fprintln(os.Stderr, "Available Parameters:")
@@ -122,12 +176,84 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 		scanner.HistoryDisable()
 	}
 
+	scanner.Completer = readline.CompleterFunc(func(line string) []string {
+		if scanner.Prompt.UseAlt {
+			return nil
+		}
+
+		models := func() []string {
+			client, err := api.ClientFromEnvironment()
+			if err != nil {
+				return nil
+			}
+
+			resp, err := client.List(cmd.Context())
+			if err != nil {
+				return nil
+			}
+
+			var names []string
+			for _, m := range resp.Models {
+				names = append(names, m.Name)
+			}
+
+			return names
+		}
+
+		sessions := func() []string {
+			names, _ := sessionNames()
+			return names
+		}
+
+		return completeInteractive(line, models, sessions)
+	})
+
 	fmt.Print(readline.StartBracketedPaste)
 	defer fmt.Printf(readline.EndBracketedPaste)
 
 	var sb strings.Builder
 	var multiline MultilineState
 
+	// attachments are added to the next message which is sent
+	var attachments []*attachment
+	attach := func(attachments []*attachment, p string) []*attachment {
+		attachments, err := addAttachment(cmd.Context(), attachments, p, opts)
+		if err != nil {
+			fmt.Printf("Couldn't attach '%s': %v\n", p, err)
+			return attachments
+		}
+
+		a := attachments[len(attachments)-1]
+		if a.Skipped > 0 {
+			fmt.Printf("Attached '%s' (%d file(s), %d tokens, skipped %d binary or large file(s))\n", a.Path, a.Files, a.Tokens, a.Skipped)
+		} else {
+			fmt.Printf("Attached '%s' (%d file(s), %d tokens)\n", a.Path, a.Files, a.Tokens)
+		}
+		return attachments
+	}
+
+	// send chats with the messages so far and adds the response to them
+	send := func(o runOptions) error {
+		assistant, err := chat(cmd, o)
+		if err != nil {
+			return err
+		}
+		if assistant != nil {
+			opts.Messages = append(opts.Messages, *assistant)
+		}
+
+		if opts.Session != "" {
+			if err := saveSession(opts.Session, newSession(opts)); err != nil {
+				fmt.Printf("error: couldn't save session: %v\n", err)
+			}
+		}
+
+		return nil
+	}
+
+	// editing is the index of the message the next line replaces, or -1
+	editing := -1
+
 	for {
 		line, err := scanner.Readline()
 		switch {
@@ -141,6 +267,7 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 
 			scanner.Prompt.UseAlt = false
 			sb.Reset()
+			editing = -1
 
 			continue
 		case err != nil:
@@ -161,6 +288,7 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 			case MultilineSystem:
 				opts.System = sb.String()
 				opts.Messages = append(opts.Messages, api.Message{Role: "system", Content: opts.System})
+				editing = -1
 				fmt.Println("Set system message.")
 				sb.Reset()
 			}
@@ -193,6 +321,8 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 			}
 			opts.Model = args[1]
 			opts.Messages = []api.Message{}
+			opts.Session = ""
+			editing = -1
 			fmt.Printf("Loading model '%s'\n", opts.Model)
 			if err := loadOrUnloadModel(cmd, &opts); err != nil {
 				return err
@@ -223,8 +353,90 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 			}
 			fmt.Printf("Created new model '%s'\n", args[1])
 			continue
+		case strings.HasPrefix(line, "/session"):
+			args := strings.Fields(line)
+			if len(args) < 2 {
+				usageSession()
+				continue
+			}
+
+			switch args[1] {
+			case "save":
+				name := opts.Session
+				if len(args) > 2 {
+					name = args[2]
+				}
+
+				if name == "" {
+					fmt.Println("Usage:\n  /session save <name>")
+					continue
+				}
+
+				if err := saveSession(name, newSession(opts)); err != nil {
+					fmt.Printf("error: couldn't save session: %v\n", err)
+					continue
+				}
+
+				opts.Session = name
+				fmt.Printf("Saved session '%s'\n", name)
+			case "load":
+				if len(args) != 3 {
+					fmt.Println("Usage:\n  /session load <name>")
+					continue
+				}
+
+				s, err := loadSession(args[2])
+				if errors.Is(err, os.ErrNotExist) {
+					fmt.Printf("error: session '%s' not found\n", args[2])
+					continue
+				} else if err != nil {
+					fmt.Printf("error: couldn't load session: %v\n", err)
+					continue
+				}
+
+				s.apply(&opts)
+				opts.Session = args[2]
+				editing = -1
+				fmt.Printf("Loading session '%s'\n", opts.Session)
+				if err := loadOrUnloadModel(cmd, &opts); err != nil {
+					return err
+				}
+
+				displayMessages(opts.Messages, opts)
+			case "list":
+				if err := listSessions(os.Stdout); err != nil {
+					return err
+				}
+			case "export":
+				if len(args) < 3 || len(args) > 4 {
+					fmt.Println("Usage:\n  /session export <file> [json|openai|markdown]")
+					continue
+				}
+
+				format := exportFormat(args[2])
+				if len(args) == 4 {
+					format = args[3]
+				}
+
+				var buf bytes.Buffer
+				if err := exportSession(&buf, newSession(opts), format); err != nil {
+					fmt.Printf("error: %v\n", err)
+					continue
+				}
+
+				if err := os.WriteFile(args[2], buf.Bytes(), 0o644); err != nil {
+					fmt.Printf("error: couldn't export session: %v\n", err)
+					continue
+				}
+
+				fmt.Printf("Exported session to '%s'\n", args[2])
+			default:
+				usageSession()
+			}
+			continue
 		case strings.HasPrefix(line, "/clear"):
 			opts.Messages = []api.Message{}
+			editing = -1
 			if opts.System != "" {
 				newMessage := api.Message{Role: "system", Content: opts.System}
 				opts.Messages = append(opts.Messages, newMessage)
@@ -245,6 +457,12 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 				case "nowordwrap":
 					opts.WordWrap = false
 					fmt.Println("Set 'nowordwrap' mode.")
+				case "markdown":
+					opts.Markdown = true
+					fmt.Println("Set 'markdown' mode.")
+				case "nomarkdown":
+					opts.Markdown = false
+					fmt.Println("Set 'nomarkdown' mode.")
 				case "verbose":
 					if err := cmd.Flags().Set("verbose", "true"); err != nil {
 						return err
@@ -313,6 +531,7 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 					} else {
 						opts.Messages = append(opts.Messages, newMessage)
 					}
+					editing = -1
 					fmt.Println("Set system message.")
 					sb.Reset()
 					continue
@@ -395,6 +614,12 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 					usageSet()
 				case "show", "/show":
 					usageShow()
+				case "session", "/session":
+					usageSession()
+				case "attach", "/attach":
+					usageAttach()
+				case "retry", "/retry", "edit", "/edit", "undo", "/undo":
+					usageRetry()
 				case "shortcut", "shortcuts":
 					usageShortcuts()
 				}
@@ -403,6 +628,112 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 			}
 		case strings.HasPrefix(line, "/exit"), strings.HasPrefix(line, "/bye"):
 			return nil
+		case strings.HasPrefix(line, "/attach"):
+			arg := strings.TrimSpace(strings.TrimPrefix(line, "/attach"))
+			sub, rest, _ := strings.Cut(arg, " ")
+			switch sub {
+			case "":
+				usageAttach()
+			case "list":
+				if len(attachments) == 0 {
+					fmt.Println("Nothing is attached.")
+				}
+
+				for i, a := range attachments {
+					fmt.Printf("%d. %s (%d file(s), %d tokens)\n", i+1, a.Path, a.Files, a.Tokens)
+				}
+			case "remove":
+				if attachments, err = removeAttachment(attachments, strings.TrimSpace(rest)); err != nil {
+					fmt.Println(err)
+				}
+			case "clear":
+				attachments = nil
+				fmt.Println("Removed all attachments.")
+			default:
+				attachments = attach(attachments, expandPath(arg))
+			}
+			continue
+		case strings.HasPrefix(line, "/retry"):
+			messages, err := retryMessages(opts.Messages)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			// parameters given to /retry only apply to this response
+			retry := opts
+			if retry.Options, err = retryOptions(opts.Options, strings.Fields(line)[1:]); err != nil {
+				fmt.Printf("Couldn't retry: %v\n", err)
+				continue
+			}
+
+			opts.Messages = messages
+			retry.Messages = messages
+			editing = -1
+			if err := send(retry); err != nil {
+				return err
+			}
+			continue
+		case strings.HasPrefix(line, "/undo"):
+			var ok bool
+			if opts.Messages, ok = undoTurn(opts.Messages); !ok {
+				fmt.Println("There are no messages to undo.")
+				continue
+			}
+			editing = -1
+
+			if opts.Session != "" {
+				if err := saveSession(opts.Session, newSession(opts)); err != nil {
+					fmt.Printf("error: couldn't save session: %v\n", err)
+				}
+			}
+			fmt.Println("Removed the last message.")
+			continue
+		case strings.HasPrefix(line, "/edit"):
+			var n int
+			if args := strings.Fields(line); len(args) > 1 {
+				if n, err = strconv.Atoi(args[1]); err != nil {
+					fmt.Printf("Couldn't edit: '%s' is not a message number\n", args[1])
+					continue
+				}
+			}
+
+			i, err := editTurn(opts.Messages, n)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			content := opts.Messages[i].Content
+			if editor() == "" {
+				if strings.Contains(content, "\n") {
+					fmt.Println("Set $EDITOR to edit messages with more than one line.")
+					continue
+				}
+
+				// the edited message is sent when the line is entered
+				editing = i
+				scanner.Prefill(content)
+				continue
+			}
+
+			edited, err := editText(content)
+			if err != nil {
+				fmt.Printf("Couldn't edit: %v\n", err)
+				continue
+			}
+
+			if strings.TrimSpace(edited) == "" {
+				fmt.Println("The message is empty, nothing was changed.")
+				continue
+			}
+
+			editing = i
+			sb.WriteString(edited)
+		case !(opts.MultiModal && len(extractFileNames(line)) > 0) && droppedPath(line) != "":
+			// a path dragged into the terminal is attached rather than sent
+			attachments = attach(attachments, droppedPath(line))
+			continue
 		case strings.HasPrefix(line, "/"):
 			args := strings.Fields(line)
 			isFile := false
@@ -439,15 +770,25 @@ func generateInteractive(cmd *cobra.Command, opts runOptions) error {
 				newMessage.Images = images
 			}
 
+			if len(attachments) > 0 {
+				newMessage.Content = attachmentPrompt(attachments, newMessage.Content)
+				attachments = nil
+			}
+
+			// commands which change the messages stop the edit, but check the
+			// message is still there in case one doesn't
+			if editing >= 0 && editing < len(opts.Messages) {
+				// keep the images of the edited message and discard everything after it
+				newMessage.Images = slices.Concat(opts.Messages[editing].Images, newMessage.Images)
+				opts.Messages = opts.Messages[:editing]
+			}
+			editing = -1
+
 			opts.Messages = append(opts.Messages, newMessage)
 
-			assistant, err := chat(cmd, opts)
-			if err != nil {
+			if err := send(opts); err != nil {
 				return err
 			}
-			if assistant != nil {
-				opts.Messages = append(opts.Messages, *assistant)
-			}
 
 			sb.Reset()
 		}
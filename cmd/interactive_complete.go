@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// interactiveCommands returns the commands listed by /help, followed by
+// /exit which /help leaves out as it's the same as /bye
+func interactiveCommands() []string {
+	var commands []string
+	for _, c := range interactiveHelp {
+		for _, usage := range strings.Split(c.usage, ", ") {
+			if name := strings.Fields(usage)[0]; !slices.Contains(commands, name) {
+				commands = append(commands, name)
+			}
+		}
+	}
+
+	return append(commands, "/exit")
+}
+
+var interactiveSubcommands = map[string][]string{
+	"/set":     {"parameter", "system", "history", "nohistory", "wordwrap", "nowordwrap", "markdown", "nomarkdown", "format", "noformat", "verbose", "quiet"},
+	"/show":    {"info", "license", "modelfile", "parameters", "system", "template"},
+	"/session": {"save", "load", "list", "export"},
+	"/attach":  {"list", "remove", "clear"},
+	"/help":    {"set", "show", "session", "attach", "retry", "edit", "undo", "shortcuts"},
+	"/?":       {"set", "show", "session", "attach", "retry", "edit", "undo", "shortcuts"},
+}
+
+// parameterNames returns the names of the options which can be set with
+// /set parameter
+func parameterNames() []string {
+	var names []string
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(api.Options{})) {
+		if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// completeInteractive returns the completions for a slash command typed in the
+// interactive REPL. models and sessions list the names that /load and
+// /session load complete to.
+func completeInteractive(line string, models, sessions func() []string) []string {
+	if !strings.HasPrefix(line, "/") {
+		return nil
+	}
+
+	// the last word is the one being completed, or an empty one if the line
+	// ends in a space
+	args := strings.Fields(line)
+	word := ""
+	if !strings.HasSuffix(line, " ") {
+		word, args = args[len(args)-1], args[:len(args)-1]
+	}
+
+	var choices []string
+	switch {
+	case len(args) == 0:
+		choices = interactiveCommands()
+	case len(args) == 1 && args[0] == "/load":
+		choices = models()
+	case len(args)%2 == 1 && args[0] == "/retry":
+		// /retry takes name value pairs
+		choices = parameterNames()
+	case len(args) == 1:
+		choices = interactiveSubcommands[args[0]]
+	case len(args) == 2 && args[0] == "/set" && args[1] == "parameter":
+		choices = parameterNames()
+	case len(args) == 2 && args[0] == "/set" && args[1] == "format":
+		choices = []string{"json"}
+	case len(args) == 2 && args[0] == "/session" && args[1] == "load":
+		choices = sessions()
+	}
+
+	prefix := line[:len(line)-len(word)]
+
+	var candidates []string
+	for _, choice := range choices {
+		if strings.HasPrefix(choice, word) {
+			candidates = append(candidates, prefix+choice+" ")
+		}
+	}
+
+	return candidates
+}
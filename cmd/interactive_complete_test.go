@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteInteractive(t *testing.T) {
+	models := func() []string { return []string{"llama3.2:latest", "llava:latest", "mistral:latest"} }
+	sessions := func() []string { return []string{"pirate"} }
+
+	cases := []struct {
+		line   string
+		expect []string
+	}{
+		{"hello", nil},
+		{"/se", []string{"/set ", "/session "}},
+		{"/sho", []string{"/show "}},
+		{"/e", []string{"/edit ", "/exit "}},
+		{"/u", []string{"/undo "}},
+		{"/", []string{"/set ", "/show ", "/load ", "/save ", "/session ", "/attach ", "/retry ", "/edit ", "/undo ", "/clear ", "/bye ", "/? ", "/help ", "/exit "}},
+		{"/show t", []string{"/show template "}},
+		{"/set parameter temp", []string{"/set parameter temperature "}},
+		{"/set parameter num_c", []string{"/set parameter num_ctx "}},
+		{"/set format ", []string{"/set format json "}},
+		{"/load ll", []string{"/load llama3.2:latest ", "/load llava:latest "}},
+		{"/session load ", []string{"/session load pirate "}},
+		{"/? sh", []string{"/? show ", "/? shortcuts "}},
+		{"/help e", []string{"/help edit "}},
+		{"/set parameter temperature 0", nil},
+		{"/retry temp", []string{"/retry temperature "}},
+		{"/retry temperature 1.2 top_", []string{"/retry temperature 1.2 top_k ", "/retry temperature 1.2 top_p "}},
+		{"/retry temperature ", nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.line, func(t *testing.T) {
+			assert.Equal(t, tt.expect, completeInteractive(tt.line, models, sessions))
+		})
+	}
+}
@@ -0,0 +1,595 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+const (
+	styleReset     = "\x1b[0m"
+	styleBold      = "\x1b[1m"
+	styleItalic    = "\x1b[3m"
+	styleUnderline = "\x1b[4m"
+	styleGrey      = "\x1b[38;5;245m"
+	styleCode      = "\x1b[36m"
+	styleKeyword   = "\x1b[35m"
+	styleString    = "\x1b[32m"
+	styleNumber    = "\x1b[33m"
+)
+
+type markdownBlock int
+
+const (
+	// blockUnknown is the start of a line before enough of it has been seen
+	// to know what it is
+	blockUnknown markdownBlock = iota
+	blockParagraph
+	blockHeading
+	blockListItem
+	blockQuote
+	// the following blocks are rendered once the whole line has been seen
+	blockFence
+	blockCode
+	blockTable
+	blockRule
+)
+
+var (
+	headingRe     = regexp.MustCompile(`^(#{1,6}) `)
+	listItemRe    = regexp.MustCompile(`^([-*+]) `)
+	orderedItemRe = regexp.MustCompile(`^(\d+[.)]) `)
+	quoteRe       = regexp.MustCompile(`^> ?`)
+	ruleRe        = regexp.MustCompile(`^(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	tableSepRe    = regexp.MustCompile(`^:?-+:?$`)
+)
+
+// markdownMarkers are the characters which may start a block. A line is held
+// back until it contains something else so its type is known.
+const markdownMarkers = "#*-+_>|`~.)0123456789"
+
+// markdownRenderer renders Markdown to a terminal as it is streamed. Text is
+// written as soon as the type of the line it is on is known, while code blocks
+// are written a line at a time and tables once they are complete. Lines are
+// wrapped at width unless it is 0.
+type markdownRenderer struct {
+	w     io.Writer
+	width int
+
+	line  []rune
+	block markdownBlock
+
+	fence string
+	lang  string
+	table [][]string
+
+	// base is the style of the current block, e.g. bold for headings
+	base               string
+	bold, italic, code bool
+	// stars is the number of '*' which haven't been handled yet
+	stars int
+
+	col    int
+	indent int
+	spaces int
+	word   strings.Builder
+	// wordWidth is the display width of word, excluding escape codes
+	wordWidth int
+}
+
+func newMarkdownRenderer(w io.Writer, width int) *markdownRenderer {
+	return &markdownRenderer{w: w, width: width}
+}
+
+// responseDisplay writes a streamed response to stdout, either rendered as
+// Markdown or as plain text with word wrapping. Responses with a format are
+// never rendered since Markdown markers are part of the structured output.
+type responseDisplay struct {
+	wordWrap bool
+	state    displayResponseState
+	markdown *markdownRenderer
+}
+
+func newResponseDisplay(opts runOptions) *responseDisplay {
+	d := &responseDisplay{wordWrap: opts.WordWrap}
+	if opts.Markdown && opts.Format == "" {
+		var width int
+		if termWidth, _, _ := term.GetSize(int(os.Stdout.Fd())); opts.WordWrap && termWidth >= 10 {
+			width = termWidth - 5
+		}
+
+		d.markdown = newMarkdownRenderer(os.Stdout, width)
+	}
+
+	return d
+}
+
+func (d *responseDisplay) Write(content string) {
+	if d.markdown != nil {
+		d.markdown.Write(content)
+	} else {
+		displayResponse(content, d.wordWrap, &d.state)
+	}
+}
+
+// Flush writes any part of the response which is held back for rendering
+func (d *responseDisplay) Flush() {
+	if d.markdown != nil {
+		d.markdown.Flush()
+	}
+}
+
+func (m *markdownRenderer) Write(s string) {
+	for _, r := range s {
+		m.writeRune(r)
+	}
+}
+
+// Flush writes anything which is held back, e.g. an incomplete line or table,
+// at the end of the response
+func (m *markdownRenderer) Flush() {
+	if m.block == blockUnknown && len(m.line) > 0 {
+		m.startLine()
+	}
+
+	switch m.block {
+	case blockFence, blockCode, blockTable, blockRule:
+		m.endLine(false)
+	case blockUnknown:
+	default:
+		m.flushStars()
+		m.flushWord()
+		if m.styled() {
+			fmt.Fprint(m.w, styleReset)
+		}
+	}
+
+	if m.table != nil {
+		m.renderTable()
+	}
+
+	m.reset()
+}
+
+func (m *markdownRenderer) writeRune(r rune) {
+	switch m.block {
+	case blockUnknown:
+		m.line = append(m.line, r)
+		if r == '\n' || m.fence != "" || m.known() {
+			m.startLine()
+		}
+	case blockFence, blockCode, blockTable, blockRule:
+		if r == '\n' {
+			m.endLine(true)
+		} else {
+			m.line = append(m.line, r)
+		}
+	default:
+		m.inline(r)
+	}
+}
+
+// known reports whether enough of the current line has been seen to know its type
+func (m *markdownRenderer) known() bool {
+	trimmed := strings.TrimLeft(string(m.line), " \t")
+	return strings.ContainsFunc(trimmed, func(r rune) bool {
+		return !strings.ContainsRune(markdownMarkers, r)
+	})
+}
+
+func (m *markdownRenderer) startLine() {
+	line := string(m.line)
+	complete := strings.HasSuffix(line, "\n")
+	trimmed := strings.TrimLeft(strings.TrimSuffix(line, "\n"), " \t")
+	leading := len(line) - len(strings.TrimLeft(line, " \t"))
+
+	switch {
+	case m.fence != "":
+		m.block = blockCode
+	case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+		m.block = blockFence
+	case strings.HasPrefix(trimmed, "|"):
+		m.block = blockTable
+	case complete && ruleRe.MatchString(trimmed):
+		m.block = blockRule
+	}
+
+	if m.block != blockTable && m.table != nil {
+		m.renderTable()
+	}
+
+	if m.block != blockUnknown {
+		if complete {
+			m.line = m.line[:len(m.line)-1]
+			m.endLine(true)
+		}
+		return
+	}
+
+	m.line = nil
+	m.indent = leading
+
+	var rest string
+	if match := headingRe.FindStringSubmatch(trimmed); match != nil {
+		m.block = blockHeading
+		m.base = styleBold
+		if len(match[1]) == 1 {
+			m.base += styleUnderline
+		}
+		m.restyle()
+		rest = trimmed[len(match[0]):]
+	} else if match := listItemRe.FindStringSubmatch(trimmed); match != nil {
+		m.block = blockListItem
+		m.writeString(strings.Repeat(" ", leading) + "• ")
+		m.indent = leading + 2
+		rest = trimmed[len(match[0]):]
+	} else if match := orderedItemRe.FindStringSubmatch(trimmed); match != nil {
+		m.block = blockListItem
+		m.writeString(strings.Repeat(" ", leading) + match[0])
+		m.indent = leading + len(match[0])
+		rest = trimmed[len(match[0]):]
+	} else if match := quoteRe.FindString(trimmed); match != "" {
+		m.block = blockQuote
+		m.writeStyled(styleGrey, "│ ")
+		m.indent = 2
+		rest = trimmed[len(match):]
+	} else {
+		m.block = blockParagraph
+		m.writeString(strings.Repeat(" ", leading))
+		rest = trimmed
+	}
+
+	if complete {
+		rest += "\n"
+	}
+
+	for _, r := range rest {
+		m.inline(r)
+	}
+}
+
+// endLine renders a line which is only written once it is complete
+func (m *markdownRenderer) endLine(newline bool) {
+	line := string(m.line)
+	trimmed := strings.TrimSpace(line)
+
+	var out string
+	switch m.block {
+	case blockFence:
+		m.fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		m.lang = strings.ToLower(strings.TrimSpace(trimmed[len(m.fence):]))
+		out = styleGrey + line + styleReset
+	case blockCode:
+		if m.fence != "" && strings.HasPrefix(trimmed, m.fence) && strings.Trim(trimmed, m.fence[:1]) == "" {
+			m.fence, m.lang = "", ""
+			out = styleGrey + line + styleReset
+		} else {
+			out = highlight(line, m.lang)
+		}
+	case blockTable:
+		m.table = append(m.table, tableCells(trimmed))
+	case blockRule:
+		out = styleGrey + strings.Repeat("─", cmp.Or(min(m.width, 80), 40)) + styleReset
+	}
+
+	if out != "" || (newline && m.block != blockTable) {
+		if newline {
+			out += "\n"
+		}
+		fmt.Fprint(m.w, out)
+	}
+
+	m.reset()
+}
+
+func (m *markdownRenderer) reset() {
+	m.line = nil
+	m.block = blockUnknown
+	m.base = ""
+	m.bold, m.italic, m.code = false, false, false
+	m.stars = 0
+	m.col, m.indent, m.spaces = 0, 0, 0
+}
+
+// inline renders the text of paragraphs, headings, list items and quotes
+func (m *markdownRenderer) inline(r rune) {
+	if r == '\n' {
+		m.flushStars()
+		m.flushWord()
+		if m.styled() {
+			fmt.Fprint(m.w, styleReset)
+		}
+		fmt.Fprintln(m.w)
+		m.reset()
+		return
+	}
+
+	if m.code {
+		if r == '`' {
+			m.code = false
+			m.restyle()
+		} else {
+			m.emit(r)
+		}
+		return
+	}
+
+	if r == '*' && m.stars < 2 {
+		m.stars++
+		return
+	}
+
+	switch m.stars {
+	case 1:
+		m.toggle(&m.italic, "*", r)
+	case 2:
+		m.toggle(&m.bold, "**", r)
+	}
+
+	if r == '*' {
+		m.stars = 1
+		return
+	}
+
+	if r == '`' {
+		m.code = true
+		m.restyle()
+		return
+	}
+
+	m.emit(r)
+}
+
+// toggle turns a style on or off once its marker has been seen. A style is
+// only turned on if it is followed by text so e.g. "2 * 3" is left alone.
+func (m *markdownRenderer) toggle(style *bool, marker string, next rune) {
+	m.stars = 0
+	switch {
+	case *style:
+		*style = false
+		m.restyle()
+	case !unicode.IsSpace(next):
+		*style = true
+		m.restyle()
+	default:
+		for _, r := range marker {
+			m.emit(r)
+		}
+	}
+}
+
+// flushStars handles '*' at the end of a line
+func (m *markdownRenderer) flushStars() {
+	switch m.stars {
+	case 1:
+		m.toggle(&m.italic, "*", ' ')
+	case 2:
+		m.toggle(&m.bold, "**", ' ')
+	}
+}
+
+func (m *markdownRenderer) styled() bool {
+	return m.base != "" || m.bold || m.italic || m.code
+}
+
+func (m *markdownRenderer) restyle() {
+	style := styleReset + m.base
+	if m.bold {
+		style += styleBold
+	}
+	if m.italic {
+		style += styleItalic
+	}
+	if m.code {
+		style += styleCode
+	}
+	m.word.WriteString(style)
+}
+
+func (m *markdownRenderer) writeString(s string) {
+	for _, r := range s {
+		m.emit(r)
+	}
+}
+
+func (m *markdownRenderer) writeStyled(style, s string) {
+	m.word.WriteString(style)
+	m.writeString(s)
+	m.restyle()
+}
+
+// emit writes a visible rune, wrapping the line before the current word if
+// it doesn't fit
+func (m *markdownRenderer) emit(r rune) {
+	if r == ' ' && m.col+m.wordWidth > m.indent && !m.code {
+		m.flushWord()
+		m.spaces++
+		return
+	}
+
+	m.word.WriteRune(r)
+	m.wordWidth += runewidth.RuneWidth(r)
+	if m.width == 0 {
+		m.flushWord()
+	}
+}
+
+func (m *markdownRenderer) flushWord() {
+	if m.width > 0 && m.wordWidth > 0 && m.col > m.indent && m.col+m.spaces+m.wordWidth > m.width {
+		fmt.Fprint(m.w, "\n"+strings.Repeat(" ", m.indent))
+		m.col, m.spaces = m.indent, 0
+	}
+
+	if m.wordWidth > 0 || m.width == 0 {
+		fmt.Fprint(m.w, strings.Repeat(" ", m.spaces))
+		m.col += m.spaces
+		m.spaces = 0
+	}
+
+	fmt.Fprint(m.w, m.word.String())
+	m.col += m.wordWidth
+	m.word.Reset()
+	m.wordWidth = 0
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cell = strings.TrimSpace(cell)
+		cell = strings.ReplaceAll(cell, "**", "")
+		cells[i] = strings.ReplaceAll(cell, "`", "")
+	}
+
+	return cells
+}
+
+func (m *markdownRenderer) renderTable() {
+	rows := m.table
+	m.table = nil
+
+	var header []string
+	var align []string
+	if len(rows) > 1 && !slices.ContainsFunc(rows[1], func(s string) bool { return !tableSepRe.MatchString(s) }) {
+		header, align, rows = rows[0], rows[1], rows[2:]
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], runewidth.StringWidth(cell))
+		}
+	}
+
+	cell := func(i int, s string) string {
+		pad := widths[i] - runewidth.StringWidth(s)
+		if i < len(align) {
+			switch a := align[i]; {
+			case strings.HasPrefix(a, ":") && strings.HasSuffix(a, ":"):
+				return strings.Repeat(" ", pad/2) + s + strings.Repeat(" ", pad-pad/2)
+			case strings.HasSuffix(a, ":"):
+				return strings.Repeat(" ", pad) + s
+			}
+		}
+		return s + strings.Repeat(" ", pad)
+	}
+
+	if header != nil {
+		var sb strings.Builder
+		var rule []string
+		for i := range widths {
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			var s string
+			if i < len(header) {
+				s = header[i]
+			}
+			sb.WriteString(styleBold + cell(i, s) + styleReset)
+			rule = append(rule, strings.Repeat("─", widths[i]))
+		}
+		fmt.Fprintln(m.w, sb.String())
+		fmt.Fprintln(m.w, styleGrey+strings.Join(rule, "  ")+styleReset)
+	}
+
+	for _, row := range rows {
+		cells := make([]string, len(widths))
+		for i := range widths {
+			var s string
+			if i < len(row) {
+				s = row[i]
+			}
+			cells[i] = cell(i, s)
+		}
+		fmt.Fprintln(m.w, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+}
+
+var codeKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		break case catch class const continue default defer delete do else enum export extends
+		false finally fn for from func function go goto if impl import in interface let loop
+		match mod mut new nil None null package pub return select self static struct super
+		switch this throw true True False try type typeof use var void while yield async await
+		def elif except lambda pass raise with as is not and or fi then done esac echo local
+		int float double char bool string long short unsigned void println printf`) {
+		codeKeywords[k] = true
+	}
+}
+
+// hashCommentLangs are languages which use # for comments
+var hashCommentLangs = map[string]bool{
+	"python": true, "py": true, "sh": true, "bash": true, "shell": true, "zsh": true, "console": true,
+	"ruby": true, "rb": true, "yaml": true, "yml": true, "toml": true, "perl": true, "r": true,
+	"dockerfile": true, "makefile": true, "make": true, "ini": true, "conf": true,
+}
+
+// highlight colors keywords, strings, numbers and comments in a line of code.
+// It doesn't try to understand the language beyond which comments it uses.
+func highlight(line, lang string) string {
+	var sb strings.Builder
+	rs := []rune(line)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '#' && hashCommentLangs[lang],
+			r == '/' && i+1 < len(rs) && rs[i+1] == '/' && !hashCommentLangs[lang],
+			r == '-' && i+1 < len(rs) && rs[i+1] == '-' && (lang == "sql" || lang == "lua"):
+			sb.WriteString(styleGrey + string(rs[i:]) + styleReset)
+			return sb.String()
+		case r == '"' || r == '\'' || r == '`':
+			j := i + 1
+			for j < len(rs) && rs[j] != r {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(rs))
+			sb.WriteString(styleString + string(rs[i:j]) + styleReset)
+			i = j
+		case unicode.IsDigit(r) && (i == 0 || !isIdent(rs[i-1])):
+			j := i
+			for j < len(rs) && (isIdent(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			sb.WriteString(styleNumber + string(rs[i:j]) + styleReset)
+			i = j
+		case isIdent(r):
+			j := i
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			if word := string(rs[i:j]); codeKeywords[word] {
+				sb.WriteString(styleKeyword + word + styleReset)
+			} else {
+				sb.WriteString(word)
+			}
+			i = j
+		default:
+			sb.WriteRune(r)
+			i++
+		}
+	}
+
+	return sb.String()
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ansiRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func renderMarkdown(chunks []string, width int) string {
+	var b bytes.Buffer
+	m := newMarkdownRenderer(&b, width)
+	for _, chunk := range chunks {
+		m.Write(chunk)
+	}
+	m.Flush()
+	return b.String()
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		width  int
+		expect string
+	}{
+		{
+			name:   "heading",
+			input:  "## Hello **world**\nnext",
+			expect: "Hello world\nnext",
+		},
+		{
+			name:   "inline",
+			input:  "Use `a*b` with **bold**, *italic* and 2 * 3",
+			expect: "Use a*b with bold, italic and 2 * 3",
+		},
+		{
+			name:   "lists",
+			input:  "- one\n* two\n  3. three\n",
+			expect: "• one\n• two\n  3. three\n",
+		},
+		{
+			name:   "wrap",
+			input:  "- the quick brown fox jumps over the lazy dog\n",
+			width:  16,
+			expect: "• the quick\n  brown fox\n  jumps over the\n  lazy dog\n",
+		},
+		{
+			name:   "code",
+			input:  "```python\nif x: # not **bold**\n    return 'a b'\n```\nafter\n",
+			width:  10,
+			expect: "```python\nif x: # not **bold**\n    return 'a b'\n```\nafter\n",
+		},
+		{
+			name:   "table",
+			input:  "| name | size |\n|:-----|-----:|\n| a | 1 |\n| `longer` | 100 |\n\ndone",
+			expect: "name    size\n──────  ────\na          1\nlonger   100\n\ndone",
+		},
+		{
+			name:   "table at end",
+			input:  "| a | b |\n| 1 | 2 |",
+			expect: "a  b\n1  2\n",
+		},
+		{
+			name:   "rule and quote",
+			input:  "---\n> quoted\n",
+			width:  10,
+			expect: "──────────\n│ quoted\n",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			whole := renderMarkdown([]string{tt.input}, tt.width)
+			assert.Equal(t, tt.expect, ansiRe.ReplaceAllString(whole, ""))
+
+			// the output doesn't depend on how the response is streamed
+			assert.Equal(t, whole, renderMarkdown(strings.Split(tt.input, ""), tt.width))
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, styleKeyword+"return"+styleReset+" "+styleString+`"a\"b"`+styleReset+" "+styleGrey+"// done"+styleReset, highlight(`return "a\"b" // done`, "go"))
+	assert.Equal(t, "x = "+styleNumber+"1"+styleReset+" "+styleGrey+"# one"+styleReset, highlight("x = 1 # one", "python"))
+	assert.Equal(t, "v2 = a", highlight("v2 = a", ""))
+}
+
+func TestResponseDisplay(t *testing.T) {
+	assert.Nil(t, newResponseDisplay(runOptions{}).markdown, "Markdown is opt-in")
+	assert.NotNil(t, newResponseDisplay(runOptions{Markdown: true}).markdown)
+
+	for _, format := range []string{"json", `"json"`, `{"type":"object"}`} {
+		assert.Nil(t, newResponseDisplay(runOptions{Markdown: true, Format: format}).markdown, format)
+	}
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	errNotPDF    = errors.New("not a PDF file")
+	errNoPDFText = errors.New("no text found in PDF, it may be scanned or use fonts which can't be read")
+)
+
+// pdfStreamRe matches the dictionary of a stream object and the start of its
+// data. Dictionaries may contain one level of nested dictionaries.
+var pdfStreamRe = regexp.MustCompile(`<<((?:[^<>]|<<[^<>]*>>|<[^<>]*>)*)>>\s*stream\r?\n`)
+
+// pdfText extracts the text of a PDF. It only understands text drawn with
+// simple fonts in uncompressed or Flate compressed content streams, which
+// covers most PDFs exported from documents but not scanned ones.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errNotPDF
+	}
+
+	var sb strings.Builder
+	for _, loc := range pdfStreamRe.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+
+		stream := bytes.TrimRight(data[start:start+end], "\r\n")
+
+		// skip images, fonts and other streams which aren't page content
+		if strings.Contains(dict, "/Subtype/Image") || strings.Contains(dict, "/Subtype /Image") ||
+			strings.Contains(dict, "/Length1") || strings.Contains(dict, "/Type/XRef") || strings.Contains(dict, "/Type /XRef") ||
+			strings.Contains(dict, "/Type/ObjStm") || strings.Contains(dict, "/Type /ObjStm") ||
+			strings.Contains(dict, "/Type/Metadata") || strings.Contains(dict, "/Type /Metadata") {
+			continue
+		}
+
+		switch {
+		case strings.Contains(dict, "/FlateDecode"):
+			r, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+
+			// keep whatever could be decompressed from truncated streams
+			stream, _ = io.ReadAll(r)
+		case strings.Contains(dict, "/Filter"):
+			// other filters are used for images and aren't supported
+			continue
+		}
+
+		pdfContentText(&sb, stream)
+	}
+
+	text := strings.TrimSpace(pdfBlankLinesRe.ReplaceAllString(sb.String(), "\n\n"))
+	if text == "" {
+		return "", errNoPDFText
+	}
+
+	return text, nil
+}
+
+var pdfBlankLinesRe = regexp.MustCompile(`\n\s*\n+`)
+
+// pdfContentText writes the text drawn by the operators in a content stream
+func pdfContentText(sb *strings.Builder, content []byte) {
+	var operands []any
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+
+	for p := 0; p < len(content); {
+		c := content[p]
+		switch {
+		case c == '%':
+			for p < len(content) && content[p] != '\n' && content[p] != '\r' {
+				p++
+			}
+		case isPDFSpace(c), c == '[', c == ']', c == '{', c == '}':
+			// arrays are only used by TJ so their strings and numbers are
+			// collected as operands
+			p++
+		case c == '(':
+			var s []byte
+			s, p = pdfLiteralString(content, p)
+			operands = append(operands, s)
+		case c == '<' && p+1 < len(content) && content[p+1] == '<', c == '>' && p+1 < len(content) && content[p+1] == '>':
+			p += 2
+		case c == '<':
+			end := bytes.IndexByte(content[p:], '>')
+			if end < 0 {
+				return
+			}
+			operands = append(operands, pdfHexString(content[p+1:p+end]))
+			p += end + 1
+		case c == '/':
+			p++
+			for p < len(content) && !isPDFSpace(content[p]) && !isPDFDelimiter(content[p]) {
+				p++
+			}
+		default:
+			start := p
+			for p < len(content) && !isPDFSpace(content[p]) && !isPDFDelimiter(content[p]) {
+				p++
+			}
+			if p == start {
+				p++
+				continue
+			}
+
+			token := string(content[start:p])
+			if f, err := strconv.ParseFloat(token, 64); err == nil {
+				operands = append(operands, f)
+				continue
+			}
+
+			switch token {
+			case "Tj", "TJ", "'", "\"":
+				if token != "Tj" && token != "TJ" {
+					newline()
+				}
+
+				for _, op := range operands {
+					switch op := op.(type) {
+					case []byte:
+						sb.WriteString(pdfDecodeString(op))
+					case float64:
+						// large negative adjustments in TJ arrays separate words
+						if token == "TJ" && op < -200 {
+							sb.WriteByte(' ')
+						}
+					}
+				}
+			case "Td", "TD":
+				if len(operands) == 2 {
+					if ty, ok := operands[1].(float64); ok && ty != 0 {
+						newline()
+					}
+				}
+			case "T*", "ET", "Tm":
+				newline()
+			case "ID":
+				// skip inline image data
+				end := bytes.Index(content[p:], []byte("EI"))
+				if end < 0 {
+					return
+				}
+				p += end + 2
+			}
+
+			operands = operands[:0]
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// pdfLiteralString reads the string in parentheses starting at p and returns
+// it along with the position after it
+func pdfLiteralString(content []byte, p int) ([]byte, int) {
+	var s []byte
+	depth := 0
+	for p++; p < len(content); p++ {
+		c := content[p]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return s, p + 1
+			}
+			depth--
+		case '\\':
+			p++
+			if p >= len(content) {
+				return s, p
+			}
+
+			switch e := content[p]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// line continuation
+				if e == '\r' && p+1 < len(content) && content[p+1] == '\n' {
+					p++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for i := 0; i < 3 && p < len(content) && content[p] >= '0' && content[p] <= '7'; i++ {
+						n = n*8 + int(content[p]-'0')
+						p++
+					}
+					p--
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+
+	return s, p
+}
+
+func pdfHexString(hex []byte) []byte {
+	var digits []byte
+	for _, c := range hex {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	s := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return nil
+		}
+		s = append(s, byte(n))
+	}
+
+	return s
+}
+
+// pdfDecodeString decodes a string as UTF-16 if it has a byte order mark or
+// Latin-1 otherwise, dropping control characters
+func pdfDecodeString(s []byte) string {
+	var rs []rune
+	if bytes.HasPrefix(s, []byte{0xfe, 0xff}) {
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		rs = utf16.Decode(u)
+	} else {
+		for _, c := range s {
+			rs = append(rs, rune(c))
+		}
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, string(rs))
+}
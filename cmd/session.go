@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/format"
+)
+
+// session is a conversation saved from the interactive REPL
+type session struct {
+	Model     string         `json:"model"`
+	System    string         `json:"system,omitempty"`
+	Format    string         `json:"format,omitempty"`
+	Options   map[string]any `json:"options,omitempty"`
+	Messages  []api.Message  `json:"messages"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+var sessionNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+var errInvalidSessionName = errors.New("session names may only contain letters, numbers, '.', '_' and '-'")
+
+func sessionsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".ollama", "sessions"), nil
+}
+
+func sessionPath(name string) (string, error) {
+	if !sessionNameRe.MatchString(name) {
+		return "", errInvalidSessionName
+	}
+
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+".json"), nil
+}
+
+func newSession(opts runOptions) *session {
+	return &session{
+		Model:    opts.Model,
+		System:   opts.System,
+		Format:   opts.Format,
+		Options:  opts.Options,
+		Messages: opts.Messages,
+	}
+}
+
+// apply restores the session into opts
+func (s *session) apply(opts *runOptions) {
+	opts.Model = s.Model
+	opts.System = s.System
+	opts.Format = s.Format
+	opts.Options = s.Options
+	if opts.Options == nil {
+		opts.Options = map[string]any{}
+	}
+
+	opts.Messages = s.Messages
+	if opts.Messages == nil {
+		opts.Messages = []api.Message{}
+	}
+}
+
+func saveSession(name string, s *session) error {
+	p, err := sessionPath(name)
+	if err != nil {
+		return err
+	}
+
+	if existing, err := loadSession(name); err == nil {
+		s.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	now := time.Now()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	}
+	s.UpdatedAt = now
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	bts, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so an interrupted save doesn't lose the session
+	f, err := os.CreateTemp(filepath.Dir(p), name+".json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(bts); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+func loadSession(name string) (*session, error) {
+	p, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	bts, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var s session
+	if err := json.Unmarshal(bts, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+
+	return &s, nil
+}
+
+// sessionNames returns the names of the saved sessions
+func sessionNames() ([]string, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = strings.TrimSuffix(filepath.Base(match), ".json")
+	}
+
+	return names, nil
+}
+
+func listSessions(w io.Writer) error {
+	names, err := sessionNames()
+	if err != nil {
+		return err
+	}
+
+	var data [][]string
+	for _, name := range names {
+		s, err := loadSession(name)
+		if err != nil {
+			continue
+		}
+
+		data = append(data, []string{name, s.Model, fmt.Sprint(len(s.Messages)), format.HumanTime(s.UpdatedAt, "Never")})
+	}
+
+	if len(data) == 0 {
+		fmt.Fprintln(w, "No saved sessions.")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"NAME", "MODEL", "MESSAGES", "MODIFIED"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetNoWhiteSpace(true)
+	table.SetTablePadding("    ")
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// exportSession writes the session to w. The format is one of "json" for the
+// format sessions are saved in, "openai" for an array of OpenAI chat messages
+// or "markdown".
+func exportSession(w io.Writer, s *session, exportFormat string) error {
+	switch exportFormat {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(s)
+	case "openai":
+		messages, err := openAIMessages(s.Messages)
+		if err != nil {
+			return err
+		}
+
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(messages)
+	case "markdown", "md":
+		fmt.Fprintf(w, "# %s\n", s.Model)
+		for _, m := range s.Messages {
+			fmt.Fprintf(w, "\n## %s\n\n", m.Role)
+			if m.Content != "" {
+				fmt.Fprintln(w, m.Content)
+			}
+
+			for _, tc := range m.ToolCalls {
+				bts, err := json.Marshal(tc.Function)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(w, "\n```json\n%s\n```\n", bts)
+			}
+
+			if len(m.Images) > 0 {
+				fmt.Fprintf(w, "\n_%d image(s)_\n", len(m.Images))
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown export format '%s', use json, openai or markdown", exportFormat)
+	}
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIMessage struct {
+	Role      string           `json:"role"`
+	Content   any              `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// openAIMessages converts messages to the OpenAI chat completions format.
+// Images are inlined as data URLs.
+func openAIMessages(msgs []api.Message) ([]openAIMessage, error) {
+	messages := make([]openAIMessage, 0, len(msgs))
+	for i, m := range msgs {
+		message := openAIMessage{Role: m.Role, Content: m.Content}
+		if len(m.Images) > 0 {
+			parts := []openAIContentPart{{Type: "text", Text: m.Content}}
+			for _, img := range m.Images {
+				url := fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(img), base64.StdEncoding.EncodeToString(img))
+				parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+			}
+			message.Content = parts
+		}
+
+		for j, tc := range m.ToolCalls {
+			var call openAIToolCall
+			call.ID = fmt.Sprintf("call_%d_%d", i, j)
+			call.Type = "function"
+			call.Function.Name = tc.Function.Name
+			bts, err := json.Marshal(tc.Function.Arguments)
+			if err != nil {
+				return nil, err
+			}
+
+			call.Function.Arguments = string(bts)
+			message.ToolCalls = append(message.ToolCalls, call)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// exportFormat picks the export format from the file extension of path
+func exportFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return "markdown"
+	default:
+		return "json"
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestSaveLoadSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	opts := runOptions{
+		Model:   "llama3.2",
+		System:  "You are a pirate.",
+		Options: map[string]any{"temperature": 0.5},
+		Messages: []api.Message{
+			{Role: "system", Content: "You are a pirate."},
+			{Role: "user", Content: "Hello"},
+			{Role: "assistant", Content: "Ahoy!"},
+		},
+	}
+
+	require.NoError(t, saveSession("pirate", newSession(opts)))
+
+	first, err := loadSession("pirate")
+	require.NoError(t, err)
+
+	opts.Messages = append(opts.Messages, api.Message{Role: "user", Content: "Bye"})
+	require.NoError(t, saveSession("pirate", newSession(opts)))
+
+	s, err := loadSession("pirate")
+	require.NoError(t, err)
+	assert.Equal(t, first.CreatedAt, s.CreatedAt, "created time is kept when a session is saved again")
+
+	var restored runOptions
+	s.apply(&restored)
+	assert.Equal(t, opts.Model, restored.Model)
+	assert.Equal(t, opts.System, restored.System)
+	assert.Equal(t, opts.Options, restored.Options)
+	assert.Equal(t, opts.Messages, restored.Messages)
+
+	_, err = loadSession("missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.ErrorIs(t, saveSession("../escape", newSession(opts)), errInvalidSessionName)
+
+	var b bytes.Buffer
+	require.NoError(t, listSessions(&b))
+	assert.Contains(t, b.String(), "pirate")
+	assert.Contains(t, b.String(), "llama3.2")
+}
+
+func TestExportSession(t *testing.T) {
+	s := &session{
+		Model: "llava",
+		Messages: []api.Message{
+			{Role: "user", Content: "What's this?", Images: []api.ImageData{[]byte("\x89PNG\r\n\x1a\n")}},
+			{Role: "assistant", ToolCalls: []api.ToolCall{{Function: api.ToolCallFunction{Name: "describe", Arguments: api.ToolCallFunctionArguments{"detail": "high"}}}}},
+			{Role: "assistant", Content: "A picture."},
+		},
+	}
+
+	t.Run("openai", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, exportSession(&b, s, "openai"))
+
+		var messages []map[string]any
+		require.NoError(t, json.Unmarshal(b.Bytes(), &messages))
+		require.Len(t, messages, 3)
+
+		parts := messages[0]["content"].([]any)
+		require.Len(t, parts, 2)
+		assert.Equal(t, "What's this?", parts[0].(map[string]any)["text"])
+		assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", parts[1].(map[string]any)["image_url"].(map[string]any)["url"])
+
+		call := messages[1]["tool_calls"].([]any)[0].(map[string]any)
+		assert.Equal(t, "function", call["type"])
+		assert.Equal(t, `{"detail":"high"}`, call["function"].(map[string]any)["arguments"])
+
+		assert.Equal(t, "A picture.", messages[2]["content"])
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, exportSession(&b, s, exportFormat("chat.md")))
+		assert.Contains(t, b.String(), "# llava\n")
+		assert.Contains(t, b.String(), "\n## user\n\nWhat's this?\n\n_1 image(s)_\n")
+		assert.Contains(t, b.String(), `"name":"describe"`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, exportSession(&b, s, exportFormat("chat.json")))
+
+		var exported session
+		require.NoError(t, json.Unmarshal(b.Bytes(), &exported))
+		assert.Equal(t, s.Messages, exported.Messages)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		assert.Error(t, exportSession(&bytes.Buffer{}, s, "yaml"))
+	})
+}
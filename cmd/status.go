@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/format"
+)
+
+// formatLogRecord formats a record the same way the server writes it to its log
+func formatLogRecord(r api.LogRecord) string {
+	var sb strings.Builder
+	writeAttr := func(key, value string) {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		if value == "" || strings.ContainsFunc(value, func(r rune) bool {
+			return r == ' ' || r == '=' || r == '"' || !strconv.IsPrint(r)
+		}) {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(value)
+	}
+
+	writeAttr("time", r.Time.Format(time.RFC3339Nano))
+	writeAttr("level", r.Level)
+	if r.Source != "" {
+		writeAttr("source", r.Source)
+	}
+	writeAttr("msg", r.Message)
+	for _, attr := range r.Attrs {
+		writeAttr(attr.Key, attr.Value)
+	}
+
+	return sb.String()
+}
+
+func LogsHandler(cmd *cobra.Command, args []string) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	var req api.LogsRequest
+	if req.Follow, err = cmd.Flags().GetBool("follow"); err != nil {
+		return err
+	}
+
+	if req.Level, err = cmd.Flags().GetString("level"); err != nil {
+		return err
+	}
+
+	if req.Tail, err = cmd.Flags().GetInt("tail"); err != nil {
+		return err
+	}
+
+	// stop following on Ctrl-C
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	err = client.Logs(ctx, &req, func(r api.LogRecord) error {
+		fmt.Fprintln(cmd.OutOrStdout(), formatLogRecord(r))
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+func StatusHandler(cmd *cobra.Command, args []string) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Status(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	running, err := client.ListRunning(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	printStatus(w, status)
+
+	fmt.Fprintln(w, "Loaded models")
+	if len(running.Models) == 0 {
+		fmt.Fprintln(w, "  none")
+	} else {
+		printRunningModels(running.Models)
+	}
+
+	return nil
+}
+
+func printStatus(w io.Writer, status *api.StatusResponse) {
+	fmt.Fprintf(w, "Version:  %s\n", status.Version)
+	fmt.Fprintf(w, "Queued:   %d request(s)\n", status.Queued)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "GPUs")
+	for _, gpu := range status.GPUs {
+		name := gpu.Library
+		if gpu.Name != "" {
+			name = fmt.Sprintf("%s (%s)", gpu.Name, gpu.Library)
+		}
+
+		fmt.Fprintf(w, "  %s  %s", gpu.ID, name)
+		if gpu.Compute != "" {
+			fmt.Fprintf(w, "  compute %s", gpu.Compute)
+		}
+		if gpu.DriverVersion != "" {
+			fmt.Fprintf(w, "  driver %s", gpu.DriverVersion)
+		}
+		fmt.Fprintf(w, "  %s of %s free\n", format.HumanBytes2(gpu.FreeMemory), format.HumanBytes2(gpu.TotalMemory))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Environment")
+	keys := make([]string, 0, len(status.Env))
+	width := 0
+	for k := range status.Env {
+		keys = append(keys, k)
+		width = max(width, len(k))
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, k, status.Env[k])
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestFormatLogRecord(t *testing.T) {
+	r := api.LogRecord{
+		Time:    time.Date(2024, 12, 1, 10, 30, 0, 0, time.UTC),
+		Level:   "INFO",
+		Source:  "routes.go:1290",
+		Message: "Listening on 127.0.0.1:11434",
+		Attrs: []api.LogAttr{
+			{Key: "model", Value: "llama3.2"},
+			{Key: "error", Value: `unexpected "EOF"`},
+			{Key: "empty", Value: ""},
+		},
+	}
+
+	assert.Equal(t, `time=2024-12-01T10:30:00Z level=INFO source=routes.go:1290 msg="Listening on 127.0.0.1:11434" model=llama3.2 error="unexpected \"EOF\"" empty=""`, formatLogRecord(r))
+}
+
+func TestPrintStatus(t *testing.T) {
+	var b bytes.Buffer
+	printStatus(&b, &api.StatusResponse{
+		Version: "0.5.0",
+		Queued:  2,
+		GPUs: []api.GPUStatus{
+			{ID: "0", Name: "NVIDIA GeForce RTX 4090", Library: "cuda", Compute: "8.9", DriverVersion: "12.4", TotalMemory: 24 << 30, FreeMemory: 20 << 30},
+			{ID: "0", Library: "cpu", TotalMemory: 64 << 30, FreeMemory: 32 << 30},
+		},
+		Env: map[string]string{"OLLAMA_NUM_PARALLEL": "0", "OLLAMA_HOST": "http://127.0.0.1:11434"},
+	})
+
+	assert.Equal(t, `Version:  0.5.0
+Queued:   2 request(s)
+
+GPUs
+  0  NVIDIA GeForce RTX 4090 (cuda)  compute 8.9  driver 12.4  20.0 GiB of 24.0 GiB free
+  0  cpu  32.0 GiB of 64.0 GiB free
+
+Environment
+  OLLAMA_HOST          http://127.0.0.1:11434
+  OLLAMA_NUM_PARALLEL  0
+
+`, b.String())
+}
@@ -1,48 +1,613 @@
 package convert
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"math"
+	"path"
+	"slices"
 	"strings"
 
-	"github.com/nlpodyssey/gopickle/pytorch"
+	"github.com/d4l3k/go-bfloat16"
+	"github.com/nlpodyssey/gopickle/pickle"
 	"github.com/nlpodyssey/gopickle/types"
+	"github.com/x448/float16"
+	"golang.org/x/exp/maps"
 )
 
+// torchIndex is the index of a sharded checkpoint, e.g. pytorch_model.bin.index.json
+type torchIndex struct {
+	WeightMap map[string]string `json:"weight_map"`
+}
+
 func parseTorch(fsys fs.FS, replacer *strings.Replacer, ps ...string) ([]Tensor, error) {
-	var ts []Tensor
+	// if the checkpoint is sharded, the index decides which shard each tensor
+	// is read from. shards can contain copies of tied weights that the index
+	// does not point to.
+	var index torchIndex
+	if bts, err := fs.ReadFile(fsys, "pytorch_model.bin.index.json"); err == nil {
+		if err := json.Unmarshal(bts, &index); err != nil {
+			return nil, fmt.Errorf("pytorch_model.bin.index.json: %w", err)
+		}
+
+		ps = maps.Values(index.WeightMap)
+		slices.Sort(ps)
+		ps = slices.Compact(ps)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var config torchQuantizationConfig
+	if bts, err := fs.ReadFile(fsys, "config.json"); err == nil {
+		if err := json.Unmarshal(bts, &config); err != nil {
+			return nil, fmt.Errorf("config.json: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var selected []*torch
+	keys := make(map[string]struct{})
 	for _, p := range ps {
-		pt, err := pytorch.Load(p)
+		tensors, err := readTorchCheckpoint(fsys, p)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+
+		for _, t := range tensors {
+			if index.WeightMap != nil && index.WeightMap[t.key] != p {
+				continue
+			}
+
+			keys[t.key] = struct{}{}
+			selected = append(selected, t)
+		}
+	}
+
+	for key, p := range index.WeightMap {
+		if _, ok := keys[key]; !ok {
+			return nil, fmt.Errorf("tensor %s listed in index not found in %s", key, p)
+		}
+	}
+
+	selected, err := withTorchScales(selected, config.QuantizationConfig.WeightBlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var ts []Tensor
+	names := make(map[string]struct{})
+	for _, t := range selected {
+		t.name = replacer.Replace(t.key)
+		if _, ok := names[t.name]; ok {
+			return nil, fmt.Errorf("duplicate tensor name '%s' was found for this model", t.name)
+		}
+		names[t.name] = struct{}{}
+
+		ts = append(ts, t)
+	}
+
+	return ts, nil
+}
+
+// torchQuantizationConfig is the part of config.json that describes how FP8
+// weights are scaled
+type torchQuantizationConfig struct {
+	QuantizationConfig struct {
+		WeightBlockSize []int `json:"weight_block_size"`
+	} `json:"quantization_config"`
+}
+
+// torchScaleSuffixes are the suffixes added to the names of FP8 weights for
+// the tensors of scales they're multiplied by
+var torchScaleSuffixes = []string{"_scale", "_scale_inv"}
+
+// withTorchScales pairs FP8 tensors with the tensors of their scales, which
+// are removed from the tensors returned. Scales are either one per tensor,
+// one per row or, with blockSize, one per block.
+func withTorchScales(ts []*torch, blockSize []int) ([]*torch, error) {
+	byKey := make(map[string]*torch, len(ts))
+	for _, t := range ts {
+		byKey[t.key] = t
+	}
+
+	scales := make(map[string]bool)
+	for _, t := range ts {
+		if t.storage.dtype != "F8_E4M3" && t.storage.dtype != "F8_E5M2" {
+			continue
 		}
 
-		for _, k := range pt.(*types.Dict).Keys() {
-			t := pt.(*types.Dict).MustGet(k)
+		for _, suffix := range torchScaleSuffixes {
+			if scale, ok := byKey[t.key+suffix]; ok {
+				if err := t.setScale(scale, blockSize); err != nil {
+					return nil, fmt.Errorf("%s: %w", t.key, err)
+				}
 
-			var shape []uint64
-			for dim := range t.(*pytorch.Tensor).Size {
-				shape = append(shape, uint64(dim))
+				scales[scale.key] = true
+				break
 			}
+		}
+	}
+
+	return slices.DeleteFunc(ts, func(t *torch) bool { return scales[t.key] }), nil
+}
+
+// torchStorage describes a storage record of a checkpoint. Unlike gopickle's
+// storages, the data is not read until the tensor is written.
+type torchStorage struct {
+	dtype string
+	key   string
+}
+
+type torchStorageClass string
+
+// torchStorageClasses maps storage classes to the dtypes they hold
+var torchStorageClasses = map[string]torchStorageClass{
+	"FloatStorage":         "F32",
+	"DoubleStorage":        "F64",
+	"HalfStorage":          "F16",
+	"BFloat16Storage":      "BF16",
+	"Float8_e4m3fnStorage": "F8_E4M3",
+	"Float8_e5m2Storage":   "F8_E5M2",
+}
+
+type torchRebuildTensor struct{}
+
+func (torchRebuildTensor) Call(args ...any) (any, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("unexpected arguments for tensor: %v", args)
+	}
+
+	storage, ok := args[0].(torchStorage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected storage for tensor: %v", args[0])
+	}
 
-			ts = append(ts, torch{
-				storage: t.(*pytorch.Tensor).Source,
-				tensorBase: &tensorBase{
-					name:  replacer.Replace(k.(string)),
-					shape: shape,
-				},
-			})
+	offset, ok := args[1].(int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected offset for tensor: %v", args[1])
+	}
+
+	shape, err := torchTuple(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	stride, err := torchTuple(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	// tensors are written as is, so they must be laid out contiguously in their storage
+	expect := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		if shape[i] > 1 && stride[i] != expect {
+			return nil, errors.New("non-contiguous tensors are not supported")
+		}
+		expect *= shape[i]
+	}
+
+	t := &torch{storage: storage, offset: int64(offset), tensorBase: &tensorBase{}}
+	for _, dim := range shape {
+		t.shape = append(t.shape, uint64(dim))
+	}
+
+	return t, nil
+}
+
+// torchRebuildParameter unwraps a torch.nn.Parameter into its tensor
+type torchRebuildParameter struct{}
+
+func (torchRebuildParameter) Call(args ...any) (any, error) {
+	if len(args) < 1 {
+		return nil, errors.New("unexpected arguments for parameter")
+	}
+
+	return args[0], nil
+}
+
+func torchTuple(v any) ([]int, error) {
+	tuple, ok := v.(*types.Tuple)
+	if !ok {
+		return nil, fmt.Errorf("expected tuple, got %v", v)
+	}
+
+	ints := make([]int, tuple.Len())
+	for i := range ints {
+		n, ok := tuple.Get(i).(int)
+		if !ok {
+			return nil, fmt.Errorf("expected tuple of ints, got %v", v)
+		}
+		ints[i] = n
+	}
+
+	return ints, nil
+}
+
+// openTorchZip opens a checkpoint saved with torch.save which is a zip archive
+// containing the pickled state dict and one record per storage
+func openTorchZip(fsys fs.FS, p string) (*zip.Reader, io.Closer, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		// files that aren't seekable are small enough to be read into memory
+		bts, err := io.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
 		}
+
+		ra = bytes.NewReader(bts)
+	}
+
+	r, err := zip.NewReader(ra, fi.Size())
+	if err != nil {
+		f.Close()
+		if errors.Is(err, zip.ErrFormat) {
+			return nil, nil, errors.New("legacy pytorch checkpoints are not supported, re-save the model with torch.save")
+		}
+		return nil, nil, err
+	}
+
+	return r, f, nil
+}
+
+func torchRecords(r *zip.Reader) map[string]*zip.File {
+	records := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		_, name := path.Split(f.Name)
+		records[name] = f
+	}
+
+	return records
+}
+
+// readTorchCheckpoint reads the tensor names, shapes and storage locations of a
+// checkpoint without reading any of the tensor data
+func readTorchCheckpoint(fsys fs.FS, p string) ([]*torch, error) {
+	r, c, err := openTorchZip(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	records := torchRecords(r)
+	data, ok := records["data.pkl"]
+	if !ok {
+		return nil, errors.New("data.pkl not found in checkpoint")
+	}
+
+	f, err := data.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	u := pickle.NewUnpickler(f)
+	u.FindClass = func(module, name string) (any, error) {
+		switch module + "." + name {
+		case "torch._utils._rebuild_tensor_v2":
+			return torchRebuildTensor{}, nil
+		case "torch._utils._rebuild_parameter":
+			return torchRebuildParameter{}, nil
+		}
+
+		if module == "torch" && strings.HasSuffix(name, "Storage") {
+			if dtype, ok := torchStorageClasses[name]; ok {
+				return dtype, nil
+			}
+			return nil, fmt.Errorf("unsupported tensor type: %s", name)
+		}
+
+		return types.NewGenericClass(module, name), nil
+	}
+	u.PersistentLoad = func(id any) (any, error) {
+		tuple, ok := id.(*types.Tuple)
+		if !ok || tuple.Len() < 3 || tuple.Get(0) != "storage" {
+			return nil, fmt.Errorf("unexpected persistent id: %v", id)
+		}
+
+		dtype, ok := tuple.Get(1).(torchStorageClass)
+		if !ok {
+			return nil, fmt.Errorf("unexpected storage type: %v", tuple.Get(1))
+		}
+
+		key, ok := tuple.Get(2).(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected storage key: %v", tuple.Get(2))
+		}
+
+		return torchStorage{dtype: string(dtype), key: key}, nil
+	}
+
+	v, err := u.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []any
+	var get func(any) any
+	switch d := v.(type) {
+	case *types.Dict:
+		keys, get = d.Keys(), d.MustGet
+	case *types.OrderedDict:
+		for e := d.List.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*types.OrderedDictEntry).Key)
+		}
+		get = d.MustGet
+	default:
+		return nil, fmt.Errorf("unexpected checkpoint contents: %T", v)
+	}
+
+	var ts []*torch
+	for _, k := range keys {
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected tensor name: %v", k)
+		}
+
+		t, ok := get(k).(*torch)
+		if !ok {
+			// not a tensor, e.g. training metadata
+			continue
+		}
+
+		t.fs, t.path, t.key = fsys, p, key
+
+		// uncompressed storages are read in place
+		t.dataOffset = -1
+		if record, ok := records[t.storage.key]; ok && record.Method == zip.Store {
+			if t.dataOffset, err = record.DataOffset(); err != nil {
+				return nil, err
+			}
+		}
+
+		ts = append(ts, t)
 	}
 
 	return ts, nil
 }
 
 type torch struct {
-	storage pytorch.StorageInterface
+	fs      fs.FS
+	path    string
+	key     string
+	storage torchStorage
+	offset  int64
+
+	// dataOffset is where the storage's data starts in the checkpoint
+	// file if it's stored uncompressed, or -1
+	dataOffset int64
+
+	// scale is the tensor of the scales an FP8 tensor is multiplied by,
+	// one for each block of scaleBlock rows and columns
+	scale      *torch
+	scaleBlock [2]uint64
+
 	*tensorBase
 }
 
-func (pt torch) WriteTo(w io.Writer) (int64, error) {
-	return 0, nil
+func (t torch) elements() int64 {
+	n := int64(1)
+	for _, dim := range t.shape {
+		n *= int64(dim)
+	}
+	return n
+}
+
+// matrix returns the tensor's shape as rows and columns, with every dimension
+// but the first as columns
+func (t torch) matrix() (rows, cols uint64) {
+	if len(t.shape) == 0 {
+		return 1, 1
+	}
+
+	return t.shape[0], uint64(t.elements()) / t.shape[0]
+}
+
+// setScale sets the tensor of scales the tensor is multiplied by, checking
+// that its shape matches one of the ways scales are laid out
+func (t *torch) setScale(scale *torch, blockSize []int) error {
+	rows, cols := t.matrix()
+	switch {
+	case scale.elements() == 1:
+		t.scaleBlock = [2]uint64{rows, cols}
+	case slices.Equal(scale.shape, []uint64{rows}) || slices.Equal(scale.shape, []uint64{rows, 1}):
+		t.scaleBlock = [2]uint64{1, cols}
+	case len(blockSize) == 2 && blockSize[0] > 0 && blockSize[1] > 0 && len(scale.shape) == 2:
+		block := [2]uint64{uint64(blockSize[0]), uint64(blockSize[1])}
+		if !slices.Equal(scale.shape, []uint64{(rows + block[0] - 1) / block[0], (cols + block[1] - 1) / block[1]}) {
+			return fmt.Errorf("scales of shape %v don't match blocks of %v for shape %v", scale.shape, blockSize, t.shape)
+		}
+		t.scaleBlock = block
+	default:
+		return fmt.Errorf("unsupported scales of shape %v for shape %v, block scales need quantization_config.weight_block_size in config.json", scale.shape, t.shape)
+	}
+
+	t.scale = scale
+	return nil
+}
+
+// data reads the tensor's elements of the given size from its storage
+func (t torch) data(size int64) ([]byte, error) {
+	bts := make([]byte, t.elements()*size)
+
+	if t.dataOffset >= 0 {
+		f, err := t.fs.Open(t.path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if ra, ok := f.(io.ReaderAt); ok {
+			if _, err := io.ReadFull(io.NewSectionReader(ra, t.dataOffset+t.offset*size, int64(len(bts))), bts); err != nil {
+				return nil, err
+			}
+
+			return bts, nil
+		}
+	}
+
+	r, c, err := openTorchZip(t.fs, t.path)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	record, ok := torchRecords(r)[t.storage.key]
+	if !ok {
+		return nil, fmt.Errorf("%s: storage %s not found", t.path, t.storage.key)
+	}
+
+	f, err := record.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// compressed records have to be read up to the tensor
+	if _, err := io.CopyN(io.Discard, f, t.offset*size); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(f, bts); err != nil {
+		return nil, err
+	}
+
+	return bts, nil
+}
+
+// floats reads the tensor's elements as float32s
+func (t torch) floats() ([]float32, error) {
+	var size int64
+	switch t.storage.dtype {
+	case "F64":
+		size = 8
+	case "F32":
+		size = 4
+	case "F16", "BF16":
+		size = 2
+	case "F8_E4M3", "F8_E5M2":
+		size = 1
+	default:
+		return nil, fmt.Errorf("unknown data type: %s", t.storage.dtype)
+	}
+
+	bts, err := t.data(size)
+	if err != nil {
+		return nil, err
+	}
+
+	f32s := make([]float32, t.elements())
+	switch t.storage.dtype {
+	case "F64":
+		for i := range f32s {
+			f32s[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(bts[i*8:])))
+		}
+	case "F32":
+		for i := range f32s {
+			f32s[i] = math.Float32frombits(binary.LittleEndian.Uint32(bts[i*4:]))
+		}
+	case "F16":
+		for i := range f32s {
+			f32s[i] = float16.Frombits(binary.LittleEndian.Uint16(bts[i*2:])).Float32()
+		}
+	case "BF16":
+		f32s = bfloat16.DecodeFloat32(bts)
+	case "F8_E4M3":
+		for i, b := range bts {
+			f32s[i] = float8E4M3ToFloat32(b)
+		}
+	case "F8_E5M2":
+		for i, b := range bts {
+			f32s[i] = float8E5M2ToFloat32(b)
+		}
+	}
+
+	return f32s, nil
+}
+
+func (t torch) WriteTo(w io.Writer) (int64, error) {
+	f32s, err := t.floats()
+	if err != nil {
+		return 0, err
+	}
+
+	if t.scale != nil {
+		scales, err := t.scale.floats()
+		if err != nil {
+			return 0, err
+		}
+
+		rows, cols := t.matrix()
+		blocksPerRow := (cols + t.scaleBlock[1] - 1) / t.scaleBlock[1]
+		for i := range rows {
+			for j := range cols {
+				f32s[i*cols+j] *= scales[(i/t.scaleBlock[0])*blocksPerRow+j/t.scaleBlock[1]]
+			}
+		}
+	}
+
+	if t.repacker != nil {
+		f32s, err = t.repacker(t.Name(), f32s, t.Shape())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	switch t.Kind() {
+	case tensorKindF32:
+		return 0, binary.Write(w, binary.LittleEndian, f32s)
+	case tensorKindF16:
+		f16s := make([]uint16, len(f32s))
+		for i := range f32s {
+			f16s[i] = float16.Fromfloat32(f32s[i]).Bits()
+		}
+
+		return 0, binary.Write(w, binary.LittleEndian, f16s)
+	default:
+		return 0, fmt.Errorf("unknown storage type: %d", t.Kind())
+	}
+}
+
+// float8E4M3ToFloat32 decodes a float8_e4m3fn value: 1 sign bit, 4 exponent bits
+// with a bias of 7 and 3 mantissa bits. It has no infinities and only 0x7f/0xff are NaN.
+func float8E4M3ToFloat32(b uint8) float32 {
+	sign := float32(1)
+	if b&0x80 != 0 {
+		sign = -1
+	}
+
+	exp := int(b>>3) & 0xf
+	mant := float32(b & 0x7)
+
+	switch {
+	case exp == 0xf && mant == 0x7:
+		return float32(math.NaN())
+	case exp == 0:
+		return sign * mant / 8 * float32(math.Ldexp(1, -6))
+	default:
+		return sign * (1 + mant/8) * float32(math.Ldexp(1, exp-7))
+	}
+}
+
+// float8E5M2ToFloat32 decodes a float8_e5m2 value, which is the upper byte of a float16
+func float8E5M2ToFloat32(b uint8) float32 {
+	return float16.Frombits(uint16(b) << 8).Float32()
 }
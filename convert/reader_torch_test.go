@@ -0,0 +1,157 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/x448/float16"
+)
+
+// torchFloats writes each tensor and reads it back as float32s by name
+func torchFloats(t *testing.T, ts []Tensor) map[string][]float32 {
+	t.Helper()
+
+	actual := make(map[string][]float32)
+	for _, tensor := range ts {
+		var b bytes.Buffer
+		if _, err := tensor.WriteTo(&b); err != nil {
+			t.Fatalf("%s: %v", tensor.Name(), err)
+		}
+
+		switch tensor.Kind() {
+		case tensorKindF32:
+			f32s := make([]float32, b.Len()/4)
+			if err := binary.Read(&b, binary.LittleEndian, f32s); err != nil {
+				t.Fatal(err)
+			}
+			actual[tensor.Name()] = f32s
+		case tensorKindF16:
+			u16s := make([]uint16, b.Len()/2)
+			if err := binary.Read(&b, binary.LittleEndian, u16s); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, u16 := range u16s {
+				actual[tensor.Name()] = append(actual[tensor.Name()], float16.Frombits(u16).Float32())
+			}
+		}
+	}
+
+	return actual
+}
+
+func TestParseTorchSharded(t *testing.T) {
+	fsys := os.DirFS("testdata/torch-sharded")
+
+	ts, err := parseTensors(fsys, strings.NewReplacer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := torchFloats(t, ts)
+
+	// lm_head.weight is read from the shard the index points to, not the stale copy in the first shard
+	expect := map[string][]float32{
+		"model.embed_tokens.weight": {1, 2, 3, 4},
+		"model.norm.weight":         {0.5, -0.5},
+		"model.layers.0.mlp.weight": {1, 2, -1, 0.001953125},
+		"lm_head.weight":            {7, 8},
+	}
+
+	if diff := cmp.Diff(expect, actual); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseTorchFloat8Scales(t *testing.T) {
+	fsys := os.DirFS("testdata/torch-fp8")
+
+	ts, err := parseTensors(fsys, strings.NewReplacer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the scale tensors are applied rather than converted
+	expect := map[string][]float32{
+		"a.weight": {0.5, 1, -0.5, 0.75},
+		"b.weight": {3, 6, 0.25, 0.5, 3, 6, 0.25, 0.5},
+		"c.weight": {2, 4, -10, 15},
+	}
+
+	if diff := cmp.Diff(expect, torchFloats(t, ts)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTorchScaleShapes(t *testing.T) {
+	cases := []struct {
+		name      string
+		shape     []uint64
+		blockSize []int
+		block     [2]uint64
+		err       bool
+	}{
+		{name: "per tensor", shape: []uint64{}, block: [2]uint64{256, 512}},
+		{name: "per row", shape: []uint64{256, 1}, block: [2]uint64{1, 512}},
+		{name: "per block", shape: []uint64{2, 4}, blockSize: []int{128, 128}, block: [2]uint64{128, 128}},
+		{name: "per block without block size", shape: []uint64{2, 4}, err: true},
+		{name: "mismatched blocks", shape: []uint64{2, 2}, blockSize: []int{128, 128}, err: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			weight := &torch{key: "weight", storage: torchStorage{dtype: "F8_E4M3"}, tensorBase: &tensorBase{shape: []uint64{256, 512}}}
+			scale := &torch{key: "weight_scale", storage: torchStorage{dtype: "F32"}, tensorBase: &tensorBase{shape: tt.shape}}
+
+			ts, err := withTorchScales([]*torch{weight, scale}, tt.blockSize)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ts) != 1 || ts[0] != weight || weight.scale != scale {
+				t.Fatalf("expected the scale to be paired with the weight, got %v", ts)
+			}
+
+			if weight.scaleBlock != tt.block {
+				t.Errorf("block: have %v, want %v", weight.scaleBlock, tt.block)
+			}
+		})
+	}
+}
+
+func TestFloat8(t *testing.T) {
+	cases := []struct {
+		b      uint8
+		e4m3   float32
+		e5m2   float32
+		e4m3na bool
+	}{
+		{b: 0x00, e4m3: 0, e5m2: 0},
+		{b: 0x38, e4m3: 1, e5m2: 0.5},
+		{b: 0x3c, e4m3: 1.5, e5m2: 1},
+		{b: 0xc0, e4m3: -2, e5m2: -2},
+		{b: 0x7e, e4m3: 448, e5m2: float32(math.NaN())},
+		{b: 0x7f, e4m3na: true, e5m2: float32(math.NaN())},
+	}
+
+	for _, tt := range cases {
+		if e4m3 := float8E4M3ToFloat32(tt.b); tt.e4m3na != math.IsNaN(float64(e4m3)) || (!tt.e4m3na && e4m3 != tt.e4m3) {
+			t.Errorf("e4m3 %#x: have %v, want %v", tt.b, e4m3, tt.e4m3)
+		}
+
+		e5m2 := float8E5M2ToFloat32(tt.b)
+		if math.IsNaN(float64(tt.e5m2)) != math.IsNaN(float64(e5m2)) || (!math.IsNaN(float64(tt.e5m2)) && e5m2 != tt.e5m2) {
+			t.Errorf("e5m2 %#x: have %v, want %v", tt.b, e5m2, tt.e5m2)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package envconfig
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"math"
 	"net"
 	"net/url"
@@ -17,9 +18,28 @@ import (
 // Host returns the scheme and host. Host can be configured via the OLLAMA_HOST environment variable.
 // Default is scheme "http" and host "127.0.0.1:11434"
 func Host() *url.URL {
+	u, err := parseHost(Var("OLLAMA_HOST"))
+	if err != nil {
+		slog.Warn("invalid port, using default", "port", err.port, "default", u.Port())
+	}
+
+	return u
+}
+
+type portError struct {
+	port string
+}
+
+func (e *portError) Error() string {
+	return fmt.Sprintf("invalid port %q", e.port)
+}
+
+// parseHost parses s the way Host does. If the port is invalid it returns the
+// host with the default port along with the error.
+func parseHost(s string) (*url.URL, *portError) {
 	defaultPort := "11434"
 
-	s := strings.TrimSpace(Var("OLLAMA_HOST"))
+	s = strings.TrimSpace(s)
 	scheme, hostport, ok := strings.Cut(s, "://")
 	switch {
 	case !ok:
@@ -41,8 +61,9 @@ func Host() *url.URL {
 		}
 	}
 
+	var perr *portError
 	if n, err := strconv.ParseInt(port, 10, 32); err != nil || n > 65535 || n < 0 {
-		slog.Warn("invalid port, using default", "port", port, "default", defaultPort)
+		perr = &portError{port}
 		port = defaultPort
 	}
 
@@ -50,7 +71,7 @@ func Host() *url.URL {
 		Scheme: scheme,
 		Host:   net.JoinHostPort(host, port),
 		Path:   path,
-	}
+	}, perr
 }
 
 // Origins returns a list of allowed origins. Origins can be configured via the OLLAMA_ORIGINS environment variable.
@@ -93,16 +114,43 @@ func Models() string {
 	return filepath.Join(home, ".ollama", "models")
 }
 
+// TrustedKeys returns the path to a file of public keys, in authorized_keys format, that are trusted to sign models.
+// TrustedKeys can be configured via the OLLAMA_TRUSTED_KEYS environment variable.
+// Default is $HOME/.ollama/trusted_keys
+func TrustedKeys() string {
+	if s := Var("OLLAMA_TRUSTED_KEYS"); s != "" {
+		return s
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+
+	return filepath.Join(home, ".ollama", "trusted_keys")
+}
+
+// RequireSignatures returns the registries that models must be signed by a trusted key to be pulled from.
+// RequireSignatures can be configured via the OLLAMA_REQUIRE_SIGNATURES environment variable as a comma separated
+// list of registry hosts, or "*" for all registries.
+func RequireSignatures() (registries []string) {
+	for _, s := range strings.Split(Var("OLLAMA_REQUIRE_SIGNATURES"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			registries = append(registries, s)
+		}
+	}
+
+	return registries
+}
+
 // KeepAlive returns the duration that models stay loaded in memory. KeepAlive can be configured via the OLLAMA_KEEP_ALIVE environment variable.
 // Negative values are treated as infinite. Zero is treated as no keep alive.
 // Default is 5 minutes.
 func KeepAlive() (keepAlive time.Duration) {
 	keepAlive = 5 * time.Minute
 	if s := Var("OLLAMA_KEEP_ALIVE"); s != "" {
-		if d, err := time.ParseDuration(s); err == nil {
+		if d, err := parseDuration(s); err == nil {
 			keepAlive = d
-		} else if n, err := strconv.ParseInt(s, 10, 64); err == nil {
-			keepAlive = time.Duration(n) * time.Second
 		}
 	}
 
@@ -119,10 +167,8 @@ func KeepAlive() (keepAlive time.Duration) {
 func LoadTimeout() (loadTimeout time.Duration) {
 	loadTimeout = 5 * time.Minute
 	if s := Var("OLLAMA_LOAD_TIMEOUT"); s != "" {
-		if d, err := time.ParseDuration(s); err == nil {
+		if d, err := parseDuration(s); err == nil {
 			loadTimeout = d
-		} else if n, err := strconv.ParseInt(s, 10, 64); err == nil {
-			loadTimeout = time.Duration(n) * time.Second
 		}
 	}
 
@@ -133,7 +179,87 @@ func LoadTimeout() (loadTimeout time.Duration) {
 	return loadTimeout
 }
 
+// RequestTimeout returns how long a chat or generate request may run for when it doesn't set a timeout. RequestTimeout can be configured via the OLLAMA_REQUEST_TIMEOUT environment variable.
+// Zero or Negative values are treated as infinite.
+// Default is infinite.
+func RequestTimeout() time.Duration {
+	return infiniteDuration("OLLAMA_REQUEST_TIMEOUT")
+}
+
+// MaxRequestTimeout returns the longest a chat or generate request may run for, including requests that set a longer timeout. MaxRequestTimeout can be configured via the OLLAMA_MAX_REQUEST_TIMEOUT environment variable.
+// Zero or Negative values are treated as infinite.
+// Default is infinite.
+func MaxRequestTimeout() time.Duration {
+	return infiniteDuration("OLLAMA_MAX_REQUEST_TIMEOUT")
+}
+
+// infiniteDuration parses key as a duration or a number of seconds, which is
+// infinite if it isn't set or isn't positive
+func infiniteDuration(key string) time.Duration {
+	var d time.Duration
+	if s := Var(key); s != "" {
+		if v, err := parseDuration(s); err == nil {
+			d = v
+		}
+	}
+
+	if d <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return d
+}
+
+// parseDuration parses s as a duration, e.g. "5m", or a number of seconds
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return time.Duration(n) * time.Second, nil
+}
+
+// parsers validate the values of settings which aren't free-form strings,
+// keyed by the name of the environment variable. Settings created with Bool,
+// Uint and Uint64 add themselves.
+var parsers = map[string]func(string) error{
+	"OLLAMA_HOST": func(s string) error {
+		if _, err := parseHost(s); err != nil {
+			return err
+		}
+		return nil
+	},
+	"OLLAMA_KEEP_ALIVE":          durationParser,
+	"OLLAMA_LOAD_TIMEOUT":        durationParser,
+	"OLLAMA_REQUEST_TIMEOUT":     durationParser,
+	"OLLAMA_MAX_REQUEST_TIMEOUT": durationParser,
+}
+
+func durationParser(s string) error {
+	_, err := parseDuration(s)
+	return err
+}
+
+func uintParser(s string) error {
+	if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+		return fmt.Errorf("invalid unsigned integer %q", s)
+	}
+	return nil
+}
+
 func Bool(k string) func() bool {
+	parsers[k] = func(s string) error {
+		if _, err := strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		return nil
+	}
+
 	return func() bool {
 		if s := Var(k); s != "" {
 			b, err := strconv.ParseBool(s)
@@ -184,6 +310,7 @@ var (
 )
 
 func Uint(key string, defaultValue uint) func() uint {
+	parsers[key] = uintParser
 	return func() uint {
 		if s := Var(key); s != "" {
 			if n, err := strconv.ParseUint(s, 10, 64); err != nil {
@@ -206,9 +333,14 @@ var (
 	MaxQueue = Uint("OLLAMA_MAX_QUEUE", 512)
 	// MaxVRAM sets a maximum VRAM override in bytes. MaxVRAM can be configured via the OLLAMA_MAX_VRAM environment variable.
 	MaxVRAM = Uint("OLLAMA_MAX_VRAM", 0)
+	// MaxLoras sets the maximum number of LoRA adapters each runner keeps loaded. MaxLoras can be configured via the OLLAMA_MAX_LORAS environment variable.
+	MaxLoras = Uint("OLLAMA_MAX_LORAS", 8)
+	// PrefillChunkSize limits how many prompt tokens a request adds to each batch while other requests are generating. PrefillChunkSize can be configured via the OLLAMA_PREFILL_CHUNK_SIZE environment variable.
+	PrefillChunkSize = Uint("OLLAMA_PREFILL_CHUNK_SIZE", 0)
 )
 
 func Uint64(key string, defaultValue uint64) func() uint64 {
+	parsers[key] = uintParser
 	return func() uint64 {
 		if s := Var(key); s != "" {
 			if n, err := strconv.ParseUint(s, 10, 64); err != nil {
@@ -233,23 +365,30 @@ type EnvVar struct {
 
 func AsMap() map[string]EnvVar {
 	ret := map[string]EnvVar{
-		"OLLAMA_DEBUG":             {"OLLAMA_DEBUG", Debug(), "Show additional debug information (e.g. OLLAMA_DEBUG=1)"},
-		"OLLAMA_FLASH_ATTENTION":   {"OLLAMA_FLASH_ATTENTION", FlashAttention(), "Enabled flash attention"},
-		"OLLAMA_KV_CACHE_TYPE":     {"OLLAMA_KV_CACHE_TYPE", KvCacheType(), "Quantization type for the K/V cache (default: f16)"},
-		"OLLAMA_GPU_OVERHEAD":      {"OLLAMA_GPU_OVERHEAD", GpuOverhead(), "Reserve a portion of VRAM per GPU (bytes)"},
-		"OLLAMA_HOST":              {"OLLAMA_HOST", Host(), "IP Address for the ollama server (default 127.0.0.1:11434)"},
-		"OLLAMA_KEEP_ALIVE":        {"OLLAMA_KEEP_ALIVE", KeepAlive(), "The duration that models stay loaded in memory (default \"5m\")"},
-		"OLLAMA_LLM_LIBRARY":       {"OLLAMA_LLM_LIBRARY", LLMLibrary(), "Set LLM library to bypass autodetection"},
-		"OLLAMA_LOAD_TIMEOUT":      {"OLLAMA_LOAD_TIMEOUT", LoadTimeout(), "How long to allow model loads to stall before giving up (default \"5m\")"},
-		"OLLAMA_MAX_LOADED_MODELS": {"OLLAMA_MAX_LOADED_MODELS", MaxRunners(), "Maximum number of loaded models per GPU"},
-		"OLLAMA_MAX_QUEUE":         {"OLLAMA_MAX_QUEUE", MaxQueue(), "Maximum number of queued requests"},
-		"OLLAMA_MODELS":            {"OLLAMA_MODELS", Models(), "The path to the models directory"},
-		"OLLAMA_NOHISTORY":         {"OLLAMA_NOHISTORY", NoHistory(), "Do not preserve readline history"},
-		"OLLAMA_NOPRUNE":           {"OLLAMA_NOPRUNE", NoPrune(), "Do not prune model blobs on startup"},
-		"OLLAMA_NUM_PARALLEL":      {"OLLAMA_NUM_PARALLEL", NumParallel(), "Maximum number of parallel requests"},
-		"OLLAMA_ORIGINS":           {"OLLAMA_ORIGINS", Origins(), "A comma separated list of allowed origins"},
-		"OLLAMA_SCHED_SPREAD":      {"OLLAMA_SCHED_SPREAD", SchedSpread(), "Always schedule model across all GPUs"},
-		"OLLAMA_MULTIUSER_CACHE":   {"OLLAMA_MULTIUSER_CACHE", MultiUserCache(), "Optimize prompt caching for multi-user scenarios"},
+		"OLLAMA_CONFIG":              {"OLLAMA_CONFIG", ConfigPath(), "The path to the config file (default ~/.ollama/config.toml or config.yaml)"},
+		"OLLAMA_DEBUG":               {"OLLAMA_DEBUG", Debug(), "Show additional debug information (e.g. OLLAMA_DEBUG=1)"},
+		"OLLAMA_FLASH_ATTENTION":     {"OLLAMA_FLASH_ATTENTION", FlashAttention(), "Enabled flash attention"},
+		"OLLAMA_KV_CACHE_TYPE":       {"OLLAMA_KV_CACHE_TYPE", KvCacheType(), "Quantization type for the K/V cache (default: f16)"},
+		"OLLAMA_GPU_OVERHEAD":        {"OLLAMA_GPU_OVERHEAD", GpuOverhead(), "Reserve a portion of VRAM per GPU (bytes)"},
+		"OLLAMA_HOST":                {"OLLAMA_HOST", Host(), "IP Address for the ollama server (default 127.0.0.1:11434)"},
+		"OLLAMA_KEEP_ALIVE":          {"OLLAMA_KEEP_ALIVE", KeepAlive(), "The duration that models stay loaded in memory (default \"5m\")"},
+		"OLLAMA_LLM_LIBRARY":         {"OLLAMA_LLM_LIBRARY", LLMLibrary(), "Set LLM library to bypass autodetection"},
+		"OLLAMA_LOAD_TIMEOUT":        {"OLLAMA_LOAD_TIMEOUT", LoadTimeout(), "How long to allow model loads to stall before giving up (default \"5m\")"},
+		"OLLAMA_MAX_LOADED_MODELS":   {"OLLAMA_MAX_LOADED_MODELS", MaxRunners(), "Maximum number of loaded models per GPU"},
+		"OLLAMA_MAX_LORAS":           {"OLLAMA_MAX_LORAS", MaxLoras(), "Maximum number of LoRA adapters loaded per model (default 8)"},
+		"OLLAMA_MAX_QUEUE":           {"OLLAMA_MAX_QUEUE", MaxQueue(), "Maximum number of queued requests"},
+		"OLLAMA_MAX_REQUEST_TIMEOUT": {"OLLAMA_MAX_REQUEST_TIMEOUT", MaxRequestTimeout(), "Longest a chat or generate request may run, even if it sets a longer timeout"},
+		"OLLAMA_MODELS":              {"OLLAMA_MODELS", Models(), "The path to the models directory"},
+		"OLLAMA_NOHISTORY":           {"OLLAMA_NOHISTORY", NoHistory(), "Do not preserve readline history"},
+		"OLLAMA_NOPRUNE":             {"OLLAMA_NOPRUNE", NoPrune(), "Do not prune model blobs on startup"},
+		"OLLAMA_NUM_PARALLEL":        {"OLLAMA_NUM_PARALLEL", NumParallel(), "Maximum number of parallel requests"},
+		"OLLAMA_ORIGINS":             {"OLLAMA_ORIGINS", Origins(), "A comma separated list of allowed origins"},
+		"OLLAMA_REQUEST_TIMEOUT":     {"OLLAMA_REQUEST_TIMEOUT", RequestTimeout(), "How long a chat or generate request may run if it doesn't set a timeout"},
+		"OLLAMA_PREFILL_CHUNK_SIZE":  {"OLLAMA_PREFILL_CHUNK_SIZE", PrefillChunkSize(), "Maximum prompt tokens per request in each batch while other requests are generating (default: batch size)"},
+		"OLLAMA_REQUIRE_SIGNATURES":  {"OLLAMA_REQUIRE_SIGNATURES", RequireSignatures(), "A comma separated list of registries that pulled models must be signed for (\"*\" for all)"},
+		"OLLAMA_SCHED_SPREAD":        {"OLLAMA_SCHED_SPREAD", SchedSpread(), "Always schedule model across all GPUs"},
+		"OLLAMA_MULTIUSER_CACHE":     {"OLLAMA_MULTIUSER_CACHE", MultiUserCache(), "Optimize prompt caching for multi-user scenarios"},
+		"OLLAMA_TRUSTED_KEYS":        {"OLLAMA_TRUSTED_KEYS", TrustedKeys(), "The path to the public keys trusted to sign models"},
 
 		// Informational
 		"HTTP_PROXY":  {"HTTP_PROXY", String("HTTP_PROXY")(), "HTTP proxy"},
@@ -259,23 +398,38 @@ func AsMap() map[string]EnvVar {
 
 	if runtime.GOOS != "windows" {
 		// Windows environment variables are case-insensitive so there's no need to duplicate them
-		ret["http_proxy"] = EnvVar{"http_proxy", String("http_proxy")(), "HTTP proxy"}
-		ret["https_proxy"] = EnvVar{"https_proxy", String("https_proxy")(), "HTTPS proxy"}
-		ret["no_proxy"] = EnvVar{"no_proxy", String("no_proxy")(), "No proxy"}
+		maps.Copy(ret, proxyVars())
 	}
 
 	if runtime.GOOS != "darwin" {
-		ret["CUDA_VISIBLE_DEVICES"] = EnvVar{"CUDA_VISIBLE_DEVICES", CudaVisibleDevices(), "Set which NVIDIA devices are visible"}
-		ret["HIP_VISIBLE_DEVICES"] = EnvVar{"HIP_VISIBLE_DEVICES", HipVisibleDevices(), "Set which AMD devices are visible by numeric ID"}
-		ret["ROCR_VISIBLE_DEVICES"] = EnvVar{"ROCR_VISIBLE_DEVICES", RocrVisibleDevices(), "Set which AMD devices are visible by UUID or numeric ID"}
-		ret["GPU_DEVICE_ORDINAL"] = EnvVar{"GPU_DEVICE_ORDINAL", GpuDeviceOrdinal(), "Set which AMD devices are visible by numeric ID"}
-		ret["HSA_OVERRIDE_GFX_VERSION"] = EnvVar{"HSA_OVERRIDE_GFX_VERSION", HsaOverrideGfxVersion(), "Override the gfx used for all detected AMD GPUs"}
-		ret["OLLAMA_INTEL_GPU"] = EnvVar{"OLLAMA_INTEL_GPU", IntelGPU(), "Enable experimental Intel GPU detection"}
+		maps.Copy(ret, gpuVars())
 	}
 
 	return ret
 }
 
+// proxyVars are the lower case proxy variables, which are only listed where
+// environment variables are case-sensitive
+func proxyVars() map[string]EnvVar {
+	return map[string]EnvVar{
+		"http_proxy":  {"http_proxy", String("http_proxy")(), "HTTP proxy"},
+		"https_proxy": {"https_proxy", String("https_proxy")(), "HTTPS proxy"},
+		"no_proxy":    {"no_proxy", String("no_proxy")(), "No proxy"},
+	}
+}
+
+// gpuVars are the variables selecting GPUs, which aren't used on macOS
+func gpuVars() map[string]EnvVar {
+	return map[string]EnvVar{
+		"CUDA_VISIBLE_DEVICES":     {"CUDA_VISIBLE_DEVICES", CudaVisibleDevices(), "Set which NVIDIA devices are visible"},
+		"HIP_VISIBLE_DEVICES":      {"HIP_VISIBLE_DEVICES", HipVisibleDevices(), "Set which AMD devices are visible by numeric ID"},
+		"ROCR_VISIBLE_DEVICES":     {"ROCR_VISIBLE_DEVICES", RocrVisibleDevices(), "Set which AMD devices are visible by UUID or numeric ID"},
+		"GPU_DEVICE_ORDINAL":       {"GPU_DEVICE_ORDINAL", GpuDeviceOrdinal(), "Set which AMD devices are visible by numeric ID"},
+		"HSA_OVERRIDE_GFX_VERSION": {"HSA_OVERRIDE_GFX_VERSION", HsaOverrideGfxVersion(), "Override the gfx used for all detected AMD GPUs"},
+		"OLLAMA_INTEL_GPU":         {"OLLAMA_INTEL_GPU", IntelGPU(), "Enable experimental Intel GPU detection"},
+	}
+}
+
 func Values() map[string]string {
 	vals := make(map[string]string)
 	for k, v := range AsMap() {
@@ -253,6 +253,36 @@ func TestLoadTimeout(t *testing.T) {
 	}
 }
 
+func TestRequestTimeout(t *testing.T) {
+	infinite := time.Duration(math.MaxInt64)
+	cases := map[string]time.Duration{
+		"":     infinite,
+		"30s":  30 * time.Second,
+		"10m":  10 * time.Minute,
+		"90":   90 * time.Second,
+		"0":    infinite,
+		"-1":   infinite,
+		"-1m":  infinite,
+		"???":  infinite,
+		"1d":   infinite,
+		"1h0m": time.Hour,
+	}
+
+	for tt, expect := range cases {
+		t.Run(tt, func(t *testing.T) {
+			t.Setenv("OLLAMA_REQUEST_TIMEOUT", tt)
+			t.Setenv("OLLAMA_MAX_REQUEST_TIMEOUT", tt)
+			if actual := RequestTimeout(); actual != expect {
+				t.Errorf("%s: expected %s, got %s", tt, expect, actual)
+			}
+
+			if actual := MaxRequestTimeout(); actual != expect {
+				t.Errorf("%s: expected %s, got %s", tt, expect, actual)
+			}
+		})
+	}
+}
+
 func TestVar(t *testing.T) {
 	cases := map[string]string{
 		"value":       "value",
@@ -0,0 +1,204 @@
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// sources records where settings loaded by LoadConfig came from, keyed by
+// the name of the environment variable
+var sources = map[string]string{}
+
+// ConfigPath returns the path of the config file. It can be set with the
+// OLLAMA_CONFIG environment variable and otherwise is the first of
+// config.toml, config.yaml or config.yml in $HOME/.ollama which exists, or ""
+// if none do.
+func ConfigPath() string {
+	if s := Var("OLLAMA_CONFIG"); s != "" {
+		return s
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml"} {
+		p := filepath.Join(home, ".ollama", name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// configKey returns the environment variable a key in the config file sets.
+// Keys are either the name of the variable, e.g. OLLAMA_KEEP_ALIVE, or the
+// name in lower case without the OLLAMA_ prefix, e.g. keep_alive.
+func configKey(key string, vars map[string]EnvVar) (string, bool) {
+	// the config file can't point to another one
+	if key == "OLLAMA_CONFIG" || key == "config" {
+		return "", false
+	}
+
+	if _, ok := vars[key]; ok {
+		return key, true
+	}
+
+	for name := range vars {
+		if strings.ToLower(strings.TrimPrefix(name, "OLLAMA_")) == key {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// configVars returns the settings a config file can set. Unlike AsMap it
+// includes every platform's settings, so the same file can be used on any
+// machine.
+func configVars() map[string]EnvVar {
+	vars := AsMap()
+	maps.Copy(vars, proxyVars())
+	maps.Copy(vars, gpuVars())
+	return vars
+}
+
+// configValue formats a value from the config file the way it would be given
+// in the environment. Lists are joined with commas.
+func configValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []any:
+		values := make([]string, len(v))
+		for i, e := range v {
+			s, err := configValue(e)
+			if err != nil {
+				return "", err
+			}
+			values[i] = s
+		}
+		return strings.Join(values, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// LoadConfig reads the settings in the config file at path, or ConfigPath if
+// path is empty, into the environment. Settings in the environment take
+// precedence over the config file, which takes precedence over the defaults.
+// Keys which aren't settings and values the setting can't parse are an
+// error naming the key and the file.
+func LoadConfig(path string) error {
+	if path == "" {
+		if path = ConfigPath(); path == "" {
+			return nil
+		}
+	}
+
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	settings := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(bts, &settings)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bts, &settings)
+	default:
+		return fmt.Errorf("%s: config files must be .toml, .yaml or .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	vars := configVars()
+
+	var errs []error
+	values := map[string]string{}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		name, ok := configKey(k, vars)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown setting '%s'", k))
+			continue
+		}
+
+		if _, ok := values[name]; ok {
+			errs = append(errs, fmt.Errorf("'%s' is set more than once", name))
+			continue
+		}
+
+		s, err := configValue(settings[k])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("'%s': %w", k, err))
+			continue
+		}
+
+		// values are checked the way the setting reads them from the
+		// environment, which would otherwise quietly fall back to a default.
+		// Empty values leave the default.
+		if parse, ok := parsers[name]; ok {
+			if v := strings.Trim(strings.TrimSpace(s), "\"'"); v != "" {
+				if err := parse(v); err != nil {
+					errs = append(errs, fmt.Errorf("'%s': %w", k, err))
+					continue
+				}
+			}
+		}
+		values[name] = s
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %w", path, errors.Join(errs...))
+	}
+
+	for name, value := range values {
+		if Var(name) != "" {
+			sources[name] = "env"
+			continue
+		}
+
+		// settings are passed through the environment so they also apply to
+		// runners and libraries which read it directly
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		sources[name] = path
+	}
+
+	return nil
+}
+
+// Source returns where the value of the setting named key comes from: "env"
+// for the environment, the path of the config file, or "default".
+func Source(key string) string {
+	if s, ok := sources[key]; ok {
+		return s
+	}
+
+	if Var(key) != "" {
+		return "env"
+	}
+
+	return "default"
+}
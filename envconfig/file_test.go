@@ -0,0 +1,192 @@
+package envconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cases := map[string]struct {
+		name    string
+		content string
+	}{
+		"toml": {"config.toml", `
+keep_alive = "10m"
+OLLAMA_NUM_PARALLEL = 4
+flash_attention = true
+origins = ["https://a.example.com", "https://b.example.com"]
+`},
+		"yaml": {"config.yaml", `
+keep_alive: 10m
+OLLAMA_NUM_PARALLEL: 4
+flash_attention: true
+origins:
+  - https://a.example.com
+  - https://b.example.com
+`},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			for _, k := range []string{"OLLAMA_KEEP_ALIVE", "OLLAMA_NUM_PARALLEL", "OLLAMA_FLASH_ATTENTION", "OLLAMA_ORIGINS"} {
+				t.Setenv(k, "")
+			}
+			// the environment takes precedence over the config file
+			t.Setenv("OLLAMA_NUM_PARALLEL", "2")
+			t.Cleanup(func() { sources = map[string]string{} })
+
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := LoadConfig(path); err != nil {
+				t.Fatal(err)
+			}
+
+			if v := Var("OLLAMA_KEEP_ALIVE"); v != "10m" {
+				t.Errorf("expected keep alive 10m, got %q", v)
+			}
+
+			if !FlashAttention() {
+				t.Error("expected flash attention to be enabled")
+			}
+
+			if v := Var("OLLAMA_ORIGINS"); v != "https://a.example.com,https://b.example.com" {
+				t.Errorf("unexpected origins %q", v)
+			}
+
+			if n := NumParallel(); n != 2 {
+				t.Errorf("expected the environment to win, got %d", n)
+			}
+
+			if s := Source("OLLAMA_KEEP_ALIVE"); s != path {
+				t.Errorf("expected source %q, got %q", path, s)
+			}
+
+			if s := Source("OLLAMA_NUM_PARALLEL"); s != "env" {
+				t.Errorf("expected source env, got %q", s)
+			}
+
+			if s := Source("OLLAMA_MODELS"); s != "default" {
+				t.Errorf("expected source default, got %q", s)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPlatformSettings(t *testing.T) {
+	// settings only used on some platforms are accepted on all of them
+	for _, k := range []string{"CUDA_VISIBLE_DEVICES", "OLLAMA_INTEL_GPU", "https_proxy"} {
+		t.Setenv(k, "")
+	}
+	t.Cleanup(func() { sources = map[string]string{} })
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+cuda_visible_devices = "0"
+intel_gpu = true
+https_proxy = "https://proxy.example.com"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := Var("CUDA_VISIBLE_DEVICES"); v != "0" {
+		t.Errorf("expected CUDA_VISIBLE_DEVICES 0, got %q", v)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	cases := map[string]struct {
+		name    string
+		content string
+		expect  []string
+	}{
+		"unknown":   {"config.toml", "keep_alive = \"5m\"\nkeepalive = \"5m\"\n", []string{"unknown setting 'keepalive'"}},
+		"config":    {"config.toml", "config = \"other.toml\"\n", []string{"unknown setting 'config'"}},
+		"duplicate": {"config.yaml", "keep_alive: 5m\nOLLAMA_KEEP_ALIVE: 10m\n", []string{"'OLLAMA_KEEP_ALIVE' is set more than once"}},
+		"value":     {"config.yaml", "host: {address: 0.0.0.0}\nbogus: 1\n", []string{"'host': unsupported value", "unknown setting 'bogus'"}},
+		"syntax":    {"config.toml", "keep_alive = \n", []string{"config.toml"}},
+		"duration":  {"config.toml", "keep_alive = \"forever\"\n", []string{"config.toml: 'keep_alive': invalid duration \"forever\""}},
+		"bool":      {"config.yaml", "flash_attention: sometimes\n", []string{"config.yaml: 'flash_attention': invalid boolean \"sometimes\""}},
+		"uint":      {"config.toml", "OLLAMA_NUM_PARALLEL = -1\n", []string{"config.toml: 'OLLAMA_NUM_PARALLEL': invalid unsigned integer \"-1\""}},
+		"port":      {"config.yaml", "host: 0.0.0.0:99999\n", []string{"config.yaml: 'host': invalid port \"99999\""}},
+		"extension": {"config.json", "{}", []string{"must be .toml, .yaml or .yml"}},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("OLLAMA_KEEP_ALIVE", "")
+			t.Cleanup(func() { sources = map[string]string{} })
+
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := LoadConfig(path)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			for _, s := range tt.expect {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("expected %q in %q", s, err)
+				}
+			}
+
+			// nothing is applied from an invalid config file
+			if v := Var("OLLAMA_KEEP_ALIVE"); v != "" {
+				t.Errorf("expected keep alive to be unset, got %q", v)
+			}
+		})
+	}
+}
+
+func TestConfigParsers(t *testing.T) {
+	// every setting which isn't a free-form string is validated in config files
+	for name, v := range configVars() {
+		switch v.Value.(type) {
+		case string, []string:
+		default:
+			if _, ok := parsers[name]; !ok {
+				t.Errorf("%s has no parser", name)
+			}
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("OLLAMA_CONFIG", "")
+
+	if p := ConfigPath(); p != "" {
+		t.Errorf("expected no config file, got %q", p)
+	}
+
+	if err := os.MkdirAll(filepath.Join(home, ".ollama"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	yml := filepath.Join(home, ".ollama", "config.yaml")
+	if err := os.WriteFile(yml, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := ConfigPath(); p != yml {
+		t.Errorf("expected %q, got %q", yml, p)
+	}
+
+	t.Setenv("OLLAMA_CONFIG", "/etc/ollama/config.toml")
+	if p := ConfigPath(); p != "/etc/ollama/config.toml" {
+		t.Errorf("expected OLLAMA_CONFIG to be used, got %q", p)
+	}
+}
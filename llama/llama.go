@@ -305,25 +305,49 @@ func (m *Model) AddBOSToken() bool {
 }
 
 func (m *Model) ApplyLoraFromFile(context *Context, loraPath string, scale float32, threads int) error {
+	adapter, err := m.LoadLoraAdapter(loraPath)
+	if err != nil {
+		return err
+	}
+
+	return context.SetLoraAdapter(adapter, scale)
+}
+
+// LoraAdapter is a LoRA adapter loaded alongside a model. It is not applied
+// to any context until it is passed to [Context.SetLoraAdapter].
+type LoraAdapter struct {
+	c *C.struct_llama_lora_adapter
+}
+
+func (m *Model) LoadLoraAdapter(loraPath string) (*LoraAdapter, error) {
 	cLoraPath := C.CString(loraPath)
 	defer C.free(unsafe.Pointer(cLoraPath))
 
 	loraAdapter := C.llama_lora_adapter_init(m.c, cLoraPath)
 	if loraAdapter == nil {
-		return errors.New("unable to load lora")
+		return nil, errors.New("unable to load lora")
 	}
 
-	err := -1
-	if loraAdapter != nil {
-		err = int(C.llama_lora_adapter_set(context.c, loraAdapter, C.float(scale)))
-	}
-	if err != 0 {
+	return &LoraAdapter{c: loraAdapter}, nil
+}
+
+func (a *LoraAdapter) Free() {
+	C.llama_lora_adapter_free(a.c)
+}
+
+func (c *Context) SetLoraAdapter(adapter *LoraAdapter, scale float32) error {
+	if int(C.llama_lora_adapter_set(c.c, adapter.c, C.float(scale))) != 0 {
 		return errors.New("error applying lora from file")
 	}
 
 	return nil
 }
 
+// ClearLoraAdapters removes all adapters from the context without freeing them
+func (c *Context) ClearLoraAdapters() {
+	C.llama_lora_adapter_clear(c.c)
+}
+
 type Batch struct {
 	c         C.struct_llama_batch
 	batchSize int
@@ -634,6 +658,15 @@ type SamplingParams struct {
 	PenalizeNl     bool
 	Seed           uint32
 	Grammar        string
+
+	DryMultiplier       float32
+	DryBase             float32
+	DryAllowedLength    int
+	DryPenaltyLastN     int
+	DrySequenceBreakers []string
+	XTCProbability      float32
+	XTCThreshold        float32
+	Samplers            []string
 }
 
 func NewSamplingContext(model *Model, params SamplingParams) (*SamplingContext, error) {
@@ -646,7 +679,7 @@ func NewSamplingContext(model *Model, params SamplingParams) (*SamplingContext,
 	cparams.penalty_last_n = C.int32_t(params.RepeatLastN)
 	cparams.penalty_repeat = C.float(params.PenaltyRepeat)
 	cparams.penalty_freq = C.float(params.PenaltyFreq)
-	cparams.penalty_present = C.float(params.PenaltyFreq)
+	cparams.penalty_present = C.float(params.PenaltyPresent)
 	cparams.mirostat = C.int32_t(params.Mirostat)
 	cparams.mirostat_tau = C.float(params.MirostatTau)
 	cparams.mirostat_eta = C.float(params.MirostatEta)
@@ -656,6 +689,23 @@ func NewSamplingContext(model *Model, params SamplingParams) (*SamplingContext,
 	defer C.free(unsafe.Pointer(grammar))
 
 	cparams.grammar = grammar
+	cparams.dry_multiplier = C.float(params.DryMultiplier)
+	cparams.dry_base = C.float(params.DryBase)
+	cparams.dry_allowed_length = C.int32_t(params.DryAllowedLength)
+	cparams.dry_penalty_last_n = C.int32_t(params.DryPenaltyLastN)
+	cparams.xtc_probability = C.float(params.XTCProbability)
+	cparams.xtc_threshold = C.float(params.XTCThreshold)
+
+	breakers := cStrings(params.DrySequenceBreakers)
+	defer breakers.free()
+	cparams.dry_sequence_breakers = breakers.ptr()
+	cparams.n_dry_sequence_breakers = C.size_t(len(params.DrySequenceBreakers))
+
+	samplers := cStrings(params.Samplers)
+	defer samplers.free()
+	cparams.samplers = samplers.ptr()
+	cparams.n_samplers = C.size_t(len(params.Samplers))
+
 	context := &SamplingContext{c: C.common_sampler_cinit(model.c, &cparams)}
 	if context.c == nil {
 		return nil, errors.New("unable to create sampling context")
@@ -666,6 +716,43 @@ func NewSamplingContext(model *Model, params SamplingParams) (*SamplingContext,
 	return context, nil
 }
 
+// cStringArray is a C array of C strings
+type cStringArray []*C.char
+
+func cStrings(ss []string) cStringArray {
+	if len(ss) == 0 {
+		return nil
+	}
+
+	// the array is allocated in C memory so it can be passed to C
+	// alongside the strings it points to
+	a := unsafe.Slice((**C.char)(C.malloc(C.size_t(len(ss))*C.size_t(unsafe.Sizeof((*C.char)(nil))))), len(ss))
+	for i, s := range ss {
+		a[i] = C.CString(s)
+	}
+
+	return a
+}
+
+func (a cStringArray) ptr() **C.char {
+	if len(a) == 0 {
+		return nil
+	}
+
+	return &a[0]
+}
+
+func (a cStringArray) free() {
+	if len(a) == 0 {
+		return
+	}
+
+	for _, s := range a {
+		C.free(unsafe.Pointer(s))
+	}
+	C.free(unsafe.Pointer(&a[0]))
+}
+
 func (s *SamplingContext) Reset() {
 	C.common_sampler_creset(s.c)
 }
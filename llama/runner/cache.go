@@ -20,6 +20,9 @@ type InputCache struct {
 	// optimize cache eviction for multiple users
 	multiUserCache bool
 
+	// index of the inputs cached in each slot
+	prefixes prefixTree
+
 	lc *llama.Context
 }
 
@@ -56,6 +59,9 @@ type InputCacheSlot struct {
 	// Inputs that are stored in the KV cache
 	Inputs []input
 
+	// key of the lora adapters that Inputs were processed with
+	lora string
+
 	// is this cache actively being processed as part of a sequence?
 	InUse bool
 
@@ -63,7 +69,7 @@ type InputCacheSlot struct {
 	lastUsed time.Time
 }
 
-func (c *InputCache) LoadCacheSlot(prompt []input, cachePrompt bool) (*InputCacheSlot, []input, error) {
+func (c *InputCache) LoadCacheSlot(prompt []input, lora string, cachePrompt bool) (*InputCacheSlot, []input, error) {
 	var slot *InputCacheSlot
 	var numPast int
 	var err error
@@ -75,9 +81,9 @@ func (c *InputCache) LoadCacheSlot(prompt []input, cachePrompt bool) (*InputCach
 	// at the cost of worse performance when we miss the input cache (because it causes
 	// GPU L2 cache misses due to spreading out accesses across VRAM).
 	if !c.multiUserCache {
-		slot, numPast, err = c.findLongestCacheSlot(prompt)
+		slot, numPast, err = c.findLongestCacheSlot(prompt, lora)
 	} else {
-		slot, numPast, err = c.findBestCacheSlot(prompt)
+		slot, numPast, err = c.findBestCacheSlot(prompt, lora)
 	}
 	if err != nil {
 		return nil, nil, err
@@ -89,6 +95,7 @@ func (c *InputCache) LoadCacheSlot(prompt []input, cachePrompt bool) (*InputCach
 
 	slot.InUse = true
 	slot.lastUsed = time.Now()
+	slot.lora = lora
 
 	if numPast == len(prompt) {
 		// Leave one input to sample so we can get a response
@@ -106,11 +113,19 @@ func (c *InputCache) LoadCacheSlot(prompt []input, cachePrompt bool) (*InputCach
 
 	prompt = prompt[numPast:]
 	slot.Inputs = slot.Inputs[:numPast]
+	c.prefixes.set(slot.Id, slot.lora, slot.Inputs, slot.lastUsed)
 
 	return slot, prompt, nil
 }
 
-func (c *InputCache) findLongestCacheSlot(prompt []input) (*InputCacheSlot, int, error) {
+// ReleaseCacheSlot marks slot as no longer being processed, making its inputs
+// available to be shared with other slots
+func (c *InputCache) ReleaseCacheSlot(slot *InputCacheSlot) {
+	slot.InUse = false
+	c.prefixes.set(slot.Id, slot.lora, slot.Inputs, time.Now())
+}
+
+func (c *InputCache) findLongestCacheSlot(prompt []input, lora string) (*InputCacheSlot, int, error) {
 	longest := -1
 	var longestSlot *InputCacheSlot
 
@@ -119,7 +134,7 @@ func (c *InputCache) findLongestCacheSlot(prompt []input) (*InputCacheSlot, int,
 			continue
 		}
 
-		count := countCommonPrefix(s.Inputs, prompt)
+		count := s.commonPrefix(prompt, lora)
 		if count > longest {
 			longest = count
 			longestSlot = &c.slots[i]
@@ -133,52 +148,72 @@ func (c *InputCache) findLongestCacheSlot(prompt []input) (*InputCacheSlot, int,
 	return longestSlot, longest, nil
 }
 
-func (c *InputCache) findBestCacheSlot(prompt []input) (*InputCacheSlot, int, error) {
-	oldest := time.Now()
-	var oldestSlot *InputCacheSlot
-
-	longest := -1
-	var longestSlot *InputCacheSlot
+// findBestCacheSlot finds the longest cached prefix of prompt in any slot,
+// including those in use. If an idle slot holds exactly that prefix, it is
+// used as is. Otherwise the prefix is copied to the idle slot whose inputs, that
+// aren't also cached in other slots, were least recently used.
+func (c *InputCache) findBestCacheSlot(prompt []input, lora string) (*InputCacheSlot, int, error) {
+	longest, node := c.prefixes.match(lora, prompt, time.Now())
+	if node != nil {
+		for id := range node.slots {
+			s := &c.slots[id]
+			if !s.InUse && len(s.Inputs) == longest {
+				return s, longest, nil
+			}
+		}
+	}
 
+	var evict *InputCacheSlot
+	var evictUsed time.Time
 	for i, s := range c.slots {
-		count := countCommonPrefix(s.Inputs, prompt)
-		if count > longest {
-			longest = count
-			longestSlot = &c.slots[i]
+		if s.InUse {
+			continue
 		}
 
-		if s.lastUsed.Compare(oldest) < 0 && !s.InUse {
-			oldest = s.lastUsed
-			oldestSlot = &c.slots[i]
+		used := c.prefixes.unique(s.Id)
+		if evict == nil || used.Before(evictUsed) {
+			evict = &c.slots[i]
+			evictUsed = used
 		}
 	}
 
-	if longest == len(longestSlot.Inputs) && !longestSlot.InUse {
-		return longestSlot, longest, nil
-	}
-
-	if oldestSlot.InUse {
+	if evict == nil {
 		return nil, 0, errors.New("no available cache slots")
 	}
 
-	if len(oldestSlot.Inputs) != 0 {
-		slog.Debug("evicting cache slot", "id", oldestSlot.Id, "inputs", len(oldestSlot.Inputs),
-			"used", oldestSlot.lastUsed)
+	if len(evict.Inputs) != 0 {
+		slog.Debug("evicting cache slot", "id", evict.Id, "inputs", len(evict.Inputs),
+			"used", evict.lastUsed)
 	}
 
-	if longest > 0 && longestSlot != oldestSlot {
-		slog.Debug("forking cache slot", "src", longestSlot.Id, "dst", oldestSlot.Id, "inputs", longest, "total",
-			len(longestSlot.Inputs))
-		oldestSlot.Inputs = make([]input, longest)
-		copy(oldestSlot.Inputs, longestSlot.Inputs[:longest])
+	if longest > 0 && !node.slots[evict.Id] {
+		src := -1
+		for id := range node.slots {
+			src = id
+			break
+		}
+
+		slog.Debug("sharing cache prefix", "src", src, "dst", evict.Id, "inputs", longest)
+		evict.Inputs = make([]input, longest)
+		copy(evict.Inputs, prompt[:longest])
 		// This is only nil for unit tests
 		if c.lc != nil {
-			c.lc.KvCacheSeqRm(oldestSlot.Id, 0, -1)
-			c.lc.KvCacheSeqCp(longestSlot.Id, oldestSlot.Id, 0, longest)
+			c.lc.KvCacheSeqRm(evict.Id, 0, -1)
+			c.lc.KvCacheSeqCp(src, evict.Id, 0, longest)
 		}
 	}
 
-	return oldestSlot, longest, nil
+	return evict, longest, nil
+}
+
+// commonPrefix returns the number of cached inputs that can be reused for prompt.
+// Cached entries are only valid for the lora adapters they were processed with.
+func (s *InputCacheSlot) commonPrefix(prompt []input, lora string) int {
+	if s.lora != lora {
+		return 0
+	}
+
+	return countCommonPrefix(s.Inputs, prompt)
 }
 
 func countCommonPrefix(a []input, b []input) int {
@@ -241,6 +276,7 @@ func (c *InputCache) ShiftCacheSlot(slot *InputCacheSlot, numKeep int) error {
 		slot.Inputs[i-discard] = slot.Inputs[i]
 	}
 	slot.Inputs = slot.Inputs[:len(slot.Inputs)-discard]
+	c.prefixes.set(slot.Id, slot.lora, slot.Inputs, slot.lastUsed)
 
 	return nil
 }
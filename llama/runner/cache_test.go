@@ -76,6 +76,7 @@ func TestFindCacheSlot(t *testing.T) {
 		name    string
 		cache   InputCache
 		prompt  []input
+		lora    string
 		longest expected
 		best    expected
 	}{
@@ -179,8 +180,54 @@ func TestFindCacheSlot(t *testing.T) {
 			}},
 			prompt:  []input{{token: 2}, {token: 3}},
 			longest: expected{result: 0, len: 0},
+			best:    expected{result: 0, len: 0},
+		},
+		{
+			name: "Evict unshared",
+			cache: InputCache{slots: []InputCacheSlot{
+				{
+					Id:       0,
+					Inputs:   []input{{token: 1}},
+					InUse:    false,
+					lastUsed: time.Now().Add(-time.Second),
+				},
+				{
+					Id:       1,
+					Inputs:   []input{{token: 2}},
+					InUse:    false,
+					lastUsed: time.Now().Add(-2 * time.Second),
+				},
+			}},
+			prompt:  []input{{token: 3}},
+			longest: expected{result: 0, len: 0},
 			best:    expected{result: 1, len: 0},
 		},
+		{
+			name: "Share in use",
+			cache: InputCache{slots: []InputCacheSlot{
+				{
+					Id:       0,
+					Inputs:   []input{{token: 1}, {token: 2}, {token: 3}},
+					InUse:    true,
+					lastUsed: time.Now().Add(-time.Second),
+				},
+				{
+					Id:       1,
+					Inputs:   []input{{token: 1}, {token: 2}, {token: 4}},
+					InUse:    true,
+					lastUsed: time.Now().Add(-2 * time.Second),
+				},
+				{
+					Id:       2,
+					Inputs:   []input{{token: 5}},
+					InUse:    false,
+					lastUsed: time.Now().Add(-3 * time.Second),
+				},
+			}},
+			prompt:  []input{{token: 1}, {token: 2}, {token: 6}},
+			longest: expected{result: 2, len: 0},
+			best:    expected{result: 2, len: 2},
+		},
 		{
 			name: "In use",
 			cache: InputCache{slots: []InputCacheSlot{
@@ -201,11 +248,34 @@ func TestFindCacheSlot(t *testing.T) {
 			longest: expected{result: 1, len: 1},
 			best:    expected{result: 1, len: 2},
 		},
+		{
+			name: "Different lora",
+			cache: InputCache{slots: []InputCacheSlot{
+				{
+					Id:       0,
+					Inputs:   []input{{token: 1}, {token: 2}},
+					lora:     "a:1;",
+					InUse:    false,
+					lastUsed: time.Now().Add(-time.Second),
+				},
+				{
+					Id:       1,
+					Inputs:   []input{{token: 1}},
+					lora:     "b:1;",
+					InUse:    false,
+					lastUsed: time.Now().Add(-2 * time.Second),
+				},
+			}},
+			prompt:  []input{{token: 1}, {token: 2}},
+			lora:    "b:1;",
+			longest: expected{result: 1, len: 1},
+			best:    expected{result: 1, len: 1},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run("Longest-"+tt.name, func(t *testing.T) {
-			result, resultLen, err := tt.cache.findLongestCacheSlot(tt.prompt)
+			result, resultLen, err := tt.cache.findLongestCacheSlot(tt.prompt, tt.lora)
 			if err != nil {
 				t.Errorf("findLongestCacheSlot: err %v", err)
 			} else if result.Id != tt.longest.result || resultLen != tt.longest.len {
@@ -217,7 +287,11 @@ func TestFindCacheSlot(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run("Best-"+tt.name, func(t *testing.T) {
-			result, resultLen, err := tt.cache.findBestCacheSlot(tt.prompt)
+			for _, s := range tt.cache.slots {
+				tt.cache.prefixes.set(s.Id, s.lora, s.Inputs, s.lastUsed)
+			}
+
+			result, resultLen, err := tt.cache.findBestCacheSlot(tt.prompt, tt.lora)
 			if err != nil {
 				t.Errorf("findBestCacheSlot: err %v", err)
 			} else if result.Id != tt.best.result || resultLen != tt.best.len {
@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/llama"
+)
+
+// LoraAdapter selects a LoRA adapter and the scale it is applied with for
+// a single sequence
+type LoraAdapter struct {
+	Path  string  `json:"path"`
+	Scale float32 `json:"scale"`
+}
+
+// loraKey returns an identifier for a set of adapters. Sequences with the same
+// key can share a batch and reuse each other's KV cache entries.
+func loraKey(adapters []LoraAdapter) string {
+	if len(adapters) == 0 {
+		return ""
+	}
+
+	sorted := slices.Clone(adapters)
+	slices.SortFunc(sorted, func(a, b LoraAdapter) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	var sb strings.Builder
+	for _, a := range sorted {
+		fmt.Fprintf(&sb, "%s:%g;", a.Path, a.Scale)
+	}
+
+	return sb.String()
+}
+
+// errTooManyLoras is returned when a sequence needs an adapter loaded while
+// all of the adapters that can be loaded at once are in use
+var errTooManyLoras = errors.New("too many lora adapters in use")
+
+// loadedLora is a lora adapter loaded by the runner
+type loadedLora struct {
+	adapter *llama.LoraAdapter
+
+	// refs is the number of sequences using the adapter. Adapters that
+	// are in use are never evicted.
+	refs int
+
+	// lastUsed orders the adapters from least to most recently used
+	lastUsed uint64
+}
+
+// loadLoras makes sure that all of the given adapters have been loaded and
+// holds a reference to each of them until [Server.releaseLoras] is called.
+// Adapters stay loaded after they're released so that switching between them
+// is cheap, until the least recently used one is evicted to make room for
+// another.
+func (s *Server) loadLoras(adapters []LoraAdapter) error {
+	s.loraMu.Lock()
+	defer s.loraMu.Unlock()
+
+	var acquired []LoraAdapter
+	for _, a := range adapters {
+		l, ok := s.loras[a.Path]
+		if !ok {
+			if s.maxLoras > 0 && len(s.loras) >= s.maxLoras && !s.evictLora() {
+				s.release(acquired)
+				return fmt.Errorf("%w (max: %d)", errTooManyLoras, s.maxLoras)
+			}
+
+			slog.Info("loading lora adapter", "path", a.Path)
+			adapter, err := s.model.LoadLoraAdapter(a.Path)
+			if err != nil {
+				s.release(acquired)
+				return fmt.Errorf("%s: %w", a.Path, err)
+			}
+
+			l = &loadedLora{adapter: adapter}
+			s.loras[a.Path] = l
+		}
+
+		l.refs++
+		acquired = append(acquired, a)
+	}
+
+	return nil
+}
+
+// releaseLoras releases the references held by [Server.loadLoras]
+func (s *Server) releaseLoras(adapters []LoraAdapter) {
+	s.loraMu.Lock()
+	defer s.loraMu.Unlock()
+
+	s.release(adapters)
+}
+
+// release releases references to adapters. Requires s.loraMu to be held.
+func (s *Server) release(adapters []LoraAdapter) {
+	for _, a := range adapters {
+		if l, ok := s.loras[a.Path]; ok {
+			l.refs--
+			s.loraClock++
+			l.lastUsed = s.loraClock
+		}
+	}
+}
+
+// evictLora unloads the least recently used adapter that isn't in use,
+// reporting whether there was one. It's freed once it's no longer applied to
+// the context. Requires s.loraMu to be held.
+func (s *Server) evictLora() bool {
+	var path string
+	var evict *loadedLora
+	for p, l := range s.loras {
+		if l.refs == 0 && (evict == nil || l.lastUsed < evict.lastUsed) {
+			path, evict = p, l
+		}
+	}
+
+	if evict == nil {
+		return false
+	}
+
+	slog.Info("unloading lora adapter", "path", path)
+	delete(s.loras, path)
+	s.evictedLoras = append(s.evictedLoras, evict.adapter)
+	return true
+}
+
+// applyLoras switches the adapters used by the context if they differ from
+// the ones used for the previous batch, freeing evicted adapters once they're
+// no longer applied. Requires s.mu to be held.
+func (s *Server) applyLoras(seq *Sequence) error {
+	s.loraMu.Lock()
+	defer s.loraMu.Unlock()
+
+	if seq.loraKey == s.activeLora && len(s.evictedLoras) == 0 {
+		return nil
+	}
+
+	slog.Debug("switching lora adapters", "from", s.activeLora, "to", seq.loraKey)
+
+	s.lc.ClearLoraAdapters()
+
+	for _, adapter := range s.evictedLoras {
+		adapter.Free()
+	}
+	s.evictedLoras = nil
+
+	for _, a := range seq.lora {
+		l, ok := s.loras[a.Path]
+		if !ok {
+			return fmt.Errorf("lora adapter not loaded: %s", a.Path)
+		}
+
+		if err := s.lc.SetLoraAdapter(l.adapter, a.Scale); err != nil {
+			return err
+		}
+	}
+
+	s.activeLora = seq.loraKey
+	return nil
+}
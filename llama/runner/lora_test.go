@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoraKey(t *testing.T) {
+	a := []LoraAdapter{{Path: "a", Scale: 1}, {Path: "b", Scale: 0.5}}
+	b := []LoraAdapter{{Path: "b", Scale: 0.5}, {Path: "a", Scale: 1}}
+
+	if loraKey(a) != loraKey(b) {
+		t.Errorf("loraKey: order should not matter, have %q and %q", loraKey(a), loraKey(b))
+	}
+
+	if loraKey(nil) != "" {
+		t.Errorf("loraKey: have %q, want empty", loraKey(nil))
+	}
+
+	if loraKey(a) == loraKey([]LoraAdapter{{Path: "a", Scale: 1}, {Path: "b", Scale: 1}}) {
+		t.Error("loraKey: scale should be part of the key")
+	}
+}
+
+func TestLoadLoras(t *testing.T) {
+	s := Server{
+		loras: map[string]*loadedLora{
+			"a": {lastUsed: 2},
+			"b": {lastUsed: 1},
+			"c": {refs: 1},
+		},
+		maxLoras: 3,
+	}
+
+	// the least recently used adapter that isn't in use is evicted
+	if !s.evictLora() {
+		t.Fatal("evictLora: expected an adapter to be evicted")
+	}
+
+	if _, ok := s.loras["b"]; ok || len(s.loras) != 2 || len(s.evictedLoras) != 1 {
+		t.Errorf("evictLora: expected b to be evicted, have %v", s.loras)
+	}
+
+	// loaded adapters are reused
+	if err := s.loadLoras([]LoraAdapter{{Path: "a", Scale: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if refs := s.loras["a"].refs; refs != 1 {
+		t.Errorf("refs: have %d, want 1", refs)
+	}
+
+	// releasing makes an adapter the most recently used
+	s.loras["d"] = &loadedLora{}
+	s.releaseLoras([]LoraAdapter{{Path: "a", Scale: 1}})
+	if a := s.loras["a"]; a.refs != 0 || a.lastUsed <= s.loras["d"].lastUsed {
+		t.Errorf("release: have %+v", a)
+	}
+
+	if !s.evictLora() {
+		t.Fatal("evictLora: expected an adapter to be evicted")
+	}
+
+	if _, ok := s.loras["d"]; ok {
+		t.Errorf("evictLora: expected d to be evicted, have %v", s.loras)
+	}
+
+	// nothing can be evicted while every adapter is in use
+	s.loras["a"].refs = 1
+	s.maxLoras = 2
+	err := s.loadLoras([]LoraAdapter{{Path: "a", Scale: 1}, {Path: "e", Scale: 1}})
+	if !errors.Is(err, errTooManyLoras) {
+		t.Fatalf("loadLoras: have %v, want %v", err, errTooManyLoras)
+	}
+
+	// references taken before the error are released
+	if refs := s.loras["a"].refs; refs != 1 {
+		t.Errorf("refs: have %d, want 1", refs)
+	}
+}
@@ -0,0 +1,211 @@
+package runner
+
+import (
+	"slices"
+	"time"
+)
+
+// prefixTree is a radix tree of the inputs cached in each slot. It finds the
+// longest cached prefix of a prompt across all slots without comparing the
+// prompt against each of them.
+//
+// Slots with a common prefix, such as a shared system prompt, hold the same
+// nodes. Copying a prefix to another slot with KvCacheSeqCp shares its KV
+// cache entries rather than duplicating them, so a prefix held by many slots
+// is only stored once.
+type prefixTree struct {
+	// roots is the tree for each set of lora adapters, as cached inputs
+	// are only valid for the adapters they were processed with
+	roots map[string]*prefixNode
+
+	// leaves is the last node of each slot's cached inputs
+	leaves map[int]*prefixNode
+}
+
+type prefixNode struct {
+	// inputs following the parent node's
+	inputs []input
+
+	parent   *prefixNode
+	children []*prefixNode
+
+	// slots that have the inputs up to and including this node cached
+	slots map[int]bool
+
+	// last time a slot holding this node was used or the node was
+	// matched by a prompt
+	lastUsed time.Time
+}
+
+// set replaces the inputs indexed for slot. used is the time the slot was
+// last used.
+func (t *prefixTree) set(slot int, lora string, inputs []input, used time.Time) {
+	t.remove(slot)
+
+	if len(inputs) == 0 {
+		return
+	}
+
+	if t.roots == nil {
+		t.roots = make(map[string]*prefixNode)
+		t.leaves = make(map[int]*prefixNode)
+	}
+
+	node, ok := t.roots[lora]
+	if !ok {
+		node = &prefixNode{slots: make(map[int]bool)}
+		t.roots[lora] = node
+	}
+
+	for len(inputs) > 0 {
+		child, n := node.child(inputs)
+		switch {
+		case child == nil:
+			child = &prefixNode{inputs: slices.Clone(inputs), parent: node, slots: make(map[int]bool)}
+			node.children = append(node.children, child)
+			n = len(inputs)
+		case n < len(child.inputs):
+			child = child.split(n)
+		}
+
+		child.slots[slot] = true
+		child.touch(used)
+
+		node = child
+		inputs = inputs[n:]
+	}
+
+	t.leaves[slot] = node
+}
+
+// remove drops the inputs indexed for slot. Nodes no other slot holds are
+// removed from the tree.
+func (t *prefixTree) remove(slot int) {
+	node, ok := t.leaves[slot]
+	if !ok {
+		return
+	}
+	delete(t.leaves, slot)
+
+	for ; node.parent != nil; node = node.parent {
+		delete(node.slots, slot)
+		switch {
+		case len(node.slots) == 0:
+			node.parent.children = slices.DeleteFunc(node.parent.children, func(n *prefixNode) bool { return n == node })
+		case len(node.children) == 1 && len(node.children[0].slots) == len(node.slots):
+			// the child is now held by the same slots so they can be joined
+			t.merge(node)
+		}
+	}
+}
+
+// match returns the number of inputs at the start of prompt that are cached
+// and the node holding the last of them, or nil if there are none. Matched
+// nodes are marked as used.
+func (t *prefixTree) match(lora string, prompt []input, used time.Time) (int, *prefixNode) {
+	node, ok := t.roots[lora]
+	if !ok {
+		return 0, nil
+	}
+
+	var count int
+	var last *prefixNode
+	for count < len(prompt) {
+		child, n := node.child(prompt[count:])
+		if child == nil {
+			break
+		}
+
+		child.touch(used)
+		count += n
+		last = child
+
+		if n < len(child.inputs) {
+			break
+		}
+
+		node = child
+	}
+
+	return count, last
+}
+
+// unique returns the last time the inputs only slot holds were used, or the
+// zero time if all of its inputs are also held by other slots. Reusing the
+// slot with the oldest unique inputs loses the least recently used prefix.
+func (t *prefixTree) unique(slot int) time.Time {
+	var used time.Time
+	for node := t.leaves[slot]; node != nil && node.parent != nil && len(node.slots) == 1; node = node.parent {
+		if node.lastUsed.After(used) {
+			used = node.lastUsed
+		}
+	}
+
+	return used
+}
+
+// child returns the child whose inputs start with inputs[0] and the number of
+// inputs they have in common
+func (n *prefixNode) child(inputs []input) (*prefixNode, int) {
+	for _, child := range n.children {
+		count := countCommonPrefix(child.inputs, inputs)
+		if count > 0 {
+			return child, count
+		}
+	}
+
+	return nil, 0
+}
+
+// split divides n after its first i inputs, returning the new node holding
+// them
+func (n *prefixNode) split(i int) *prefixNode {
+	head := &prefixNode{
+		inputs:   n.inputs[:i:i],
+		parent:   n.parent,
+		children: []*prefixNode{n},
+		slots:    make(map[int]bool, len(n.slots)),
+		lastUsed: n.lastUsed,
+	}
+
+	for slot := range n.slots {
+		head.slots[slot] = true
+	}
+
+	for i, child := range n.parent.children {
+		if child == n {
+			n.parent.children[i] = head
+		}
+	}
+
+	n.inputs = n.inputs[i:]
+	n.parent = head
+	return head
+}
+
+// merge joins n with its only child
+func (t *prefixTree) merge(n *prefixNode) {
+	child := n.children[0]
+
+	n.inputs = append(n.inputs[:len(n.inputs):len(n.inputs)], child.inputs...)
+	n.children = child.children
+	for _, c := range n.children {
+		c.parent = n
+	}
+
+	if child.lastUsed.After(n.lastUsed) {
+		n.lastUsed = child.lastUsed
+	}
+
+	for slot, leaf := range t.leaves {
+		if leaf == child {
+			t.leaves[slot] = n
+		}
+	}
+}
+
+func (n *prefixNode) touch(used time.Time) {
+	if used.After(n.lastUsed) {
+		n.lastUsed = used
+	}
+}
@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"testing"
+	"time"
+)
+
+func tokens(ts ...int) []input {
+	inputs := make([]input, len(ts))
+	for i, t := range ts {
+		inputs[i] = input{token: t}
+	}
+	return inputs
+}
+
+func TestPrefixTreeMatch(t *testing.T) {
+	var tree prefixTree
+	now := time.Now()
+
+	tree.set(0, "", tokens(1, 2, 3, 4), now)
+	tree.set(1, "", tokens(1, 2, 5), now)
+	tree.set(2, "a:1;", tokens(1, 2, 3, 4, 6), now)
+
+	tests := []struct {
+		name   string
+		lora   string
+		prompt []input
+		count  int
+		slots  []int
+	}{
+		{name: "Shared", prompt: tokens(1, 2, 7), count: 2, slots: []int{0, 1}},
+		{name: "Full", prompt: tokens(1, 2, 3, 4, 6), count: 4, slots: []int{0}},
+		{name: "Partial node", prompt: tokens(1, 2, 3), count: 3, slots: []int{0}},
+		{name: "Branch", prompt: tokens(1, 2, 5, 6), count: 3, slots: []int{1}},
+		{name: "None", prompt: tokens(7), count: 0},
+		{name: "Lora", lora: "a:1;", prompt: tokens(1, 2, 3, 4, 6), count: 5, slots: []int{2}},
+		{name: "Unknown lora", lora: "b:1;", prompt: tokens(1, 2), count: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, node := tree.match(tt.lora, tt.prompt, now)
+			if count != tt.count {
+				t.Errorf("count: have %v, want %v", count, tt.count)
+			}
+
+			if len(tt.slots) == 0 {
+				if node != nil {
+					t.Errorf("node: have %v, want nil", node.slots)
+				}
+				return
+			}
+
+			if node == nil || len(node.slots) != len(tt.slots) {
+				t.Fatalf("slots: have %v, want %v", node, tt.slots)
+			}
+
+			for _, slot := range tt.slots {
+				if !node.slots[slot] {
+					t.Errorf("slots: have %v, want %v", node.slots, tt.slots)
+				}
+			}
+		})
+	}
+}
+
+func TestPrefixTreeRemove(t *testing.T) {
+	var tree prefixTree
+	now := time.Now()
+
+	tree.set(0, "", tokens(1, 2, 3), now)
+	tree.set(1, "", tokens(1, 2, 4), now)
+
+	root := tree.roots[""]
+	if len(root.children) != 1 || len(root.children[0].inputs) != 2 || len(root.children[0].children) != 2 {
+		t.Fatalf("expected shared node with two children")
+	}
+
+	// removing one slot joins the shared node with what's left of the other
+	tree.remove(1)
+	if len(root.children) != 1 || len(root.children[0].inputs) != 3 || len(root.children[0].children) != 0 {
+		t.Fatalf("expected single node with 3 inputs, got %v", root.children[0].inputs)
+	}
+
+	if count, _ := tree.match("", tokens(1, 2, 4), now); count != 2 {
+		t.Errorf("count: have %v, want 2", count)
+	}
+
+	// replacing a slot's inputs drops its old ones
+	tree.set(0, "", tokens(5), now)
+	if count, _ := tree.match("", tokens(1, 2), now); count != 0 {
+		t.Errorf("count: have %v, want 0", count)
+	}
+
+	tree.remove(0)
+	if len(root.children) != 0 || len(tree.leaves) != 0 {
+		t.Errorf("expected empty tree")
+	}
+}
+
+func TestPrefixTreeUnique(t *testing.T) {
+	var tree prefixTree
+	now := time.Now()
+
+	tree.set(0, "", tokens(1, 2), now.Add(-3*time.Second))
+	tree.set(1, "", tokens(1, 2, 3), now.Add(-2*time.Second))
+	tree.set(2, "", tokens(4), now.Add(-time.Second))
+
+	// all of slot 0's inputs are also held by slot 1
+	if used := tree.unique(0); !used.IsZero() {
+		t.Errorf("slot 0: have %v, want zero", used)
+	}
+
+	if used := tree.unique(1); !used.Equal(now.Add(-2 * time.Second)) {
+		t.Errorf("slot 1: have %v, want %v", used, now.Add(-2*time.Second))
+	}
+
+	// matching a prompt marks the nodes as used
+	tree.match("", tokens(1, 2, 3), now)
+	if used := tree.unique(1); !used.Equal(now) {
+		t.Errorf("slot 1: have %v, want %v", used, now)
+	}
+
+	if used := tree.unique(3); !used.IsZero() {
+		t.Errorf("slot 3: have %v, want zero", used)
+	}
+}
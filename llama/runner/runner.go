@@ -58,6 +58,13 @@ type Sequence struct {
 	// an image for certain multi-modal models
 	crossAttention bool
 
+	// lora adapters applied while decoding this sequence
+	lora []LoraAdapter
+
+	// identifies the set of lora adapters, sequences with different
+	// keys can't share a batch
+	loraKey string
+
 	// channel to send responses over
 	responses chan string
 
@@ -78,6 +85,13 @@ type Sequence struct {
 	// number of inputs to keep at the beginning when shifting context window
 	numKeep int
 
+	// what to do when the context window is full, see api.Options.ContextOverflow
+	contextOverflow string
+
+	// decode in batches without other sequences so the results don't depend
+	// on what else is running, see api.Options.Deterministic
+	deterministic bool
+
 	// true if an embedding are to be returned instead of text generation
 	embeddingOnly bool
 
@@ -88,16 +102,33 @@ type Sequence struct {
 	startGenerationTime time.Time
 	numDecoded          int
 	numPromptInputs     int
+
+	// number of prompt inputs discarded because the prompt didn't fit
+	numTruncated int
+
+	// number of prompt inputs loaded from the cache rather than processed
+	numCached int
+
+	// receives the number of prompt inputs processed after each batch, if
+	// the request asked for progress
+	progress chan int
 }
 
 type NewSequenceParams struct {
-	numPredict     int
-	stop           []string
-	numKeep        int
-	samplingParams *llama.SamplingParams
-	embedding      bool
+	numPredict      int
+	stop            []string
+	numKeep         int
+	contextOverflow string
+	deterministic   bool
+	samplingParams  *llama.SamplingParams
+	embedding       bool
+	lora            []LoraAdapter
 }
 
+// errContextLength is returned for prompts which don't fit in the context
+// window when they can't be truncated
+var errContextLength = errors.New("prompt exceeds the context length")
+
 func (s *Server) NewSequence(prompt string, images []ImageData, params NewSequenceParams) (*Sequence, error) {
 	s.ready.Wait()
 
@@ -118,11 +149,21 @@ func (s *Server) NewSequence(prompt string, images []ImageData, params NewSequen
 		params.numKeep += 1
 	}
 
+	// keep the start of the conversation and discard from the middle
+	if params.contextOverflow == api.ContextOverflowTruncateMiddle {
+		params.numKeep = max(params.numKeep, s.cache.numCtx/4)
+	}
+
 	// Ensure that at least 1 input can be discarded during shift
 	params.numKeep = min(params.numKeep, s.cache.numCtx-1)
 
+	var discard int
 	if len(inputs) > s.cache.numCtx {
-		discard := len(inputs) - s.cache.numCtx
+		if params.contextOverflow == api.ContextOverflowError {
+			return nil, fmt.Errorf("%w (prompt: %d context: %d)", errContextLength, len(inputs), s.cache.numCtx)
+		}
+
+		discard = len(inputs) - s.cache.numCtx
 		newInputs := inputs[:params.numKeep]
 		newInputs = append(newInputs, inputs[params.numKeep+discard:]...)
 
@@ -130,10 +171,19 @@ func (s *Server) NewSequence(prompt string, images []ImageData, params NewSequen
 		inputs = newInputs
 	}
 
+	if params.lora == nil {
+		params.lora = s.defaultLoras
+	}
+
+	if err := s.loadLoras(params.lora); err != nil {
+		return nil, fmt.Errorf("failed to load lora adapters: %w", err)
+	}
+
 	var sc *llama.SamplingContext
 	if params.samplingParams != nil {
 		sc, err = llama.NewSamplingContext(s.model, *params.samplingParams)
 		if err != nil {
+			s.releaseLoras(params.lora)
 			return nil, err
 		}
 		for _, input := range inputs {
@@ -146,6 +196,7 @@ func (s *Server) NewSequence(prompt string, images []ImageData, params NewSequen
 	return &Sequence{
 		inputs:              inputs,
 		numPromptInputs:     len(inputs),
+		numTruncated:        discard,
 		startProcessingTime: startTime,
 		numPredict:          params.numPredict,
 		pendingResponses:    make([]string, 0),
@@ -156,6 +207,10 @@ func (s *Server) NewSequence(prompt string, images []ImageData, params NewSequen
 		embeddingOnly:       params.embedding,
 		stop:                params.stop,
 		numKeep:             params.numKeep,
+		contextOverflow:     params.contextOverflow,
+		deterministic:       params.deterministic,
+		lora:                params.lora,
+		loraKey:             loraKey(params.lora),
 	}, nil
 }
 
@@ -240,6 +295,10 @@ type Server struct {
 	// TODO (jmorganca): make this n_batch
 	batchSize int
 
+	// maximum number of prompt inputs a sequence adds to a batch while
+	// other sequences are generating, 0 for no limit
+	prefillChunkSize int
+
 	// protects access to everything below this line
 	// this is context state needed for decoding
 	mu sync.Mutex
@@ -262,6 +321,28 @@ type Server struct {
 
 	// next sequence for prompt processing to avoid starvation
 	nextSeq int
+
+	// key of the lora adapters currently applied to lc
+	activeLora string
+
+	// protects loras, loraClock and evictedLoras
+	loraMu sync.Mutex
+
+	// lora adapters that have been loaded, by path
+	loras map[string]*loadedLora
+
+	// maximum number of lora adapters loaded at once, or 0 for no limit
+	maxLoras int
+
+	// counts adapter uses to order them by when they were last used
+	loraClock uint64
+
+	// adapters that were unloaded but may still be applied to lc
+	evictedLoras []*llama.LoraAdapter
+
+	// adapters passed on the command line, used when a request
+	// doesn't select any
+	defaultLoras []LoraAdapter
 }
 
 func (s *Server) allNil() bool {
@@ -306,7 +387,8 @@ func (s *Server) removeSequence(seqIndex int, reason string) {
 	seq.doneReason = reason
 	close(seq.responses)
 	close(seq.embedding)
-	seq.cache.InUse = false
+	s.cache.ReleaseCacheSlot(seq.cache)
+	s.releaseLoras(seq.lora)
 	s.seqs[seqIndex] = nil
 	s.seqsSem.Release(1)
 }
@@ -350,6 +432,51 @@ func (s *Server) run(ctx context.Context) {
 	}
 }
 
+// prefilling reports whether seq is still processing its prompt
+func (seq *Sequence) prefilling() bool {
+	return seq.numDecoded == 0
+}
+
+// prefillLimit returns the maximum number of prompt inputs a sequence can add
+// to the next batch, or 0 for no limit. Prompts are only limited while other
+// sequences are generating so that a long prompt is processed in chunks
+// between their tokens rather than holding them up.
+func (s *Server) prefillLimit() int {
+	if s.prefillChunkSize <= 0 {
+		return 0
+	}
+
+	for _, seq := range s.seqs {
+		if seq != nil && !seq.prefilling() {
+			return s.prefillChunkSize
+		}
+	}
+
+	return 0
+}
+
+// canShareBatch reports whether seq can add inputs to a batch started by
+// first. Deterministic sequences never share a batch so that their results
+// don't depend on what else is running.
+func (seq *Sequence) canShareBatch(first *Sequence) bool {
+	if seq == first {
+		return true
+	}
+
+	return seq.loraKey == first.loraKey && !seq.deterministic && !first.deterministic
+}
+
+// batchLimit returns the number of inputs seq can add to a batch of the given
+// size. Deterministic sequences always fill the batch as they're never sharing
+// it, so their prompts are split the same way every time.
+func (seq *Sequence) batchLimit(batchSize, prefillLimit int) int {
+	if prefillLimit > 0 && !seq.deterministic && seq.prefilling() {
+		return min(batchSize, prefillLimit)
+	}
+
+	return batchSize
+}
+
 // TODO (jmorganca): processBatch should be simplified, removing:
 // * sampling
 // * stop token checking
@@ -365,7 +492,9 @@ func (s *Server) processBatch(tokenBatch *llama.Batch, embedBatch *llama.Batch)
 	defer s.mu.Unlock()
 
 	var batch *llama.Batch
+	var loraSeq *Sequence
 	crossAttention := false
+	prefillLimit := s.prefillLimit()
 
 	seqIdx := s.nextSeq - 1
 	for range s.seqs {
@@ -376,6 +505,14 @@ func (s *Server) processBatch(tokenBatch *llama.Batch, embedBatch *llama.Batch)
 			continue
 		}
 
+		// stop sequences that were canceled, even while processing the prompt
+		select {
+		case <-seq.quit:
+			s.removeSequence(seqIdx, "connection")
+			continue
+		default:
+		}
+
 		// if past the num predict limit
 		if seq.numPredict > 0 && seq.numPredicted >= seq.numPredict {
 			s.removeSequence(seqIdx, "limit")
@@ -385,6 +522,11 @@ func (s *Server) processBatch(tokenBatch *llama.Batch, embedBatch *llama.Batch)
 		for i, input := range seq.inputs {
 			if len(seq.cache.Inputs)+len(seq.pendingInputs)+1 > s.cache.numCtx {
 				if len(seq.pendingInputs) == 0 {
+					if seq.contextOverflow == api.ContextOverflowError {
+						s.removeSequence(seqIdx, "context_length")
+						break
+					}
+
 					err := s.cache.ShiftCacheSlot(seq.cache, seq.numKeep)
 					if err != nil {
 						return err
@@ -407,12 +549,13 @@ func (s *Server) processBatch(tokenBatch *llama.Batch, embedBatch *llama.Batch)
 					batch = embedBatch
 					seq.crossAttention = s.image.NeedCrossAttention(input)
 				}
-			} else if embedding != batch.IsEmbedding() || crossAttention != seq.crossAttention {
+				loraSeq = seq
+			} else if embedding != batch.IsEmbedding() || crossAttention != seq.crossAttention || !seq.canShareBatch(loraSeq) {
 				s.nextSeq = seqIdx
 				break
 			}
 
-			if i >= batch.Size() {
+			if i >= seq.batchLimit(batch.Size(), prefillLimit) {
 				break
 			}
 
@@ -431,6 +574,10 @@ func (s *Server) processBatch(tokenBatch *llama.Batch, embedBatch *llama.Batch)
 
 	s.lc.SetCrossAttention(crossAttention)
 
+	if err := s.applyLoras(loraSeq); err != nil {
+		return err
+	}
+
 	err := s.lc.Decode(batch)
 	if err != nil {
 		return fmt.Errorf("failed to decode batch: %w", err)
@@ -456,6 +603,13 @@ func (s *Server) processBatch(tokenBatch *llama.Batch, embedBatch *llama.Batch)
 
 		// don't sample prompt processing
 		if len(seq.inputs) != 0 {
+			if seq.progress != nil && seq.prefilling() {
+				// drop the update if the last one hasn't been sent yet
+				select {
+				case seq.progress <- seq.numPromptInputs - len(seq.inputs):
+				default:
+				}
+			}
 			continue
 		}
 
@@ -562,6 +716,18 @@ type Options struct {
 	MirostatTau      float32  `json:"mirostat_tau"`
 	MirostatEta      float32  `json:"mirostat_eta"`
 	Stop             []string `json:"stop"`
+
+	DryMultiplier       float32  `json:"dry_multiplier"`
+	DryBase             float32  `json:"dry_base"`
+	DryAllowedLength    int      `json:"dry_allowed_length"`
+	DryPenaltyLastN     int      `json:"dry_penalty_last_n"`
+	DrySequenceBreakers []string `json:"dry_sequence_breakers"`
+	XTCProbability      float32  `json:"xtc_probability"`
+	XTCThreshold        float32  `json:"xtc_threshold"`
+	Samplers            []string `json:"samplers"`
+
+	ContextOverflow string `json:"context_overflow"`
+	Deterministic   bool   `json:"deterministic"`
 }
 
 type ImageData struct {
@@ -576,27 +742,75 @@ type CompletionRequest struct {
 	Grammar     string      `json:"grammar"`
 	CachePrompt bool        `json:"cache_prompt"`
 
+	// Lora selects the adapters for this request, if nil the adapters
+	// the runner was started with are used
+	Lora []LoraAdapter `json:"lora"`
+
+	// Progress sends responses with the number of prompt inputs processed
+	// while the prompt is being processed
+	Progress bool `json:"progress"`
+
+	// Timeout stops the sequence once the request has run for this long,
+	// if set
+	Timeout time.Duration `json:"timeout"`
+
 	Options
 }
 
 type Timings struct {
-	PredictedN  int     `json:"predicted_n"`
-	PredictedMS float64 `json:"predicted_ms"`
-	PromptN     int     `json:"prompt_n"`
-	PromptMS    float64 `json:"prompt_ms"`
+	PredictedN    int     `json:"predicted_n"`
+	PredictedMS   float64 `json:"predicted_ms"`
+	PromptN       int     `json:"prompt_n"`
+	PromptMS      float64 `json:"prompt_ms"`
+	PromptCacheN  int     `json:"prompt_cache_n"`
+	PromptCacheMS float64 `json:"prompt_cache_ms"`
+}
+
+// timings returns the metrics for seq. The time saved by loading inputs from
+// the cache is estimated from the rate the rest of the prompt was processed.
+// A sequence stopped before generating, such as by a timeout, only counts the
+// prompt inputs processed so far.
+func (seq *Sequence) timings() Timings {
+	t := Timings{
+		PromptN:      seq.numPromptInputs,
+		PromptCacheN: seq.numCached,
+		PredictedN:   seq.numDecoded,
+	}
+
+	if seq.startGenerationTime.IsZero() {
+		t.PromptN -= len(seq.inputs)
+		t.PromptMS = float64(time.Since(seq.startProcessingTime).Milliseconds())
+	} else {
+		t.PromptMS = float64(seq.startGenerationTime.Sub(seq.startProcessingTime).Milliseconds())
+		t.PredictedMS = float64(time.Since(seq.startGenerationTime).Milliseconds())
+	}
+
+	if processed := t.PromptN - seq.numCached; processed > 0 {
+		t.PromptCacheMS = t.PromptMS * float64(seq.numCached) / float64(processed)
+	}
+
+	return t
 }
 
 type CompletionResponse struct {
 	Content string `json:"content"`
 	Stop    bool   `json:"stop"`
 
-	Model        string  `json:"model,omitempty"`
-	Prompt       string  `json:"prompt,omitempty"`
-	StoppedLimit bool    `json:"stopped_limit,omitempty"`
-	PredictedN   int     `json:"predicted_n,omitempty"`
-	PredictedMS  float64 `json:"predicted_ms,omitempty"`
-	PromptN      int     `json:"prompt_n,omitempty"`
-	PromptMS     float64 `json:"prompt_ms,omitempty"`
+	Model           string  `json:"model,omitempty"`
+	Prompt          string  `json:"prompt,omitempty"`
+	StoppedLimit    bool    `json:"stopped_limit,omitempty"`
+	StoppedContext  bool    `json:"stopped_context,omitempty"`
+	StoppedTimeout  bool    `json:"stopped_timeout,omitempty"`
+	PromptTruncated int     `json:"prompt_truncated,omitempty"`
+	PredictedN      int     `json:"predicted_n,omitempty"`
+	PredictedMS     float64 `json:"predicted_ms,omitempty"`
+	PromptN         int     `json:"prompt_n,omitempty"`
+	PromptMS        float64 `json:"prompt_ms,omitempty"`
+
+	// PromptProcessed of PromptTotal inputs have been processed, sent
+	// while processing the prompt if the request asked for progress
+	PromptProcessed int `json:"prompt_processed,omitempty"`
+	PromptTotal     int `json:"prompt_total,omitempty"`
 
 	Timings Timings `json:"timings"`
 }
@@ -633,16 +847,36 @@ func (s *Server) completion(w http.ResponseWriter, r *http.Request) {
 	samplingParams.MirostatTau = req.MirostatTau
 	samplingParams.MirostatEta = req.MirostatEta
 	samplingParams.Seed = uint32(req.Seed)
+	samplingParams.DryMultiplier = req.DryMultiplier
+	samplingParams.DryBase = req.DryBase
+	samplingParams.DryAllowedLength = req.DryAllowedLength
+	samplingParams.DryPenaltyLastN = req.DryPenaltyLastN
+	samplingParams.DrySequenceBreakers = req.DrySequenceBreakers
+	samplingParams.XTCProbability = req.XTCProbability
+	samplingParams.XTCThreshold = req.XTCThreshold
+	samplingParams.Samplers = req.Samplers
 	samplingParams.Grammar = req.Grammar
 
 	seq, err := s.NewSequence(req.Prompt, req.Images, NewSequenceParams{
-		numPredict:     req.NumPredict,
-		stop:           req.Stop,
-		numKeep:        req.NumKeep,
-		samplingParams: &samplingParams,
-		embedding:      false,
+		numPredict:      req.NumPredict,
+		stop:            req.Stop,
+		numKeep:         req.NumKeep,
+		contextOverflow: req.ContextOverflow,
+		deterministic:   req.Deterministic,
+		samplingParams:  &samplingParams,
+		embedding:       false,
+		lora:            req.Lora,
 	})
-	if err != nil {
+	if errors.Is(err, errContextLength) {
+		slog.Info("stopping completion", "error", err)
+		if err := json.NewEncoder(w).Encode(&CompletionResponse{Stop: true, StoppedContext: true}); err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode final response: %v", err), http.StatusInternalServerError)
+		}
+		return
+	} else if errors.Is(err, errTooManyLoras) {
+		http.Error(w, fmt.Sprintf("Failed to create new sequence: %v", err), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create new sequence: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -654,6 +888,7 @@ func (s *Server) completion(w http.ResponseWriter, r *http.Request) {
 		} else {
 			slog.Error("Failed to acquire semaphore", "error", err)
 		}
+		s.releaseLoras(seq.lora)
 		return
 	}
 
@@ -661,12 +896,19 @@ func (s *Server) completion(w http.ResponseWriter, r *http.Request) {
 	found := false
 	for i, sq := range s.seqs {
 		if sq == nil {
-			seq.cache, seq.inputs, err = s.cache.LoadCacheSlot(seq.inputs, req.CachePrompt)
+			// cached inputs were processed in different batches, which
+			// changes the results slightly
+			seq.cache, seq.inputs, err = s.cache.LoadCacheSlot(seq.inputs, seq.loraKey, req.CachePrompt && !req.Deterministic)
 			if err != nil {
 				s.mu.Unlock()
+				s.releaseLoras(seq.lora)
 				http.Error(w, fmt.Sprintf("Failed to load cache: %v", err), http.StatusInternalServerError)
 				return
 			}
+			seq.numCached = seq.numPromptInputs - len(seq.inputs)
+			if req.Progress {
+				seq.progress = make(chan int, 1)
+			}
 
 			seq.crossAttention = s.image.NeedCrossAttention(seq.cache.Inputs...)
 
@@ -679,22 +921,52 @@ func (s *Server) completion(w http.ResponseWriter, r *http.Request) {
 	s.mu.Unlock()
 
 	if !found {
+		s.releaseLoras(seq.lora)
 		http.Error(w, "could not find an available sequence", http.StatusInternalServerError)
 		return
 	}
 
+	// the sequence can be stopped both by the timeout and the handler returning
+	quit := sync.OnceFunc(func() { close(seq.quit) })
+
+	var timeout <-chan time.Time
+	if req.Timeout > 0 {
+		timer := time.NewTimer(req.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var timedOut bool
 	for {
 		select {
 		case <-r.Context().Done():
-			close(seq.quit)
+			quit()
 			return
+		case <-timeout:
+			// keep reading responses until the sequence is removed so the
+			// final response has the metrics so far
+			slog.Info("stopping completion", "timeout", req.Timeout)
+			timedOut = true
+			timeout = nil
+			quit()
+		case processed := <-seq.progress:
+			if err := json.NewEncoder(w).Encode(&CompletionResponse{
+				PromptProcessed: processed,
+				PromptTotal:     seq.numPromptInputs,
+			}); err != nil {
+				http.Error(w, fmt.Sprintf("failed to encode response: %v", err), http.StatusInternalServerError)
+				quit()
+				return
+			}
+
+			flusher.Flush()
 		case content, ok := <-seq.responses:
 			if ok {
 				if err := json.NewEncoder(w).Encode(&CompletionResponse{
 					Content: content,
 				}); err != nil {
 					http.Error(w, fmt.Sprintf("failed to encode response: %v", err), http.StatusInternalServerError)
-					close(seq.quit)
+					quit()
 					return
 				}
 
@@ -702,14 +974,12 @@ func (s *Server) completion(w http.ResponseWriter, r *http.Request) {
 			} else {
 				// Send the final response
 				if err := json.NewEncoder(w).Encode(&CompletionResponse{
-					Stop:         true,
-					StoppedLimit: seq.doneReason == "limit",
-					Timings: Timings{
-						PromptN:     seq.numPromptInputs,
-						PromptMS:    float64(seq.startGenerationTime.Sub(seq.startProcessingTime).Milliseconds()),
-						PredictedN:  seq.numDecoded,
-						PredictedMS: float64(time.Since(seq.startGenerationTime).Milliseconds()),
-					},
+					Stop:            true,
+					StoppedLimit:    seq.doneReason == "limit",
+					StoppedContext:  seq.doneReason == "context_length",
+					StoppedTimeout:  timedOut,
+					PromptTruncated: seq.numTruncated,
+					Timings:         seq.timings(),
 				}); err != nil {
 					http.Error(w, fmt.Sprintf("failed to encode final response: %v", err), http.StatusInternalServerError)
 				}
@@ -723,6 +993,10 @@ func (s *Server) completion(w http.ResponseWriter, r *http.Request) {
 type EmbeddingRequest struct {
 	Content     string `json:"content"`
 	CachePrompt bool   `json:"cache_prompt"`
+
+	// Lora selects the adapters for this request, if nil the adapters
+	// the runner was started with are used
+	Lora []LoraAdapter `json:"lora"`
 }
 
 type EmbeddingResponse struct {
@@ -740,8 +1014,11 @@ func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
 
 	slog.Debug("embedding request", "content", req.Content)
 
-	seq, err := s.NewSequence(req.Content, nil, NewSequenceParams{embedding: true})
-	if err != nil {
+	seq, err := s.NewSequence(req.Content, nil, NewSequenceParams{embedding: true, lora: req.Lora})
+	if errors.Is(err, errTooManyLoras) {
+		http.Error(w, fmt.Sprintf("Failed to create new sequence: %v", err), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create new sequence: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -753,6 +1030,7 @@ func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
 		} else {
 			slog.Error("Failed to acquire semaphore", "error", err)
 		}
+		s.releaseLoras(seq.lora)
 		return
 	}
 
@@ -760,9 +1038,10 @@ func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
 	found := false
 	for i, sq := range s.seqs {
 		if sq == nil {
-			seq.cache, seq.inputs, err = s.cache.LoadCacheSlot(seq.inputs, req.CachePrompt)
+			seq.cache, seq.inputs, err = s.cache.LoadCacheSlot(seq.inputs, seq.loraKey, req.CachePrompt)
 			if err != nil {
 				s.mu.Unlock()
+				s.releaseLoras(seq.lora)
 				http.Error(w, fmt.Sprintf("Failed to load cache: %v", err), http.StatusInternalServerError)
 				return
 			}
@@ -775,6 +1054,7 @@ func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
 	s.mu.Unlock()
 
 	if !found {
+		s.releaseLoras(seq.lora)
 		http.Error(w, "could not find an available sequence", http.StatusInternalServerError)
 		return
 	}
@@ -858,13 +1138,18 @@ func (s *Server) loadModel(
 		panic(err)
 	}
 
-	if lpath.String() != "" {
-		for _, path := range lpath {
-			err := s.model.ApplyLoraFromFile(s.lc, path, 1.0, threads)
-			if err != nil {
-				panic(err)
-			}
-		}
+	s.loras = make(map[string]*loadedLora)
+	for _, path := range lpath {
+		s.defaultLoras = append(s.defaultLoras, LoraAdapter{Path: path, Scale: 1.0})
+	}
+
+	// the default adapters are never released so they're always loaded
+	if s.maxLoras > 0 {
+		s.maxLoras = max(s.maxLoras, len(s.defaultLoras))
+	}
+
+	if err := s.loadLoras(s.defaultLoras); err != nil {
+		panic(err)
 	}
 
 	if ppath != "" {
@@ -905,6 +1190,8 @@ func Execute(args []string) error {
 	mlock := fs.Bool("mlock", false, "force system to keep model in RAM rather than swapping or compressing")
 	tensorSplit := fs.String("tensor-split", "", "fraction of the model to offload to each GPU, comma-separated list of proportions")
 	multiUserCache := fs.Bool("multiuser-cache", false, "optimize input cache algorithm for multiple users")
+	prefillChunkSize := fs.Int("prefill-chunk-size", 0, "maximum prompt tokens per sequence in each batch while other sequences are generating (0 = batch size)")
+	maxLoras := fs.Int("max-loras", 0, "maximum number of lora adapters loaded at once (0 = no limit)")
 
 	var lpaths multiLPath
 	fs.Var(&lpaths, "lora", "Path to lora layer file (can be specified multiple times)")
@@ -937,11 +1224,13 @@ func Execute(args []string) error {
 	slog.Info("system", "info", llama.PrintSystemInfo(), "threads", *threads)
 
 	server := &Server{
-		batchSize: *batchSize,
-		parallel:  *parallel,
-		seqs:      make([]*Sequence, *parallel),
-		seqsSem:   semaphore.NewWeighted(int64(*parallel)),
-		status:    ServerStatusLoadingModel,
+		batchSize:        *batchSize,
+		prefillChunkSize: *prefillChunkSize,
+		maxLoras:         *maxLoras,
+		parallel:         *parallel,
+		seqs:             make([]*Sequence, *parallel),
+		seqsSem:          semaphore.NewWeighted(int64(*parallel)),
+		status:           ServerStatusLoadingModel,
 	}
 
 	var tensorSplitFloats []float32
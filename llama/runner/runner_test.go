@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrefillLimit(t *testing.T) {
+	prefilling := &Sequence{}
+	generating := &Sequence{numDecoded: 3}
+
+	tests := []struct {
+		name      string
+		chunkSize int
+		seqs      []*Sequence
+		expected  int
+	}{
+		{
+			name:      "Disabled",
+			chunkSize: 0,
+			seqs:      []*Sequence{prefilling, generating},
+			expected:  0,
+		},
+		{
+			name:      "Only prompts",
+			chunkSize: 64,
+			seqs:      []*Sequence{prefilling, nil, prefilling},
+			expected:  0,
+		},
+		{
+			name:      "Mixed",
+			chunkSize: 64,
+			seqs:      []*Sequence{prefilling, nil, generating},
+			expected:  64,
+		},
+		{
+			name:      "Empty",
+			chunkSize: 64,
+			seqs:      []*Sequence{nil, nil},
+			expected:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Server{prefillChunkSize: tt.chunkSize, seqs: tt.seqs}
+			if limit := s.prefillLimit(); limit != tt.expected {
+				t.Errorf("prefillLimit: have %v, want %v", limit, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTimingsStoppedDuringPrompt(t *testing.T) {
+	seq := Sequence{
+		inputs:              make([]input, 4),
+		numPromptInputs:     10,
+		numCached:           2,
+		startProcessingTime: time.Now().Add(-time.Second),
+	}
+
+	timings := seq.timings()
+	if timings.PromptN != 6 {
+		t.Errorf("PromptN: have %v, want %v", timings.PromptN, 6)
+	}
+
+	if timings.PromptMS < 1000 {
+		t.Errorf("PromptMS: have %v, want at least %v", timings.PromptMS, 1000)
+	}
+
+	if timings.PredictedN != 0 || timings.PredictedMS != 0 {
+		t.Errorf("predicted: have %v in %vms, want none", timings.PredictedN, timings.PredictedMS)
+	}
+}
+
+func TestCanShareBatch(t *testing.T) {
+	seq := &Sequence{}
+	other := &Sequence{}
+	lora := &Sequence{loraKey: "adapter"}
+	deterministic := &Sequence{deterministic: true}
+
+	tests := []struct {
+		name     string
+		seq      *Sequence
+		first    *Sequence
+		expected bool
+	}{
+		{"Same adapters", seq, other, true},
+		{"Different adapters", lora, seq, false},
+		{"Deterministic joining", deterministic, seq, false},
+		{"Joining deterministic", seq, deterministic, false},
+		{"Deterministic on its own", deterministic, deterministic, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if share := tt.seq.canShareBatch(tt.first); share != tt.expected {
+				t.Errorf("canShareBatch: have %v, want %v", share, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBatchLimitSplitsPrompt(t *testing.T) {
+	// split returns the sizes of the batches a prompt is processed in
+	split := func(seq *Sequence, prompt, batchSize, prefillLimit int) []int {
+		var sizes []int
+		for prompt > 0 {
+			n := min(prompt, seq.batchLimit(batchSize, prefillLimit))
+			sizes = append(sizes, n)
+			prompt -= n
+		}
+		return sizes
+	}
+
+	tests := []struct {
+		name          string
+		deterministic bool
+		prefillLimit  int
+		expected      []int
+	}{
+		{"Alone", false, 0, []int{128, 128, 44}},
+		{"Others generating", false, 64, []int{64, 64, 64, 64, 44}},
+		{"Deterministic alone", true, 0, []int{128, 128, 44}},
+		{"Deterministic with others generating", true, 64, []int{128, 128, 44}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := &Sequence{deterministic: tt.deterministic}
+			sizes := split(seq, 300, 128, tt.prefillLimit)
+			if !slices.Equal(sizes, tt.expected) {
+				t.Errorf("batches: have %v, want %v", sizes, tt.expected)
+			}
+		})
+	}
+}
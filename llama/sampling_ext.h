@@ -25,6 +25,18 @@ extern "C"
         float mirostat_eta;
         uint32_t seed;
         char *grammar;
+        float dry_multiplier;
+        float dry_base;
+        int32_t dry_allowed_length;
+        int32_t dry_penalty_last_n;
+        // dry_sequence_breakers and samplers keep their defaults when their
+        // count is 0
+        char **dry_sequence_breakers;
+        size_t n_dry_sequence_breakers;
+        float xtc_probability;
+        float xtc_threshold;
+        char **samplers;
+        size_t n_samplers;
     };
 
     struct common_sampler *common_sampler_cinit(const struct llama_model *model, struct common_sampler_cparams *params);
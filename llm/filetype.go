@@ -111,6 +111,19 @@ func ParseFileType(s string) (fileType, error) {
 	}
 }
 
+// QuantizationTypes returns the names of the types models can be quantized to
+func QuantizationTypes() []string {
+	var types []string
+	for t := fileTypeQ4_0; t < fileTypeBF16; t++ {
+		switch t {
+		case fileTypeQ4_1_F16, fileTypeQ4_2, fileTypeQ4_3:
+			continue
+		}
+		types = append(types, t.String())
+	}
+	return types
+}
+
 func (t fileType) String() string {
 	switch t {
 	case fileTypeF32:
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"slices"
 	"strings"
 	"sync"
@@ -122,6 +123,121 @@ func (kv KV) ChatTemplate() string {
 	return s
 }
 
+// ArrayLen returns the number of elements of v if it's an array value of a KV.
+// The elements themselves are only available if they were decoded with a
+// large enough maxArraySize.
+func ArrayLen(v any) (int, bool) {
+	a, ok := v.(*array)
+	if !ok {
+		return 0, false
+	}
+
+	return a.size, true
+}
+
+// fimKeys are the keys of the tokens that start the prefix, suffix and middle
+// of a fill-in-the-middle prompt, with the names older conversions used
+var fimKeys = [3][]string{
+	{"tokenizer.ggml.fim_pre_token_id", "tokenizer.ggml.prefix_token_id"},
+	{"tokenizer.ggml.fim_suf_token_id", "tokenizer.ggml.suffix_token_id"},
+	{"tokenizer.ggml.fim_mid_token_id", "tokenizer.ggml.middle_token_id"},
+}
+
+// fimTokenIDs returns the ids of the prefix, suffix and middle tokens
+func (kv KV) fimTokenIDs() (ids [3]uint64, ok bool) {
+	for i, keys := range fimKeys {
+		found := false
+		for _, key := range keys {
+			if _, exists := kv[key]; exists {
+				ids[i] = kv.u64(key)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return ids, false
+		}
+	}
+
+	return ids, true
+}
+
+// HasFIMTokens reports whether the tokenizer has the tokens for
+// fill-in-the-middle prompts
+func (kv KV) HasFIMTokens() bool {
+	_, ok := kv.fimTokenIDs()
+	return ok
+}
+
+// FIMTokens returns the prefix, suffix and middle tokens of a fill-in-the-middle
+// prompt. The tokenizer's tokens are only available if they were decoded
+// with a large enough maxArraySize, or read with [ReadFIMTokens].
+func (kv KV) FIMTokens() (prefix, suffix, middle string, ok bool) {
+	ids, ok := kv.fimTokenIDs()
+	if !ok {
+		return "", "", "", false
+	}
+
+	tokens, _ := kv["tokenizer.ggml.tokens"].(*array)
+	if tokens == nil {
+		return "", "", "", false
+	}
+
+	var strs [3]string
+	for i, id := range ids {
+		v, ok := tokens.value(id)
+		if !ok {
+			return "", "", "", false
+		}
+
+		if strs[i], ok = v.(string); !ok {
+			return "", "", "", false
+		}
+	}
+
+	return strs[0], strs[1], strs[2], true
+}
+
+// ReadFIMTokens reads the prefix, suffix and middle tokens of a
+// fill-in-the-middle prompt from a model file, as in [KV.FIMTokens]. Only
+// those tokens are collected from the tokenizer's vocabulary rather than the
+// whole of it.
+func ReadFIMTokens(model string) (prefix, suffix, middle string, ok bool, err error) {
+	f, err := os.Open(model)
+	if err != nil {
+		return "", "", "", false, err
+	}
+	defer f.Close()
+
+	ggml, _, err := DecodeGGML(f, 0)
+	if err != nil {
+		return "", "", "", false, err
+	}
+
+	ids, ok := ggml.KV().fimTokenIDs()
+	if !ok {
+		return "", "", "", false, nil
+	}
+
+	// small vocabularies are already collected
+	if prefix, suffix, middle, ok := ggml.KV().FIMTokens(); ok {
+		return prefix, suffix, middle, true, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", "", false, err
+	}
+
+	ggml, _, err = decodeGGML(f, 0, map[string][]uint64{"tokenizer.ggml.tokens": ids[:]})
+	if err != nil {
+		return "", "", "", false, err
+	}
+
+	prefix, suffix, middle, ok = ggml.KV().FIMTokens()
+	return prefix, suffix, middle, ok, nil
+}
+
 type Tensors struct {
 	Items  []*Tensor
 	Offset uint64
@@ -176,6 +292,47 @@ type Tensor struct {
 	io.WriterTo `json:"-"`
 }
 
+// TypeName returns the name of the tensor's ggml type, e.g. Q4_K
+func (t Tensor) TypeName() string {
+	if int(t.Kind) < len(tensorTypeNames) && tensorTypeNames[t.Kind] != "" {
+		return tensorTypeNames[t.Kind]
+	}
+
+	return fmt.Sprintf("unknown(%d)", t.Kind)
+}
+
+var tensorTypeNames = []string{
+	0:  "F32",
+	1:  "F16",
+	2:  "Q4_0",
+	3:  "Q4_1",
+	6:  "Q5_0",
+	7:  "Q5_1",
+	8:  "Q8_0",
+	9:  "Q8_1",
+	10: "Q2_K",
+	11: "Q3_K",
+	12: "Q4_K",
+	13: "Q5_K",
+	14: "Q6_K",
+	15: "Q8_K",
+	16: "IQ2_XXS",
+	17: "IQ2_XS",
+	18: "IQ3_XXS",
+	19: "IQ1_S",
+	20: "IQ4_NL",
+	21: "IQ3_S",
+	22: "IQ2_S",
+	23: "IQ4_XS",
+	24: "I8",
+	25: "I16",
+	26: "I32",
+	27: "I64",
+	28: "F64",
+	29: "IQ1_M",
+	30: "BF16",
+}
+
 func (t Tensor) block() (n int) {
 	if _, err := fmt.Sscanf(t.Name, "blk.%d.", &n); err != nil {
 		return -1
@@ -318,6 +475,13 @@ func DetectGGMLType(b []byte) string {
 // maxArraySize. If maxArraySize is 0, the default value of 1024 is used. If
 // the maxArraySize is negative, all arrays are collected.
 func DecodeGGML(rs io.ReadSeeker, maxArraySize int) (*GGML, int64, error) {
+	return decodeGGML(rs, maxArraySize, nil)
+}
+
+// decodeGGML decodes a GGML model as in [DecodeGGML], also collecting the
+// values at arrayIndices from the arrays with those keys that are too large
+// to collect whole.
+func decodeGGML(rs io.ReadSeeker, maxArraySize int, arrayIndices map[string][]uint64) (*GGML, int64, error) {
 	if maxArraySize == 0 {
 		maxArraySize = 1024
 	}
@@ -336,9 +500,9 @@ func DecodeGGML(rs io.ReadSeeker, maxArraySize int) (*GGML, int64, error) {
 	case FILE_MAGIC_GGLA:
 		c = &containerGGLA{}
 	case FILE_MAGIC_GGUF_LE:
-		c = &containerGGUF{ByteOrder: binary.LittleEndian, maxArraySize: maxArraySize}
+		c = &containerGGUF{ByteOrder: binary.LittleEndian, maxArraySize: maxArraySize, arrayIndices: arrayIndices}
 	case FILE_MAGIC_GGUF_BE:
-		c = &containerGGUF{ByteOrder: binary.BigEndian, maxArraySize: maxArraySize}
+		c = &containerGGUF{ByteOrder: binary.BigEndian, maxArraySize: maxArraySize, arrayIndices: arrayIndices}
 	default:
 		return nil, 0, errors.New("invalid file magic")
 	}
@@ -1 +1,190 @@
 package llm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFIMTokens(t *testing.T) {
+	cases := []struct {
+		name     string
+		kv       KV
+		arrays   int
+		has      bool
+		expected []string
+	}{
+		{
+			name: "fim keys",
+			kv: KV{
+				"tokenizer.ggml.fim_pre_token_id": uint32(1),
+				"tokenizer.ggml.fim_suf_token_id": uint32(2),
+				"tokenizer.ggml.fim_mid_token_id": uint32(3),
+			},
+			arrays:   -1,
+			has:      true,
+			expected: []string{"<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+		},
+		{
+			name: "older keys",
+			kv: KV{
+				"tokenizer.ggml.prefix_token_id": uint32(1),
+				"tokenizer.ggml.suffix_token_id": uint32(2),
+				"tokenizer.ggml.middle_token_id": uint32(3),
+			},
+			arrays:   -1,
+			has:      true,
+			expected: []string{"<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+		},
+		{
+			name: "missing middle",
+			kv: KV{
+				"tokenizer.ggml.fim_pre_token_id": uint32(1),
+				"tokenizer.ggml.fim_suf_token_id": uint32(2),
+			},
+			arrays: -1,
+		},
+		{
+			name: "out of range",
+			kv: KV{
+				"tokenizer.ggml.fim_pre_token_id": uint32(1),
+				"tokenizer.ggml.fim_suf_token_id": uint32(2),
+				"tokenizer.ggml.fim_mid_token_id": uint32(7),
+			},
+			arrays: -1,
+			has:    true,
+		},
+		{
+			name: "tokens not decoded",
+			kv: KV{
+				"tokenizer.ggml.fim_pre_token_id": uint32(1),
+				"tokenizer.ggml.fim_suf_token_id": uint32(2),
+				"tokenizer.ggml.fim_mid_token_id": uint32(3),
+			},
+			arrays: 2,
+			has:    true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := KV{
+				"general.architecture":  "llama",
+				"tokenizer.ggml.tokens": []string{"<s>", "<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+			}
+			for k, v := range tt.kv {
+				kv[k] = v
+			}
+
+			p := filepath.Join(t.TempDir(), "model.gguf")
+			f, err := os.Create(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			if err := WriteGGUF(f, kv, []Tensor{
+				{Name: "output.weight", Kind: 0, Shape: []uint64{1, 1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			ggml, err := LoadModel(p, tt.arrays)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if has := ggml.KV().HasFIMTokens(); has != tt.has {
+				t.Errorf("HasFIMTokens: expected %v, got %v", tt.has, has)
+			}
+
+			prefix, suffix, middle, ok := ggml.KV().FIMTokens()
+			if ok != (tt.expected != nil) {
+				t.Fatalf("expected ok %v, got %v", tt.expected != nil, ok)
+			}
+
+			if ok && (prefix != tt.expected[0] || suffix != tt.expected[1] || middle != tt.expected[2]) {
+				t.Errorf("expected %v, got %v", tt.expected, []string{prefix, suffix, middle})
+			}
+		})
+	}
+}
+
+func TestReadFIMTokens(t *testing.T) {
+	// larger than the arrays collected by default
+	vocab := make([]string, 2000)
+	for i := range vocab {
+		vocab[i] = fmt.Sprintf("<%d>", i)
+	}
+	vocab[1500], vocab[1501], vocab[1502] = "<fim_prefix>", "<fim_suffix>", "<fim_middle>"
+
+	cases := []struct {
+		name     string
+		tokens   []string
+		ids      []uint32
+		expected []string
+	}{
+		{
+			name:     "small vocabulary",
+			tokens:   []string{"<s>", "<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+			ids:      []uint32{1, 2, 3},
+			expected: []string{"<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+		},
+		{
+			name:     "large vocabulary",
+			tokens:   vocab,
+			ids:      []uint32{1500, 1501, 1502},
+			expected: []string{"<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+		},
+		{
+			name:   "out of range",
+			tokens: vocab,
+			ids:    []uint32{1500, 1501, 2000},
+		},
+		{
+			name:   "missing middle",
+			tokens: vocab,
+			ids:    []uint32{1500, 1501},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := KV{
+				"general.architecture":  "llama",
+				"tokenizer.ggml.tokens": tt.tokens,
+			}
+			for i, id := range tt.ids {
+				kv[fimKeys[i][0]] = id
+			}
+
+			p := filepath.Join(t.TempDir(), "model.gguf")
+			f, err := os.Create(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			if err := WriteGGUF(f, kv, []Tensor{
+				{Name: "output.weight", Kind: 0, Shape: []uint64{1, 1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			prefix, suffix, middle, ok, err := ReadFIMTokens(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok != (tt.expected != nil) {
+				t.Fatalf("expected ok %v, got %v", tt.expected != nil, ok)
+			}
+
+			if ok && (prefix != tt.expected[0] || suffix != tt.expected[1] || middle != tt.expected[2]) {
+				t.Errorf("expected %v, got %v", tt.expected, []string{prefix, suffix, middle})
+			}
+		})
+	}
+}
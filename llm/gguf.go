@@ -35,6 +35,10 @@ type containerGGUF struct {
 	}
 
 	maxArraySize int
+
+	// arrayIndices are values to collect, by key, from arrays too large to
+	// collect whole
+	arrayIndices map[string][]uint64
 }
 
 func (c *containerGGUF) canCollectArray(size int) bool {
@@ -179,7 +183,7 @@ func (llm *gguf) Decode(rs io.ReadSeeker) error {
 		case ggufTypeString:
 			v, err = readGGUFString(llm, rs)
 		case ggufTypeArray:
-			v, err = readGGUFArray(llm, rs)
+			v, err = readGGUFArray(llm, rs, llm.arrayIndices[k])
 		default:
 			return fmt.Errorf("invalid type: %d", t)
 		}
@@ -359,13 +363,48 @@ func writeGGUFString(w io.Writer, s string) error {
 type array struct {
 	size   int
 	values []any
+
+	// picked are the values collected by index when values isn't
+	picked map[uint64]any
+}
+
+// value returns the array's i-th value if it was collected
+func (a *array) value(i uint64) (any, bool) {
+	if a.values != nil {
+		if i >= uint64(len(a.values)) {
+			return nil, false
+		}
+		return a.values[i], true
+	}
+
+	v, ok := a.picked[i]
+	return v, ok
+}
+
+// collect reports whether the array's i-th value is collected, given the
+// indices to collect if the array isn't collected whole
+func (a *array) collect(i uint64, indices []uint64) bool {
+	return a.values != nil || slices.Contains(indices, i)
+}
+
+// store stores the i-th value if it's collected
+func (a *array) store(i uint64, v any, indices []uint64) {
+	switch {
+	case a.values != nil:
+		a.values[i] = v
+	case slices.Contains(indices, i):
+		if a.picked == nil {
+			a.picked = make(map[uint64]any)
+		}
+		a.picked[i] = v
+	}
 }
 
 func (a *array) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a.values)
 }
 
-func readGGUFV1Array(llm *gguf, r io.Reader) (*array, error) {
+func readGGUFV1Array(llm *gguf, r io.Reader, indices []uint64) (*array, error) {
 	t, err := readGGUF[uint32](llm, r)
 	if err != nil {
 		return nil, err
@@ -378,7 +417,7 @@ func readGGUFV1Array(llm *gguf, r io.Reader) (*array, error) {
 
 	a := &array{size: int(n)}
 	if llm.canCollectArray(int(n)) {
-		a.values = make([]any, 0, int(n))
+		a.values = make([]any, int(n))
 	}
 
 	for i := range n {
@@ -415,17 +454,15 @@ func readGGUFV1Array(llm *gguf, r io.Reader) (*array, error) {
 			return nil, err
 		}
 
-		if a.values != nil {
-			a.values[i] = e
-		}
+		a.store(uint64(i), e, indices)
 	}
 
 	return a, nil
 }
 
-func readGGUFArray(llm *gguf, r io.Reader) (*array, error) {
+func readGGUFArray(llm *gguf, r io.Reader, indices []uint64) (*array, error) {
 	if llm.Version == 1 {
-		return readGGUFV1Array(llm, r)
+		return readGGUFV1Array(llm, r, indices)
 	}
 
 	t, err := readGGUF[uint32](llm, r)
@@ -469,7 +506,7 @@ func readGGUFArray(llm *gguf, r io.Reader) (*array, error) {
 		case ggufTypeBool:
 			e, err = readGGUF[bool](llm, r)
 		case ggufTypeString:
-			if a.values != nil {
+			if a.collect(i, indices) {
 				e, err = readGGUFString(llm, r)
 			} else {
 				err = discardGGUFString(llm, r)
@@ -481,9 +518,7 @@ func readGGUFArray(llm *gguf, r io.Reader) (*array, error) {
 			return nil, err
 		}
 
-		if a.values != nil {
-			a.values[i] = e
-		}
+		a.store(i, e, indices)
 	}
 
 	return a, nil
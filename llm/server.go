@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"log/slog"
+	"math"
 	"math/rand"
 	"net"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,13 +37,14 @@ type LlamaServer interface {
 	Ping(ctx context.Context) error
 	WaitUntilRunning(ctx context.Context) error
 	Completion(ctx context.Context, req CompletionRequest, fn func(CompletionResponse)) error
-	Embedding(ctx context.Context, input string) ([]float32, error)
+	Embedding(ctx context.Context, input string, adapters []LoraAdapter) ([]float32, error)
 	Tokenize(ctx context.Context, content string) ([]int, error)
 	Detokenize(ctx context.Context, tokens []int) (string, error)
 	Close() error
 	EstimatedVRAM() uint64 // Total VRAM across all GPUs
 	EstimatedTotal() uint64
 	EstimatedVRAMByGPU(gpuID string) uint64
+	LoadProgress() float32 // Fraction of the model loaded, between 0 and 1
 }
 
 // llmServer is an instance of the llama.cpp server
@@ -62,8 +65,20 @@ type llmServer struct {
 	gpus         discover.GpuInfoList // Recorded just before the model loaded, free space will be incorrect
 	loadDuration time.Duration        // Record how long it took the model to load
 	loadProgress float32
+	progressMu   sync.Mutex
 
 	sem *semaphore.Weighted
+
+	// waiters are the requests waiting for sem, in the order they started
+	// waiting, to report their place in the queue
+	waiters   []*slotWaiter
+	waitersMu sync.Mutex
+}
+
+// slotWaiter is a request waiting for a parallel slot of a runner
+type slotWaiter struct {
+	// moved is signaled when a request ahead of this one stops waiting
+	moved chan struct{}
 }
 
 // LoadModel will load a model from disk. The model must be in the GGML format.
@@ -232,6 +247,14 @@ func NewLlamaServer(gpus discover.GpuInfoList, model string, ggml *GGML, adapter
 		params = append(params, "--multiuser-cache")
 	}
 
+	if n := envconfig.MaxLoras(); n > 0 {
+		params = append(params, "--max-loras", strconv.FormatUint(uint64(n), 10))
+	}
+
+	if n := envconfig.PrefillChunkSize(); n > 0 {
+		params = append(params, "--prefill-chunk-size", strconv.FormatUint(uint64(n), 10))
+	}
+
 	libs := make(map[string]string)
 	if entries, err := os.ReadDir(discover.LibOllamaPath); err == nil {
 		for _, entry := range entries {
@@ -511,7 +534,9 @@ func (s *llmServer) getServerStatus(ctx context.Context) (ServerStatus, error) {
 	case "no slot available":
 		return ServerStatusNoSlotsAvailable, nil
 	case "loading model":
+		s.progressMu.Lock()
 		s.loadProgress = status.Progress
+		s.progressMu.Unlock()
 		return ServerStatusLoadingModel, nil
 	default:
 		return ServerStatusError, fmt.Errorf("server error: %+v", status)
@@ -638,6 +663,12 @@ ws ::= ([ \t\n] ws)?
 
 const maxBufferSize = 512 * format.KiloByte
 
+// completionTimeoutGrace is how long past a request's timeout to wait for the
+// runner to stop the completion itself before giving up on it
+var completionTimeoutGrace = 30 * time.Second
+
+var errCompletionTimeout = errors.New("completion timed out")
+
 type ImageData struct {
 	Data          []byte `json:"data"`
 	ID            int    `json:"id"`
@@ -645,25 +676,53 @@ type ImageData struct {
 }
 
 type completion struct {
-	Content      string `json:"content"`
-	Model        string `json:"model"`
-	Prompt       string `json:"prompt"`
-	Stop         bool   `json:"stop"`
-	StoppedLimit bool   `json:"stopped_limit"`
+	Content        string `json:"content"`
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	Stop           bool   `json:"stop"`
+	StoppedLimit   bool   `json:"stopped_limit"`
+	StoppedContext bool   `json:"stopped_context"`
+	StoppedTimeout bool   `json:"stopped_timeout"`
+
+	// PromptTruncated is the number of prompt tokens the runner discarded
+	PromptTruncated int `json:"prompt_truncated"`
+
+	PromptProcessed int `json:"prompt_processed"`
+	PromptTotal     int `json:"prompt_total"`
 
 	Timings struct {
-		PredictedN  int     `json:"predicted_n"`
-		PredictedMS float64 `json:"predicted_ms"`
-		PromptN     int     `json:"prompt_n"`
-		PromptMS    float64 `json:"prompt_ms"`
+		PredictedN    int     `json:"predicted_n"`
+		PredictedMS   float64 `json:"predicted_ms"`
+		PromptN       int     `json:"prompt_n"`
+		PromptMS      float64 `json:"prompt_ms"`
+		PromptCacheN  int     `json:"prompt_cache_n"`
+		PromptCacheMS float64 `json:"prompt_cache_ms"`
 	}
 }
 
+// LoraAdapter is an adapter and the scale it is applied with for a single completion
+type LoraAdapter struct {
+	Path  string  `json:"path"`
+	Scale float32 `json:"scale"`
+}
+
 type CompletionRequest struct {
 	Prompt  string
 	Format  json.RawMessage
 	Images  []ImageData
 	Options *api.Options
+
+	// Adapters overrides the adapters the server was started with if not nil
+	Adapters []LoraAdapter
+
+	// Progress sends responses with a Status while the prompt is processed
+	Progress bool
+
+	// Timeout stops the completion with the done reason "timeout" once it
+	// has run for this long. Zero means no timeout. If the runner doesn't
+	// stop it, such as when it's stuck decoding, the response is ended
+	// [completionTimeoutGrace] later anyway.
+	Timeout time.Duration
 }
 
 type CompletionResponse struct {
@@ -674,31 +733,73 @@ type CompletionResponse struct {
 	PromptEvalDuration time.Duration
 	EvalCount          int
 	EvalDuration       time.Duration
+
+	// Seed is the seed used for sampling, set when Done
+	Seed int
+
+	// PromptTruncated is the number of tokens discarded from the middle of
+	// the prompt because it didn't fit in the context, set when Done
+	PromptTruncated int
+
+	PromptCacheHitCount      int
+	PromptCacheSavedDuration time.Duration
+
+	// Status is set, without content, for progress before the response starts
+	Status *api.Status
 }
 
 func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn func(CompletionResponse)) error {
+	// choose a random seed here rather than in the runner so it can be
+	// returned to replay the response
+	seed := req.Options.Seed
+	if seed < 0 {
+		seed = rand.Intn(math.MaxInt32) + 1
+	}
+
 	request := map[string]any{
-		"prompt":            req.Prompt,
-		"stream":            true,
-		"n_predict":         req.Options.NumPredict,
-		"n_keep":            req.Options.NumKeep,
-		"main_gpu":          req.Options.MainGPU,
-		"temperature":       req.Options.Temperature,
-		"top_k":             req.Options.TopK,
-		"top_p":             req.Options.TopP,
-		"min_p":             req.Options.MinP,
-		"typical_p":         req.Options.TypicalP,
-		"repeat_last_n":     req.Options.RepeatLastN,
-		"repeat_penalty":    req.Options.RepeatPenalty,
-		"presence_penalty":  req.Options.PresencePenalty,
-		"frequency_penalty": req.Options.FrequencyPenalty,
-		"mirostat":          req.Options.Mirostat,
-		"mirostat_tau":      req.Options.MirostatTau,
-		"mirostat_eta":      req.Options.MirostatEta,
-		"seed":              req.Options.Seed,
-		"stop":              req.Options.Stop,
-		"image_data":        req.Images,
-		"cache_prompt":      true,
+		"prompt":                req.Prompt,
+		"stream":                true,
+		"n_predict":             req.Options.NumPredict,
+		"n_keep":                req.Options.NumKeep,
+		"main_gpu":              req.Options.MainGPU,
+		"temperature":           req.Options.Temperature,
+		"top_k":                 req.Options.TopK,
+		"top_p":                 req.Options.TopP,
+		"min_p":                 req.Options.MinP,
+		"typical_p":             req.Options.TypicalP,
+		"repeat_last_n":         req.Options.RepeatLastN,
+		"repeat_penalty":        req.Options.RepeatPenalty,
+		"presence_penalty":      req.Options.PresencePenalty,
+		"frequency_penalty":     req.Options.FrequencyPenalty,
+		"mirostat":              req.Options.Mirostat,
+		"mirostat_tau":          req.Options.MirostatTau,
+		"mirostat_eta":          req.Options.MirostatEta,
+		"seed":                  seed,
+		"stop":                  req.Options.Stop,
+		"dry_multiplier":        req.Options.DryMultiplier,
+		"dry_base":              req.Options.DryBase,
+		"dry_allowed_length":    req.Options.DryAllowedLength,
+		"dry_penalty_last_n":    req.Options.DryPenaltyLastN,
+		"dry_sequence_breakers": req.Options.DrySequenceBreakers,
+		"xtc_probability":       req.Options.XTCProbability,
+		"xtc_threshold":         req.Options.XTCThreshold,
+		"samplers":              req.Options.Samplers,
+		"context_overflow":      req.Options.ContextOverflow,
+		"deterministic":         req.Options.Deterministic,
+		"image_data":            req.Images,
+		"cache_prompt":          true,
+	}
+
+	if req.Adapters != nil {
+		request["lora"] = req.Adapters
+	}
+
+	if req.Progress {
+		request["progress"] = true
+	}
+
+	if req.Timeout > 0 {
+		request["timeout"] = req.Timeout
 	}
 
 	if len(req.Format) > 0 {
@@ -723,7 +824,14 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 		}
 	}
 
-	if err := s.sem.Acquire(ctx, 1); err != nil {
+	var progress func(api.Status)
+	if req.Progress {
+		progress = func(status api.Status) {
+			fn(CompletionResponse{Status: &status})
+		}
+	}
+
+	if err := s.acquire(ctx, progress); err != nil {
 		if errors.Is(err, context.Canceled) {
 			slog.Info("aborting completion request due to client closing the connection")
 		} else {
@@ -733,14 +841,40 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 	}
 	defer s.sem.Release(1)
 
+	// the runner times out the completion itself, but can't while it's stuck
+	// in a decode so stop waiting for it a little later
+	runCtx := ctx
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeoutCause(ctx, req.Timeout+completionTimeoutGrace, errCompletionTimeout)
+		defer cancel()
+	}
+
+	timedOut := func() bool {
+		if !errors.Is(context.Cause(runCtx), errCompletionTimeout) {
+			return false
+		}
+
+		slog.Warn("runner didn't stop the completion after its timeout", "timeout", req.Timeout)
+		fn(CompletionResponse{
+			Done:       true,
+			DoneReason: "timeout",
+			Seed:       seed,
+		})
+		return true
+	}
+
 	// put an upper limit on num_predict to avoid the model running on forever
 	if req.Options.NumPredict < 0 || req.Options.NumPredict > 10*s.options.NumCtx {
 		req.Options.NumPredict = 10 * s.options.NumCtx
 	}
 
 	// Make sure the server is ready
-	status, err := s.getServerStatusRetry(ctx)
+	status, err := s.getServerStatusRetry(runCtx)
 	if err != nil {
+		if timedOut() {
+			return nil
+		}
 		return err
 	} else if status != ServerStatusReady {
 		return fmt.Errorf("unexpected server status: %s", status.ToString())
@@ -756,7 +890,7 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 	}
 
 	endpoint := fmt.Sprintf("http://127.0.0.1:%d/completion", s.port)
-	serverReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, buffer)
+	serverReq, err := http.NewRequestWithContext(runCtx, http.MethodPost, endpoint, buffer)
 	if err != nil {
 		return fmt.Errorf("error creating POST request: %v", err)
 	}
@@ -764,6 +898,9 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 
 	res, err := http.DefaultClient.Do(serverReq)
 	if err != nil {
+		if timedOut() {
+			return nil
+		}
 		return fmt.Errorf("POST predict: %v", err)
 	}
 	defer res.Body.Close()
@@ -785,9 +922,15 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 	var lastToken string
 	var tokenRepeat int
 
+	// progress is only reported until the response starts
+	var started bool
+
 	for scanner.Scan() {
 		select {
-		case <-ctx.Done():
+		case <-runCtx.Done():
+			if timedOut() {
+				return nil
+			}
 			// This handles the request cancellation
 			return ctx.Err()
 		default:
@@ -806,6 +949,16 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 			if err := json.Unmarshal(evt, &c); err != nil {
 				return fmt.Errorf("error unmarshalling llm prediction response: %v", err)
 			}
+
+			if c.PromptTotal > 0 {
+				if !started {
+					fn(CompletionResponse{
+						Status: &api.Status{Status: api.StatusProcessing, Completed: c.PromptProcessed, Total: c.PromptTotal},
+					})
+				}
+				continue
+			}
+
 			switch {
 			case strings.TrimSpace(c.Content) == lastToken:
 				tokenRepeat++
@@ -821,6 +974,7 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 			}
 
 			if c.Content != "" {
+				started = true
 				fn(CompletionResponse{
 					Content: c.Content,
 				})
@@ -828,8 +982,13 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 
 			if c.Stop {
 				doneReason := "stop"
-				if c.StoppedLimit {
+				switch {
+				case c.StoppedLimit:
 					doneReason = "length"
+				case c.StoppedContext:
+					doneReason = "context_length"
+				case c.StoppedTimeout:
+					doneReason = "timeout"
 				}
 
 				fn(CompletionResponse{
@@ -839,6 +998,11 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 					PromptEvalDuration: parseDurationMs(c.Timings.PromptMS),
 					EvalCount:          c.Timings.PredictedN,
 					EvalDuration:       parseDurationMs(c.Timings.PredictedMS),
+					Seed:               seed,
+					PromptTruncated:    c.PromptTruncated,
+
+					PromptCacheHitCount:      c.Timings.PromptCacheN,
+					PromptCacheSavedDuration: parseDurationMs(c.Timings.PromptCacheMS),
 				})
 				return nil
 			}
@@ -846,6 +1010,10 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 	}
 
 	if err := scanner.Err(); err != nil {
+		if timedOut() {
+			return nil
+		}
+
 		if strings.Contains(err.Error(), "unexpected EOF") || strings.Contains(err.Error(), "forcibly closed") {
 			s.Close()
 			var msg string
@@ -863,16 +1031,80 @@ func (s *llmServer) Completion(ctx context.Context, req CompletionRequest, fn fu
 	return nil
 }
 
+// acquire waits for a parallel slot. If progress isn't nil and there's no
+// free slot, it's called with the request's place among the requests waiting
+// for one whenever that changes.
+func (s *llmServer) acquire(ctx context.Context, progress func(api.Status)) error {
+	w := &slotWaiter{moved: make(chan struct{}, 1)}
+	s.waitersMu.Lock()
+	s.waiters = append(s.waiters, w)
+	s.waitersMu.Unlock()
+	defer s.stopWaiting(w)
+
+	if progress == nil {
+		return s.sem.Acquire(ctx, 1)
+	}
+
+	if s.sem.TryAcquire(1) {
+		return nil
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- s.sem.Acquire(ctx, 1)
+	}()
+
+	var last int
+	for {
+		if position := s.waitPosition(w); position != last {
+			progress(api.Status{Status: api.StatusQueued, Position: position})
+			last = position
+		}
+
+		select {
+		case err := <-acquired:
+			return err
+		case <-w.moved:
+		}
+	}
+}
+
+// waitPosition returns the place of w among the requests waiting for a slot,
+// starting at 1
+func (s *llmServer) waitPosition(w *slotWaiter) int {
+	s.waitersMu.Lock()
+	defer s.waitersMu.Unlock()
+
+	return slices.Index(s.waiters, w) + 1
+}
+
+func (s *llmServer) stopWaiting(w *slotWaiter) {
+	s.waitersMu.Lock()
+	defer s.waitersMu.Unlock()
+
+	i := slices.Index(s.waiters, w)
+	s.waiters = slices.Delete(s.waiters, i, i+1)
+	for _, behind := range s.waiters[i:] {
+		select {
+		case behind.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
 type EmbeddingRequest struct {
-	Content string `json:"content"`
+	Content string        `json:"content"`
+	Lora    []LoraAdapter `json:"lora"`
 }
 
 type EmbeddingResponse struct {
 	Embedding []float32 `json:"embedding"`
 }
 
-func (s *llmServer) Embedding(ctx context.Context, input string) ([]float32, error) {
-	if err := s.sem.Acquire(ctx, 1); err != nil {
+// Embedding computes the embedding of input with the given adapters applied,
+// a nil or empty list applies none.
+func (s *llmServer) Embedding(ctx context.Context, input string, adapters []LoraAdapter) ([]float32, error) {
+	if err := s.acquire(ctx, nil); err != nil {
 		if errors.Is(err, context.Canceled) {
 			slog.Info("aborting embedding request due to client closing the connection")
 		} else {
@@ -890,7 +1122,13 @@ func (s *llmServer) Embedding(ctx context.Context, input string) ([]float32, err
 		return nil, fmt.Errorf("unexpected server status: %s", status.ToString())
 	}
 
-	data, err := json.Marshal(EmbeddingRequest{Content: input})
+	// always send a list so the runner doesn't fall back to the adapters it
+	// was started with, which belong to whichever model loaded it
+	if adapters == nil {
+		adapters = []LoraAdapter{}
+	}
+
+	data, err := json.Marshal(EmbeddingRequest{Content: input, Lora: adapters})
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling embed data: %w", err)
 	}
@@ -1103,6 +1341,12 @@ func (s *llmServer) EstimatedTotal() uint64 {
 	return s.estimate.TotalSize
 }
 
+func (s *llmServer) LoadProgress() float32 {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	return s.loadProgress
+}
+
 func (s *llmServer) EstimatedVRAMByGPU(gpuID string) uint64 {
 	for i, gpu := range s.gpus {
 		if gpu.ID == gpuID {
@@ -4,8 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 
 	"github.com/ollama/ollama/api"
 	"golang.org/x/sync/semaphore"
@@ -70,3 +78,117 @@ func TestLLMServerCompletionFormat(t *testing.T) {
 	}, nil)
 	checkValid(err)
 }
+
+func TestLLMServerAcquireQueued(t *testing.T) {
+	s := &llmServer{sem: semaphore.NewWeighted(1)}
+
+	// a free slot is taken without reporting a status
+	if err := s.acquire(context.Background(), func(status api.Status) {
+		t.Errorf("unexpected status %+v", status)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := func() (chan api.Status, chan error) {
+		statuses := make(chan api.Status, 10)
+		acquired := make(chan error, 1)
+		go func() {
+			acquired <- s.acquire(context.Background(), func(status api.Status) { statuses <- status })
+		}()
+		return statuses, acquired
+	}
+
+	next := func(statuses chan api.Status) api.Status {
+		t.Helper()
+		select {
+		case status := <-statuses:
+			return status
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for status")
+			return api.Status{}
+		}
+	}
+
+	firstStatuses, first := wait()
+	if status := next(firstStatuses); status != (api.Status{Status: api.StatusQueued, Position: 1}) {
+		t.Errorf("expected first to be queued at 1, got %+v", status)
+	}
+
+	secondStatuses, second := wait()
+	if status := next(secondStatuses); status != (api.Status{Status: api.StatusQueued, Position: 2}) {
+		t.Errorf("expected second to be queued at 2, got %+v", status)
+	}
+
+	s.sem.Release(1)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+
+	if status := next(secondStatuses); status != (api.Status{Status: api.StatusQueued, Position: 1}) {
+		t.Errorf("expected second to move up to 1, got %+v", status)
+	}
+
+	s.sem.Release(1)
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+	s.sem.Release(1)
+}
+
+func TestLLMServerCompletionTimeout(t *testing.T) {
+	// a runner that never finishes the completion, like one stuck decoding
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/completion":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grace := completionTimeoutGrace
+	completionTimeoutGrace = 10 * time.Millisecond
+	t.Cleanup(func() { completionTimeoutGrace = grace })
+
+	s := &llmServer{
+		cmd:  &exec.Cmd{},
+		port: port,
+		sem:  semaphore.NewWeighted(1),
+		options: api.Options{
+			Runner: api.Runner{NumCtx: 2048},
+		},
+	}
+
+	var responses []CompletionResponse
+	err = s.Completion(context.Background(), CompletionRequest{
+		Options: &api.Options{Seed: 42},
+		Timeout: 10 * time.Millisecond,
+	}, func(r CompletionResponse) {
+		responses = append(responses, r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []CompletionResponse{{Done: true, DoneReason: "timeout", Seed: 42}}
+	if diff := cmp.Diff(responses, want); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+
+	// the slot is released
+	if !s.sem.TryAcquire(1) {
+		t.Error("expected the slot to be released")
+	}
+}
@@ -632,6 +632,11 @@ func (w *ChatWriter) writeResponse(data []byte) (int, error) {
 		return 0, err
 	}
 
+	// status events have no equivalent in the OpenAI API
+	if chatResponse.Status != nil {
+		return len(data), nil
+	}
+
 	// chat chunk
 	if w.stream {
 		c := toChunk(w.id, chatResponse)
@@ -695,6 +700,11 @@ func (w *CompleteWriter) writeResponse(data []byte) (int, error) {
 		return 0, err
 	}
 
+	// status events have no equivalent in the OpenAI API
+	if generateResponse.Status != nil {
+		return len(data), nil
+	}
+
 	// completion chunk
 	if w.stream {
 		c := toCompleteChunk(w.id, generateResponse)
@@ -368,6 +368,52 @@ func TestChatMiddleware(t *testing.T) {
 	}
 }
 
+func TestChatMiddlewareStatus(t *testing.T) {
+	endpoint := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		for _, res := range []api.ChatResponse{
+			{Model: "test-model", Message: api.Message{Role: "assistant"}, Status: &api.Status{Status: api.StatusQueued, Position: 1}},
+			{Model: "test-model", Message: api.Message{Role: "assistant", Content: "Hello"}},
+			{Model: "test-model", Message: api.Message{Role: "assistant"}, Done: true, DoneReason: "stop"},
+		} {
+			bts, _ := json.Marshal(res)
+			c.Writer.Write(append(bts, '\n'))
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ChatMiddleware())
+	router.Handle(http.MethodPost, "/api/chat", endpoint)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"model": "test-model", "messages": [{"role": "user", "content": "Hello"}], "stream": true}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	var contents []any
+	for _, line := range strings.Split(resp.Body.String(), "\n\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, choice := range chunk.Choices {
+			contents = append(contents, choice.Delta.Content)
+		}
+	}
+
+	if diff := cmp.Diff(contents, []any{"Hello", ""}); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
+
 func TestCompletionsMiddleware(t *testing.T) {
 	type testCase struct {
 		name string
@@ -509,6 +509,15 @@ func TestParseFileParameters(t *testing.T) {
 		"stop <|endoftext|>":           {"stop", "<|endoftext|>"},
 		"stop <|eot_id|>":              {"stop", "<|eot_id|>"},
 		"stop </s>":                    {"stop", "</s>"},
+		"dry_multiplier 0.8":           {"dry_multiplier", "0.8"},
+		"dry_base 1.75":                {"dry_base", "1.75"},
+		"dry_allowed_length 2":         {"dry_allowed_length", "2"},
+		"dry_penalty_last_n -1":        {"dry_penalty_last_n", "-1"},
+		"dry_sequence_breakers ###":    {"dry_sequence_breakers", "###"},
+		"xtc_probability 0.5":          {"xtc_probability", "0.5"},
+		"xtc_threshold 0.1":            {"xtc_threshold", "0.1"},
+		"samplers top_k":               {"samplers", "top_k"},
+		"deterministic true":           {"deterministic", "true"},
 	}
 
 	for k, v := range cases {
@@ -525,3 +525,20 @@ func (b *Buffer) StringNM(n, m int) string {
 	}
 	return s
 }
+
+// redraw prints the prompt and the contents of the buffer from the current
+// cursor position, e.g. after output has been printed below the line
+func (b *Buffer) redraw() {
+	r := b.Buf.Values()
+
+	b.DisplayPos = 0
+	b.Pos = 0
+	b.Buf.Clear()
+	b.LineHasSpace.Clear()
+
+	fmt.Print(b.Prompt.prompt())
+
+	for _, c := range r {
+		b.Add(c)
+	}
+}
@@ -0,0 +1,82 @@
+package readline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// complete completes the line in buf using the instance's completer. The line
+// is extended to the longest prefix shared by all candidates and, if there is
+// more than one candidate, they are listed below the prompt. It returns false
+// if there was nothing to complete.
+func (i *Instance) complete(buf *Buffer) bool {
+	line := buf.String()
+
+	candidates := i.Completer.Complete(line)
+	if len(candidates) == 0 {
+		return false
+	}
+
+	if prefix := commonPrefix(candidates); len(prefix) > len(line) {
+		for _, r := range strings.TrimPrefix(prefix, line) {
+			buf.Add(r)
+		}
+	}
+
+	if len(candidates) > 1 {
+		buf.MoveToEnd()
+		fmt.Println()
+
+		// only show the word being completed rather than the whole line
+		start := strings.LastIndex(line, " ") + 1
+		words := make([]string, len(candidates))
+		for n, c := range candidates {
+			words[n] = c[min(start, len(c)):]
+		}
+
+		printColumns(words, buf.Width)
+		buf.redraw()
+	}
+
+	return true
+}
+
+// commonPrefix returns the longest prefix shared by all of ss
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+
+	prefix := []rune(ss[0])
+	for _, s := range ss[1:] {
+		n := 0
+		for _, r := range s {
+			if n >= len(prefix) || prefix[n] != r {
+				break
+			}
+			n++
+		}
+		prefix = prefix[:n]
+	}
+
+	return string(prefix)
+}
+
+// printColumns prints words in as many columns as fit in width
+func printColumns(words []string, width int) {
+	var colWidth int
+	for _, w := range words {
+		colWidth = max(colWidth, runewidth.StringWidth(w)+2)
+	}
+
+	cols := max(width/colWidth, 1)
+	for n, w := range words {
+		if (n+1)%cols == 0 || n == len(words)-1 {
+			fmt.Println(w)
+		} else {
+			fmt.Print(runewidth.FillRight(w, colWidth))
+		}
+	}
+}
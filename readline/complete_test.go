@@ -0,0 +1,19 @@
+package readline
+
+import "testing"
+
+func TestCommonPrefix(t *testing.T) {
+	cases := map[string][]string{
+		"":          nil,
+		"/se":       {"/set ", "/session "},
+		"/show ":    {"/show "},
+		"/load lla": {"/load llama3 ", "/load llava "},
+		"/load ":    {"/load llama3 ", "/load 日本 "},
+	}
+
+	for expect, ss := range cases {
+		if prefix := commonPrefix(ss); prefix != expect {
+			t.Errorf("commonPrefix(%q) = %q, expected %q", ss, prefix, expect)
+		}
+	}
+}
@@ -118,6 +118,22 @@ func (h *History) Next() (line string) {
 	return line
 }
 
+// Search returns the index of the most recent entry before pos which contains
+// s, or -1 if there is no such entry
+func (h *History) Search(s string, pos int) int {
+	if s == "" {
+		return -1
+	}
+
+	for i := min(pos, h.Size()) - 1; i >= 0; i-- {
+		if line, _ := h.Buf.Get(i); strings.Contains(line, s) {
+			return i
+		}
+	}
+
+	return -1
+}
+
 func (h *History) Size() int {
 	return h.Buf.Size()
 }
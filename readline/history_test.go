@@ -0,0 +1,31 @@
+package readline
+
+import (
+	"testing"
+
+	"github.com/emirpasic/gods/v2/lists/arraylist"
+)
+
+func TestHistorySearch(t *testing.T) {
+	h := &History{Buf: arraylist.New("/set parameter temperature 0.5", "why is the sky blue?", "/set verbose", "hello")}
+
+	cases := []struct {
+		query  string
+		pos    int
+		expect int
+	}{
+		{"/set", h.Size(), 2},
+		{"/set", 2, 0},
+		{"/set", 0, -1},
+		{"sky", h.Size(), 1},
+		{"missing", h.Size(), -1},
+		{"", h.Size(), -1},
+		{"hello", 100, 3},
+	}
+
+	for _, tt := range cases {
+		if n := h.Search(tt.query, tt.pos); n != tt.expect {
+			t.Errorf("Search(%q, %d) = %d, expected %d", tt.query, tt.pos, n, tt.expect)
+		}
+	}
+}
@@ -35,11 +35,27 @@ type Terminal struct {
 	termios any
 }
 
+// Completer returns the candidates for completing line. Each candidate is the
+// complete line including the text which has already been typed.
+type Completer interface {
+	Complete(line string) []string
+}
+
+// CompleterFunc adapts a function to the Completer interface
+type CompleterFunc func(line string) []string
+
+func (f CompleterFunc) Complete(line string) []string {
+	return f(line)
+}
+
 type Instance struct {
-	Prompt   *Prompt
-	Terminal *Terminal
-	History  *History
-	Pasting  bool
+	Prompt    *Prompt
+	Terminal  *Terminal
+	History   *History
+	Completer Completer
+	Pasting   bool
+
+	prefill string
 }
 
 func New(prompt Prompt) (*Instance, error) {
@@ -86,6 +102,10 @@ func (i *Instance) Readline() (string, error) {
 	}()
 
 	buf, _ := NewBuffer(i.Prompt)
+	for _, r := range i.prefill {
+		buf.Add(r)
+	}
+	i.prefill = ""
 
 	var esc bool
 	var escex bool
@@ -93,6 +113,9 @@ func (i *Instance) Readline() (string, error) {
 
 	var currentLineBuf []rune
 
+	// pending holds a key which ended a reverse search and still needs handling
+	var pending rune
+
 	for {
 		// don't show placeholder when pasting unless we're in multiline mode
 		showPlaceholder := !i.Pasting || i.Prompt.UseAlt
@@ -101,7 +124,13 @@ func (i *Instance) Readline() (string, error) {
 			fmt.Print(ColorGrey + ph + CursorLeftN(len(ph)) + ColorDefault)
 		}
 
-		r, err := i.Terminal.Read()
+		var r rune
+		var err error
+		if pending != 0 {
+			r, pending = pending, 0
+		} else {
+			r, err = i.Terminal.Read()
+		}
 
 		if buf.IsEmpty() {
 			fmt.Print(ClearToEOL)
@@ -195,7 +224,19 @@ func (i *Instance) Readline() (string, error) {
 			buf.MoveRight()
 		case CharBackspace, CharCtrlH:
 			buf.Remove()
+		case CharBckSearch:
+			line, key, err := i.reverseSearch(buf)
+			if err != nil {
+				return "", err
+			}
+
+			buf.Replace([]rune(line))
+			pending = key
 		case CharTab:
+			if i.Completer != nil && buf.Pos == buf.Buf.Size() && i.complete(buf) {
+				continue
+			}
+
 			// todo: convert back to real tabs
 			for range 8 {
 				buf.Add(' ')
@@ -238,6 +279,11 @@ func (i *Instance) Readline() (string, error) {
 	}
 }
 
+// Prefill sets the text the next line starts with so it can be edited
+func (i *Instance) Prefill(s string) {
+	i.prefill = s
+}
+
 func (i *Instance) HistoryEnable() {
 	i.History.Enabled = true
 }
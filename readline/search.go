@@ -0,0 +1,76 @@
+package readline
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// reverseSearch incrementally searches backwards through the history as the
+// query is typed. Pressing Ctrl-R again moves to the next older match. It
+// returns the selected line along with the key which ended the search so the
+// caller can handle it, e.g. Enter to submit the line. Ctrl-C or Ctrl-G cancel
+// the search and restore the original line.
+func (i *Instance) reverseSearch(buf *Buffer) (string, rune, error) {
+	original := buf.String()
+	buf.Replace(nil)
+
+	var query []rune
+	var match string
+	var failed bool
+	pos := i.History.Size()
+
+	search := func(from int) {
+		n := i.History.Search(string(query), from)
+		failed = n < 0 && len(query) > 0
+		if n >= 0 {
+			pos = n
+			match, _ = i.History.Buf.Get(n)
+		}
+	}
+
+	for {
+		label := "(reverse-i-search)"
+		if failed {
+			label = "(failed reverse-i-search)"
+		}
+
+		line := fmt.Sprintf("%s`%s': %s", label, string(query), match)
+		// keep the search on a single line so it can be redrawn in place
+		fmt.Print(CursorBOL + ClearToEOL + runewidth.Truncate(line, buf.Width-1, ""))
+
+		r, err := i.Terminal.Read()
+		if err != nil {
+			return "", 0, io.EOF
+		}
+
+		switch r {
+		case CharBckSearch:
+			search(pos)
+		case CharBackspace, CharCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = ""
+				pos = i.History.Size()
+				search(pos)
+			}
+		case CharInterrupt, CharBell:
+			fmt.Print(CursorBOL + ClearToEOL)
+			return original, 0, nil
+		default:
+			if r < CharSpace {
+				fmt.Print(CursorBOL + ClearToEOL)
+				if match == "" {
+					return original, r, nil
+				}
+
+				return match, r, nil
+			}
+
+			query = append(query, r)
+			// the current match may still contain the longer query
+			search(pos + 1)
+		}
+	}
+}
@@ -65,6 +65,15 @@ func (s *Server) CreateHandler(c *gin.Context) {
 			ch <- resp
 		}
 
+		// keep the uploaded blobs from being pruned until the manifest is written
+		var digests []string
+		for _, files := range []map[string]string{r.Files, r.Adapters} {
+			for _, digest := range files {
+				digests = append(digests, digest)
+			}
+		}
+		defer useBlobs(digests...)()
+
 		oldManifest, _ := ParseNamedManifest(name)
 
 		var baseLayers []*layerGGML
@@ -120,7 +129,7 @@ func (s *Server) CreateHandler(c *gin.Context) {
 		}
 
 		if err := createModel(r, name, baseLayers, fn); err != nil {
-			if errors.Is(err, errBadTemplate) {
+			if errors.Is(err, errBadTemplate) || errors.Is(err, errBadOption) {
 				ch <- gin.H{"error": err.Error(), "status": http.StatusBadRequest}
 				return
 			}
@@ -181,6 +190,9 @@ func detectModelTypeFromFiles(files map[string]string) string {
 	for fn := range files {
 		if strings.HasSuffix(fn, ".safetensors") {
 			return "safetensors"
+		} else if strings.HasSuffix(fn, ".pth") || (strings.HasPrefix(filepath.Base(fn), "pytorch_model") && strings.HasSuffix(fn, ".bin")) {
+			// pytorch checkpoints are converted the same way as safetensors
+			return "safetensors"
 		} else if strings.HasSuffix(fn, ".gguf") {
 			return "gguf"
 		} else {
@@ -613,6 +625,21 @@ func setParameters(layers []Layer, p map[string]any) ([]Layer, error) {
 	if err := json.NewEncoder(&b).Encode(p); err != nil {
 		return nil, err
 	}
+
+	// check the parameters as they'll be read back when the model is run
+	var params map[string]any
+	if err := json.Unmarshal(b.Bytes(), &params); err != nil {
+		return nil, err
+	}
+
+	opts := api.DefaultOptions()
+	if err := opts.FromMap(params); err != nil {
+		return nil, err
+	}
+
+	if err := validateOptions(opts); err != nil {
+		return nil, err
+	}
 	layer, err := NewLayer(&b, "application/vnd.ollama.image.params")
 	if err != nil {
 		return nil, err
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/llm"
+	"github.com/ollama/ollama/types/errtypes"
+	"github.com/ollama/ollama/types/model"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+func (s *Server) DiffHandler(c *gin.Context) {
+	var req api.DiffRequest
+	err := c.ShouldBindJSON(&req)
+	switch {
+	case errors.Is(err, io.EOF):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.From == "" || req.To == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+		return
+	}
+
+	var sections [2]map[string][]string
+	for i, name := range []string{req.From, req.To} {
+		sections[i], err = diffSections(name)
+		if err != nil {
+			switch {
+			case os.IsNotExist(err):
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model '%s' not found", name)})
+			case err.Error() == errtypes.InvalidModelNameErrMsg, errors.Is(err, errModelPathInvalid):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+	}
+
+	var sb strings.Builder
+	for _, section := range []string{"manifest", "modelfile", "metadata", "tensors"} {
+		sb.WriteString(unifiedDiff(
+			req.From+"/"+section, req.To+"/"+section,
+			sections[0][section], sections[1][section],
+		))
+	}
+
+	c.JSON(http.StatusOK, api.DiffResponse{Diff: sb.String()})
+}
+
+// diffSections renders the parts of a model that are compared by DiffHandler
+// as lines of text, keyed by section name
+func diffSections(name string) (map[string][]string, error) {
+	n := model.ParseName(name)
+	if !n.IsValid() {
+		return nil, errModelPathInvalid
+	}
+
+	n, err := getExistingName(n)
+	if err != nil {
+		return nil, err
+	}
+
+	mf, err := ParseNamedManifest(n)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := GetModel(n.String())
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string][]string)
+
+	sections["manifest"] = append(sections["manifest"], fmt.Sprintf("config %s %s %d", mf.Config.MediaType, mf.Config.Digest, mf.Config.Size))
+	for _, layer := range mf.Layers {
+		sections["manifest"] = append(sections["manifest"], fmt.Sprintf("layer %s %s %d", layer.MediaType, layer.Digest, layer.Size))
+	}
+
+	sections["modelfile"] = strings.Split(strings.TrimSuffix(m.String(), "\n"), "\n")
+
+	// every array is decoded so arrays that differ aren't shown as the same
+	ggml, err := llm.LoadModel(m.ModelPath, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	kv := ggml.KV()
+	for _, k := range slices.Sorted(maps.Keys(kv)) {
+		sections["metadata"] = append(sections["metadata"], fmt.Sprintf("%s = %s", k, diffValue(kv[k])))
+	}
+
+	for _, t := range ggml.Tensors().Items {
+		sections["tensors"] = append(sections["tensors"], fmt.Sprintf("%s %v %s", t.Name, t.Shape, t.TypeName()))
+	}
+
+	return sections, nil
+}
+
+// diffMaxArray is the number of elements of the longest array shown in full
+const diffMaxArray = 16
+
+// diffValue renders a metadata value. Longer arrays, such as the tokenizer's
+// vocabulary, are summarized by their length and a hash of their elements.
+func diffValue(v any) string {
+	n, ok := llm.ArrayLen(v)
+	if !ok {
+		return fmt.Sprintf("%v", v)
+	}
+
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("[%d elements]", n)
+	}
+
+	if n <= diffMaxArray {
+		return string(bts)
+	}
+
+	return fmt.Sprintf("[%d elements] sha256:%x", n, sha256.Sum256(bts))
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns a unified diff between a and b. It returns an empty
+// string if there are no differences.
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	ops := diffLines(a, b)
+	if !slices.ContainsFunc(ops, func(op diffOp) bool { return op.kind != ' ' }) {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// line numbers (0-based) in a and b at the start of each op
+	lineA, lineB := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		lineA[i+1], lineB[i+1] = lineA[i], lineB[i]
+		if op.kind != '+' {
+			lineA[i+1]++
+		}
+		if op.kind != '-' {
+			lineB[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// extend the hunk until there is a run of unchanged lines long enough to split on
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(ops))
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(lineA[start], lineA[end]-lineA[start]),
+			hunkRange(lineB[start], lineB[end]-lineB[start]))
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", op.kind, op.line)
+		}
+
+		i = end
+	}
+
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines computes a line-based edit script from a to b using the longest
+// common subsequence of the lines that differ
+func diffLines(a, b []string) []diffOp {
+	var prefix, suffix []diffOp
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffOp{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append(suffix, diffOp{' ', a[len(a)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := prefix
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	slices.Reverse(suffix)
+	return append(ops, suffix...)
+}
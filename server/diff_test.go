@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/llm"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name   string
+		a, b   []string
+		expect string
+	}{
+		{
+			name: "identical",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+		},
+		{
+			name:   "changed",
+			a:      []string{"a", "b", "c"},
+			b:      []string{"a", "x", "c"},
+			expect: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name:   "added",
+			a:      []string{},
+			b:      []string{"x"},
+			expect: "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name:   "hunks",
+			a:      []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"},
+			b:      []string{"0", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "13"},
+			expect: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+13\n",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expect, unifiedDiff("a", "b", tt.a, tt.b)); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	p := t.TempDir()
+	t.Setenv("OLLAMA_MODELS", p)
+
+	var s Server
+
+	_, digestA := createBinFile(t, llm.KV{"general.architecture": "llama"}, []llm.Tensor{
+		{Name: "token_embd.weight", Shape: []uint64{1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+	})
+
+	_, digestB := createBinFile(t, llm.KV{"general.architecture": "llama"}, []llm.Tensor{
+		{Name: "token_embd.weight", Shape: []uint64{1}, Kind: 1, WriterTo: bytes.NewReader(make([]byte, 2))},
+	})
+
+	for _, req := range []api.CreateRequest{
+		{Name: "a", Files: map[string]string{"a.gguf": digestA}, Parameters: map[string]any{"temperature": 0.7}},
+		{Name: "b", Files: map[string]string{"b.gguf": digestB}, Parameters: map[string]any{"temperature": 0.2}},
+		{Name: "c", From: "a"},
+	} {
+		stream := false
+		req.Stream = &stream
+		if w := createRequest(t, s.CreateHandler, req); w.Code != http.StatusOK {
+			t.Fatalf("create %s: expected status code 200, actual %d: %s", req.Name, w.Code, w.Body.String())
+		}
+	}
+
+	t.Run("identical", func(t *testing.T) {
+		w := createRequest(t, s.DiffHandler, api.DiffRequest{From: "a", To: "c"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d", w.Code)
+		}
+
+		var resp api.DiffResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Diff != "" {
+			t.Errorf("expected no diff, got %q", resp.Diff)
+		}
+	})
+
+	t.Run("different", func(t *testing.T) {
+		w := createRequest(t, s.DiffHandler, api.DiffRequest{From: "a", To: "b"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d", w.Code)
+		}
+
+		var resp api.DiffResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{
+			"--- a/manifest\n+++ b/manifest\n",
+			"-PARAMETER temperature 0.7\n+PARAMETER temperature 0.2\n",
+			"--- a/tensors\n+++ b/tensors\n@@ -1 +1 @@\n-token_embd.weight [1] F32\n+token_embd.weight [1] F16\n",
+		} {
+			if !strings.Contains(resp.Diff, s) {
+				t.Errorf("expected diff to contain %q, got:\n%s", s, resp.Diff)
+			}
+		}
+
+		if strings.Contains(resp.Diff, "/metadata") {
+			t.Errorf("expected metadata to be unchanged, got:\n%s", resp.Diff)
+		}
+	})
+
+	t.Run("different vocabulary", func(t *testing.T) {
+		// larger than the arrays decoded by default
+		vocab := func(last string) []string {
+			tokens := make([]string, 2000)
+			for i := range tokens {
+				tokens[i] = fmt.Sprintf("<%d>", i)
+			}
+			tokens[len(tokens)-1] = last
+			return tokens
+		}
+
+		for name, tokens := range map[string][]string{"vocab-a": vocab("a"), "vocab-b": vocab("b")} {
+			_, digest := createBinFile(t, llm.KV{
+				"general.architecture":  "llama",
+				"tokenizer.ggml.tokens": tokens,
+				"tokenizer.ggml.scores": []float32{1, 2},
+			}, []llm.Tensor{
+				{Name: "token_embd.weight", Shape: []uint64{1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+			})
+
+			stream := false
+			if w := createRequest(t, s.CreateHandler, api.CreateRequest{Name: name, Files: map[string]string{"model.gguf": digest}, Stream: &stream}); w.Code != http.StatusOK {
+				t.Fatalf("create %s: expected status code 200, actual %d: %s", name, w.Code, w.Body.String())
+			}
+		}
+
+		w := createRequest(t, s.DiffHandler, api.DiffRequest{From: "vocab-a", To: "vocab-b"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d", w.Code)
+		}
+
+		var resp api.DiffResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(resp.Diff, "--- vocab-a/metadata\n+++ vocab-b/metadata\n") {
+			t.Fatalf("expected metadata to differ, got:\n%s", resp.Diff)
+		}
+
+		for _, s := range []string{
+			"-tokenizer.ggml.tokens = [2000 elements] sha256:",
+			"+tokenizer.ggml.tokens = [2000 elements] sha256:",
+			" tokenizer.ggml.scores = [1,2]\n",
+		} {
+			if !strings.Contains(resp.Diff, s) {
+				t.Errorf("expected diff to contain %q, got:\n%s", s, resp.Diff)
+			}
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		w := createRequest(t, s.DiffHandler, api.DiffRequest{From: "a", To: "missing"})
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status code 404, actual %d", w.Code)
+		}
+	})
+}
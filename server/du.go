@@ -0,0 +1,321 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/types/model"
+)
+
+func (s *Server) DiskUsageHandler(c *gin.Context) {
+	ms, err := Manifests(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := diskUsage(ms)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) PruneHandler(c *gin.Context) {
+	var req api.PruneRequest
+	// the request body is optional
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := pruneBlobs(req.DryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// diskUsage reports the size of every file in the blobs directory and which of
+// the manifests in ms reference it
+func diskUsage(ms map[model.Name]*Manifest) (*api.DiskUsageResponse, error) {
+	refs := make(map[string][]string)
+	for n, m := range ms {
+		for _, digest := range manifestBlobs(m) {
+			refs[digest] = append(refs[digest], n.DisplayShortest())
+		}
+	}
+
+	p, err := GetBlobsPath("")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := api.DiskUsageResponse{
+		Models: []api.ModelDiskUsage{},
+		Blobs:  []api.BlobDiskUsage{},
+	}
+
+	sizes := make(map[string]int64)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fi, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			// removed since the directory was read
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		resp.Size += fi.Size()
+
+		digest := strings.ReplaceAll(entry.Name(), "-", ":")
+		if _, err := GetBlobsPath(digest); errors.Is(err, ErrInvalidDigestFormat) {
+			resp.Partial = append(resp.Partial, api.PartialBlob{Name: entry.Name(), Size: fi.Size()})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		sizes[digest] = fi.Size()
+
+		models := append([]string{}, refs[digest]...)
+		slices.Sort(models)
+		resp.Blobs = append(resp.Blobs, api.BlobDiskUsage{Digest: digest, Size: fi.Size(), Models: models})
+	}
+
+	for n, m := range ms {
+		usage := api.ModelDiskUsage{Name: n.DisplayShortest()}
+		for _, digest := range manifestBlobs(m) {
+			size, ok := sizes[digest]
+			if !ok {
+				// the blob is missing so it doesn't use any space
+				continue
+			}
+
+			usage.Size += size
+			if len(refs[digest]) > 1 {
+				usage.Shared += size
+			} else {
+				usage.Unique += size
+			}
+		}
+
+		resp.Models = append(resp.Models, usage)
+	}
+
+	slices.SortFunc(resp.Models, func(a, b api.ModelDiskUsage) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return &resp, nil
+}
+
+// manifestBlobs returns the digests of the blobs the manifest references
+func manifestBlobs(m *Manifest) []string {
+	var digests []string
+	for _, layer := range append(m.Layers, m.Config) {
+		if layer.Digest != "" && !slices.Contains(digests, layer.Digest) {
+			digests = append(digests, layer.Digest)
+		}
+	}
+
+	return digests
+}
+
+// pruneBlobs removes blobs which aren't referenced by any manifest, partial
+// downloads and signatures of manifests that no longer exist. Blobs that are
+// currently being downloaded are kept.
+func pruneBlobs(dryRun bool) (*api.PruneResponse, error) {
+	// hold the lock while reading manifests and removing blobs so a pull or
+	// create either writes its manifest first or still has its blobs marked
+	blobsInUse.mu.Lock()
+	defer blobsInUse.mu.Unlock()
+
+	ms, err := Manifests(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the blobs of manifests that can't be read since they may still
+	// be in use once the manifest is fixed
+	unparsed, err := unparsedManifestBlobs(ms)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := diskUsage(ms)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := api.PruneResponse{Removed: []string{}}
+	remove := func(dir, name string, size int64) {
+		if !dryRun {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				slog.Error("couldn't remove file", "path", filepath.Join(dir, name), "error", err)
+				return
+			}
+		}
+
+		resp.Removed = append(resp.Removed, filepath.ToSlash(filepath.Join(filepath.Base(dir), name)))
+		resp.Size += size
+	}
+
+	blobs, err := GetBlobsPath("")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, blob := range usage.Blobs {
+		name := strings.ReplaceAll(blob.Digest, ":", "-")
+		if len(blob.Models) == 0 && !unparsed[blob.Digest] && blobsInUse.refs[blob.Digest] == 0 && !downloading(blob.Digest) && !recent(filepath.Join(blobs, name)) {
+			remove(blobs, name, blob.Size)
+		}
+	}
+
+	for _, partial := range usage.Partial {
+		// partial downloads are named after the blob they are for, e.g. sha256-<digest>-partial-0
+		if digest, _, ok := strings.Cut(strings.Replace(partial.Name, "-", ":", 1), "-"); ok && downloading(digest) {
+			continue
+		}
+
+		remove(blobs, partial.Name, partial.Size)
+	}
+
+	signatures, err := GetSignaturesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	for _, m := range ms {
+		keep["sha256-"+m.digest] = true
+	}
+
+	for _, entry := range entries {
+		if fi, err := entry.Info(); err == nil && !entry.IsDir() && !keep[entry.Name()] {
+			remove(signatures, entry.Name(), fi.Size())
+		}
+	}
+
+	return &resp, nil
+}
+
+// digestPattern matches the blob digests a manifest file mentions
+var digestPattern = regexp.MustCompile(`sha256[:-][0-9a-f]{64}`)
+
+// unparsedManifestBlobs returns every blob digest mentioned in the manifest
+// files that couldn't be read into ms
+func unparsedManifestBlobs(ms map[model.Name]*Manifest) (map[string]bool, error) {
+	manifests, err := GetManifestPath()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(manifests, "*", "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]bool)
+	for _, match := range matches {
+		if rel, err := filepath.Rel(manifests, match); err == nil {
+			if _, ok := ms[model.ParseNameFromFilepath(rel)]; ok {
+				continue
+			}
+		}
+
+		fi, err := os.Stat(match)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+
+		bts, err := os.ReadFile(match)
+		if err != nil {
+			slog.Warn("couldn't read manifest", "path", match, "error", err)
+			continue
+		}
+
+		slog.Warn("keeping blobs of bad manifest", "path", match)
+		for _, digest := range digestPattern.FindAll(bts, -1) {
+			digests[strings.Replace(string(digest), "-", ":", 1)] = true
+		}
+	}
+
+	return digests, nil
+}
+
+// pruneGracePeriod is how long an unreferenced blob is kept after it was
+// written, so blobs uploaded for a create, or created by one, aren't removed
+// before the model's manifest is written
+const pruneGracePeriod = time.Hour
+
+// recent reports whether the file at path was modified within the grace period
+func recent(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && time.Since(fi.ModTime()) < pruneGracePeriod
+}
+
+// blobsInUse counts the in-flight pulls and creates using each blob. Their
+// blobs aren't referenced by a manifest until the operation finishes.
+var blobsInUse = struct {
+	mu   sync.Mutex
+	refs map[string]int
+}{refs: make(map[string]int)}
+
+// useBlobs marks digests as in use, keeping them from being pruned, until the
+// returned func is called
+func useBlobs(digests ...string) func() {
+	blobsInUse.mu.Lock()
+	defer blobsInUse.mu.Unlock()
+
+	for _, digest := range digests {
+		blobsInUse.refs[digest]++
+	}
+
+	return sync.OnceFunc(func() {
+		blobsInUse.mu.Lock()
+		defer blobsInUse.mu.Unlock()
+
+		for _, digest := range digests {
+			if blobsInUse.refs[digest]--; blobsInUse.refs[digest] <= 0 {
+				delete(blobsInUse.refs, digest)
+			}
+		}
+	})
+}
+
+// downloading reports whether the blob is being pulled
+func downloading(digest string) bool {
+	_, ok := blobDownloadManager.Load(digest)
+	return ok
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestDiskUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	p := t.TempDir()
+	t.Setenv("OLLAMA_MODELS", p)
+
+	var s Server
+
+	_, digest := createBinFile(t, nil, nil)
+
+	stream := false
+	for _, req := range []api.CreateRequest{
+		{Name: "a", Files: map[string]string{"a.gguf": digest}, Stream: &stream},
+		{Name: "b", Files: map[string]string{"b.gguf": digest}, Parameters: map[string]any{"temperature": 0.2}, Stream: &stream},
+	} {
+		if w := createRequest(t, s.CreateHandler, req); w.Code != http.StatusOK {
+			t.Fatalf("create %s: expected status code 200, actual %d: %s", req.Name, w.Code, w.Body.String())
+		}
+	}
+
+	// blobs are only pruned once they're older than the grace period
+	old := time.Now().Add(-2 * pruneGracePeriod)
+	backdate := func(t *testing.T, digest string) {
+		t.Helper()
+		if err := os.Chtimes(filepath.Join(p, "blobs", "sha256-"+digest[7:]), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, unused := createBinFile(t, map[string]any{"general.architecture": "unused"}, nil)
+	backdate(t, unused)
+
+	// a blob uploaded for a create that hasn't run yet
+	_, uploaded := createBinFile(t, map[string]any{"general.architecture": "uploaded"}, nil)
+
+	// a finished blob of a pull that's still downloading its other layers
+	_, pulled := createBinFile(t, map[string]any{"general.architecture": "pulled"}, nil)
+	backdate(t, pulled)
+	release := useBlobs(pulled, "sha256:"+strings.Repeat("0", 64))
+	defer release()
+
+	partial := filepath.Join(p, "blobs", "sha256-"+digest[7:]+"-partial-0")
+	if err := os.WriteFile(partial, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage := func(t *testing.T) api.DiskUsageResponse {
+		t.Helper()
+
+		w := createRequest(t, s.DiskUsageHandler, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.DiskUsageResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		return resp
+	}
+
+	t.Run("du", func(t *testing.T) {
+		resp := usage(t)
+
+		if len(resp.Models) != 2 || resp.Models[0].Name != "a:latest" || resp.Models[1].Name != "b:latest" {
+			t.Fatalf("unexpected models %+v", resp.Models)
+		}
+
+		for _, m := range resp.Models {
+			if m.Size != m.Unique+m.Shared {
+				t.Errorf("%s: size %d is not unique %d + shared %d", m.Name, m.Size, m.Unique, m.Shared)
+			}
+
+			if m.Shared == 0 {
+				t.Errorf("%s: expected model blob to be shared", m.Name)
+			}
+		}
+
+		if resp.Models[1].Unique <= resp.Models[0].Unique {
+			t.Errorf("expected b to have more unique bytes than a, got %d and %d", resp.Models[1].Unique, resp.Models[0].Unique)
+		}
+
+		i := slices.IndexFunc(resp.Blobs, func(b api.BlobDiskUsage) bool { return b.Digest == digest })
+		if i < 0 {
+			t.Fatalf("expected blob %s in %+v", digest, resp.Blobs)
+		}
+
+		if diff := cmp.Diff([]string{"a:latest", "b:latest"}, resp.Blobs[i].Models); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+
+		i = slices.IndexFunc(resp.Blobs, func(b api.BlobDiskUsage) bool { return b.Digest == unused })
+		if i < 0 || len(resp.Blobs[i].Models) != 0 {
+			t.Errorf("expected unused blob %s in %+v", unused, resp.Blobs)
+		}
+
+		if diff := cmp.Diff([]api.PartialBlob{{Name: filepath.Base(partial), Size: 2}}, resp.Partial); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	expect := []string{
+		"blobs/sha256-" + unused[7:],
+		"blobs/" + filepath.Base(partial),
+	}
+
+	t.Run("prune dry run", func(t *testing.T) {
+		w := createRequest(t, s.PruneHandler, api.PruneRequest{DryRun: true})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.PruneResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(expect, resp.Removed); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+
+		if _, err := os.Stat(partial); err != nil {
+			t.Errorf("expected partial download to be kept: %v", err)
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		before := usage(t)
+
+		w := createRequest(t, s.PruneHandler, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.PruneResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(expect, resp.Removed); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+
+		after := usage(t)
+		if after.Size != before.Size-resp.Size {
+			t.Errorf("expected %d bytes after prune, got %d", before.Size-resp.Size, after.Size)
+		}
+
+		if len(after.Partial) != 0 {
+			t.Errorf("expected no partial blobs, got %+v", after.Partial)
+		}
+
+		var kept []string
+		for _, b := range after.Blobs {
+			if len(b.Models) == 0 {
+				kept = append(kept, b.Digest)
+			}
+		}
+
+		slices.Sort(kept)
+		want := []string{uploaded, pulled}
+		slices.Sort(want)
+		if diff := cmp.Diff(want, kept); diff != "" {
+			t.Errorf("unused blobs mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("prune after pull", func(t *testing.T) {
+		// the pull failed without writing its manifest
+		release()
+
+		w := createRequest(t, s.PruneHandler, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.PruneResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff([]string{"blobs/sha256-" + pulled[7:]}, resp.Removed); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("prune with corrupt manifest", func(t *testing.T) {
+		_, referenced := createBinFile(t, map[string]any{"general.architecture": "referenced"}, nil)
+		backdate(t, referenced)
+
+		_, unreferenced := createBinFile(t, map[string]any{"general.architecture": "unreferenced"}, nil)
+		backdate(t, unreferenced)
+
+		corrupt := filepath.Join(p, "manifests", "registry.ollama.ai", "library", "corrupt", "latest")
+		if err := os.MkdirAll(filepath.Dir(corrupt), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(corrupt, []byte(`{"layers":[{"digest":"`+referenced+`"`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		w := createRequest(t, s.PruneHandler, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code 200, actual %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.PruneResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff([]string{"blobs/sha256-" + unreferenced[7:]}, resp.Removed); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"log"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
@@ -20,6 +21,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ollama/ollama/api"
 	"github.com/ollama/ollama/envconfig"
@@ -51,6 +53,9 @@ type registryOptions struct {
 	Password string
 	Token    string
 
+	// Sign pushes a signature of the manifest made with the local key
+	Sign bool
+
 	CheckRedirect func(req *http.Request, via []*http.Request) error
 }
 
@@ -100,8 +105,12 @@ func (m *Model) CheckCapabilities(caps ...Capability) error {
 				errs = append(errs, errCapabilityTools)
 			}
 		case CapabilityInsert:
-			vars := m.Template.Vars()
-			if !slices.Contains(vars, "suffix") {
+			if slices.Contains(m.Template.Vars(), "suffix") {
+				continue
+			}
+
+			// models can also insert text with their tokenizer's FIM tokens
+			if _, _, _, ok := m.FIMTokens(); !ok {
 				errs = append(errs, errCapabilityInsert)
 			}
 		default:
@@ -117,6 +126,47 @@ func (m *Model) CheckCapabilities(caps ...Capability) error {
 	return nil
 }
 
+// Capabilities returns the capabilities the model has
+func (m *Model) Capabilities() []Capability {
+	var caps []Capability
+	for _, capability := range []Capability{CapabilityCompletion, CapabilityTools, CapabilityInsert} {
+		if m.CheckCapabilities(capability) == nil {
+			caps = append(caps, capability)
+		}
+	}
+
+	return caps
+}
+
+// fimTokens caches the result of [Model.FIMTokens] for each model file. Model
+// files are named by their digest so their tokens never change.
+var fimTokens sync.Map
+
+type fimTokenSet struct {
+	prefix, suffix, middle string
+	ok                     bool
+}
+
+// FIMTokens returns the tokens the model's tokenizer marks the prefix, suffix
+// and middle of a fill-in-the-middle prompt with, if it has them
+func (m *Model) FIMTokens() (prefix, suffix, middle string, ok bool) {
+	if v, ok := fimTokens.Load(m.ModelPath); ok {
+		t := v.(fimTokenSet)
+		return t.prefix, t.suffix, t.middle, t.ok
+	}
+
+	var t fimTokenSet
+	var err error
+	t.prefix, t.suffix, t.middle, t.ok, err = llm.ReadFIMTokens(m.ModelPath)
+	if err != nil {
+		slog.Error("couldn't read model", "error", err)
+		return "", "", "", false
+	}
+
+	fimTokens.Store(m.ModelPath, t)
+	return t.prefix, t.suffix, t.middle, t.ok
+}
+
 func (m *Model) String() string {
 	var modelfile parser.Modelfile
 
@@ -153,8 +203,8 @@ func (m *Model) String() string {
 		})
 	}
 
-	for k, v := range m.Options {
-		switch v := v.(type) {
+	for _, k := range slices.Sorted(maps.Keys(m.Options)) {
+		switch v := m.Options[k].(type) {
 		case []any:
 			for _, s := range v {
 				modelfile.Commands = append(modelfile.Commands, parser.Command{
@@ -398,45 +448,13 @@ func deleteUnusedLayers(deleteMap map[string]struct{}) error {
 }
 
 func PruneLayers() error {
-	deleteMap := make(map[string]struct{})
-	p, err := GetBlobsPath("")
+	resp, err := pruneBlobs(false)
 	if err != nil {
-		return err
-	}
-
-	blobs, err := os.ReadDir(p)
-	if err != nil {
-		slog.Info(fmt.Sprintf("couldn't read dir '%s': %v", p, err))
-		return err
-	}
-
-	for _, blob := range blobs {
-		name := blob.Name()
-		name = strings.ReplaceAll(name, "-", ":")
-
-		_, err := GetBlobsPath(name)
-		if err != nil {
-			if errors.Is(err, ErrInvalidDigestFormat) {
-				// remove invalid blobs (e.g. partial downloads)
-				if err := os.Remove(filepath.Join(p, blob.Name())); err != nil {
-					slog.Error("couldn't remove blob", "blob", blob.Name(), "error", err)
-				}
-			}
-
-			continue
-		}
-
-		deleteMap[name] = struct{}{}
-	}
-
-	slog.Info(fmt.Sprintf("total blobs: %d", len(deleteMap)))
-
-	if err := deleteUnusedLayers(deleteMap); err != nil {
 		slog.Error(fmt.Sprintf("couldn't remove unused layers: %v", err))
 		return nil
 	}
 
-	slog.Info(fmt.Sprintf("total unused blobs removed: %d", len(deleteMap)))
+	slog.Info(fmt.Sprintf("total unused blobs removed: %d", len(resp.Removed)))
 
 	return nil
 }
@@ -488,6 +506,18 @@ func PushModel(ctx context.Context, name string, regOpts *registryOptions, fn fu
 		return err
 	}
 
+	// push the manifest as it's stored so it matches the local copy of its
+	// signature
+	fp, err := mp.GetManifestPath()
+	if err != nil {
+		return err
+	}
+
+	manifestJSON, err := os.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+
 	var layers []Layer
 	layers = append(layers, manifest.Layers...)
 	if manifest.Config.Digest != "" {
@@ -505,11 +535,6 @@ func PushModel(ctx context.Context, name string, regOpts *registryOptions, fn fu
 	requestURL := mp.BaseURL()
 	requestURL = requestURL.JoinPath("v2", mp.GetNamespaceRepository(), "manifests", mp.Tag)
 
-	manifestJSON, err := json.Marshal(manifest)
-	if err != nil {
-		return err
-	}
-
 	headers := make(http.Header)
 	headers.Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
 	resp, err := makeRequestWithRetry(ctx, http.MethodPut, requestURL, headers, bytes.NewReader(manifestJSON), regOpts)
@@ -518,6 +543,12 @@ func PushModel(ctx context.Context, name string, regOpts *registryOptions, fn fu
 	}
 	defer resp.Body.Close()
 
+	if regOpts.Sign {
+		if err := signManifest(ctx, mp, manifestJSON, regOpts, fn); err != nil {
+			return fmt.Errorf("sign manifest: %w", err)
+		}
+	}
+
 	fn(api.ProgressResponse{Status: "success"})
 
 	return nil
@@ -548,17 +579,32 @@ func PullModel(ctx context.Context, name string, regOpts *registryOptions, fn fu
 
 	fn(api.ProgressResponse{Status: "pulling manifest"})
 
-	manifest, err = pullModelManifest(ctx, mp, regOpts)
+	manifest, manifestJSON, err := pullModelManifest(ctx, mp, regOpts)
 	if err != nil {
 		return fmt.Errorf("pull model manifest: %s", err)
 	}
 
+	if signatureRequired(mp.Registry) {
+		fn(api.ProgressResponse{Status: "verifying signature"})
+		if err := verifyManifest(ctx, mp, manifestJSON, regOpts); err != nil {
+			return fmt.Errorf("verify signature: %w", err)
+		}
+	}
+
 	var layers []Layer
 	layers = append(layers, manifest.Layers...)
 	if manifest.Config.Digest != "" {
 		layers = append(layers, manifest.Config)
 	}
 
+	digests := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		digests = append(digests, layer.Digest)
+	}
+
+	// keep the blobs from being pruned until the manifest is written
+	defer useBlobs(digests...)()
+
 	skipVerify := make(map[string]bool)
 	for _, layer := range layers {
 		cacheHit, err := downloadBlob(ctx, downloadOpts{
@@ -598,11 +644,7 @@ func PullModel(ctx context.Context, name string, regOpts *registryOptions, fn fu
 
 	fn(api.ProgressResponse{Status: "writing manifest"})
 
-	manifestJSON, err := json.Marshal(manifest)
-	if err != nil {
-		return err
-	}
-
+	// write the manifest as it was received so it still matches its signature
 	fp, err := mp.GetManifestPath()
 	if err != nil {
 		return err
@@ -629,23 +671,30 @@ func PullModel(ctx context.Context, name string, regOpts *registryOptions, fn fu
 	return nil
 }
 
-func pullModelManifest(ctx context.Context, mp ModelPath, regOpts *registryOptions) (*Manifest, error) {
+// pullModelManifest pulls a manifest from the registry, returning it along with
+// its bytes as received
+func pullModelManifest(ctx context.Context, mp ModelPath, regOpts *registryOptions) (*Manifest, []byte, error) {
 	requestURL := mp.BaseURL().JoinPath("v2", mp.GetNamespaceRepository(), "manifests", mp.Tag)
 
 	headers := make(http.Header)
 	headers.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
 	resp, err := makeRequestWithRetry(ctx, http.MethodGet, requestURL, headers, nil, regOpts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
+	bts, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var m Manifest
-	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
-		return nil, err
+	if err := json.Unmarshal(bts, &m); err != nil {
+		return nil, nil, err
 	}
 
-	return &m, err
+	return &m, bts, nil
 }
 
 // GetSHA256Digest returns the SHA256 hash of a given buffer and returns it, and the size of buffer
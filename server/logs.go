@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ollama/ollama/api"
+)
+
+// maxLogRecords is the number of the most recent log records kept for /api/logs
+const maxLogRecords = 2000
+
+// logBuffer keeps the most recent log records in a ring buffer and sends new
+// records to the clients following them
+type logBuffer struct {
+	mu      sync.Mutex
+	records []api.LogRecord
+	// next is the position the next record is written at once the buffer is full
+	next int
+	size int
+
+	subscribers map[chan api.LogRecord]struct{}
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{size: size, subscribers: make(map[chan api.LogRecord]struct{})}
+}
+
+func (b *logBuffer) add(record api.LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.records) < b.size {
+		b.records = append(b.records, record)
+	} else {
+		b.records[b.next] = record
+		b.next = (b.next + 1) % b.size
+	}
+
+	for ch := range b.subscribers {
+		// drop records for clients which can't keep up rather than block logging
+		select {
+		case ch <- record:
+		default:
+		}
+	}
+}
+
+// tail returns up to n of the most recent records at or above level, or all of
+// them if n is 0, in the order they were logged
+func (b *logBuffer) tail(level slog.Level, n int) []api.LogRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tailLocked(level, n)
+}
+
+func (b *logBuffer) tailLocked(level slog.Level, n int) []api.LogRecord {
+	ordered := append(append([]api.LogRecord{}, b.records[b.next:]...), b.records[:b.next]...)
+
+	records := []api.LogRecord{}
+	for _, r := range ordered {
+		if recordLevel(r) >= level {
+			records = append(records, r)
+		}
+	}
+
+	if n > 0 && len(records) > n {
+		records = records[len(records)-n:]
+	}
+
+	return records
+}
+
+// follow returns the same records as tail along with a channel which receives
+// every record logged after them
+func (b *logBuffer) follow(level slog.Level, n int) ([]api.LogRecord, chan api.LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan api.LogRecord, 256)
+	b.subscribers[ch] = struct{}{}
+	return b.tailLocked(level, n), ch
+}
+
+func (b *logBuffer) unsubscribe(ch chan api.LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, ch)
+}
+
+func recordLevel(r api.LogRecord) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(r.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// logBufferHandler is a slog.Handler which passes records on to another
+// handler and adds them to a logBuffer. Only the records the other handler is
+// enabled for are kept, so debug records are only kept with OLLAMA_DEBUG set
+// rather than filling the buffer and pushing out the records logged by default.
+type logBufferHandler struct {
+	slog.Handler
+
+	buffer *logBuffer
+	attrs  []api.LogAttr
+	group  string
+}
+
+func (h *logBufferHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := api.LogRecord{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Message: r.Message,
+		Attrs:   append([]api.LogAttr{}, h.attrs...),
+	}
+
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		record.Source = filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+	}
+
+	r.Attrs(func(attr slog.Attr) bool {
+		record.Attrs = appendLogAttr(record.Attrs, h.group, attr)
+		return true
+	})
+
+	h.buffer.add(record)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *logBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.Handler = h.Handler.WithAttrs(attrs)
+	nh.attrs = append([]api.LogAttr{}, h.attrs...)
+	for _, attr := range attrs {
+		nh.attrs = appendLogAttr(nh.attrs, h.group, attr)
+	}
+	return &nh
+}
+
+func (h *logBufferHandler) WithGroup(name string) slog.Handler {
+	nh := *h
+	nh.Handler = h.Handler.WithGroup(name)
+	nh.group = groupKey(h.group, name)
+	return &nh
+}
+
+func groupKey(group, key string) string {
+	if group == "" {
+		return key
+	}
+	return group + "." + key
+}
+
+// appendLogAttr flattens groups into keys separated by dots like the text handler
+func appendLogAttr(attrs []api.LogAttr, group string, attr slog.Attr) []api.LogAttr {
+	attr.Value = attr.Value.Resolve()
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			attrs = appendLogAttr(attrs, groupKey(group, attr.Key), a)
+		}
+		return attrs
+	}
+
+	if attr.Equal(slog.Attr{}) {
+		return attrs
+	}
+
+	return append(attrs, api.LogAttr{Key: groupKey(group, attr.Key), Value: attr.Value.String()})
+}
+
+func (s *Server) LogsHandler(c *gin.Context) {
+	var req api.LogsRequest
+	// the request body is optional
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	level := slog.LevelInfo
+	if req.Level != "" {
+		if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "level must be one of debug, info, warn or error"})
+			return
+		}
+	}
+
+	if s.logs == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "logs aren't kept by this server"})
+		return
+	}
+
+	var records []api.LogRecord
+	var follow chan api.LogRecord
+	if req.Follow {
+		records, follow = s.logs.follow(level, req.Tail)
+		defer s.logs.unsubscribe(follow)
+	} else {
+		records = s.logs.tail(level, req.Tail)
+	}
+
+	ch := make(chan any)
+	go func() {
+		defer close(ch)
+		for _, r := range records {
+			select {
+			case ch <- r:
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+
+		if follow == nil {
+			return
+		}
+
+		for {
+			select {
+			case r := <-follow:
+				if recordLevel(r) < level {
+					continue
+				}
+
+				select {
+				case ch <- r:
+				case <-c.Request.Context().Done():
+					return
+				}
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}()
+
+	streamResponse(c, ch)
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/ollama/ollama/api"
@@ -19,29 +20,39 @@ type tokenizeFunc func(context.Context, string) ([]int, error)
 
 var errTooManyImages = errors.New("vision model only supports a single image per message")
 
+// imageNumTokens returns the number of tokens an image takes up in the context window of the model
+func imageNumTokens(m *Model) int {
+	// TODO: Ideally we would compute this from the projector metadata but some pieces are implementation dependent
+	if checkMllamaModelFamily(m) {
+		// Our mllama implementation packs all of the embeddings into a single token
+		return 1
+	}
+
+	// Clip images are represented as 768 tokens, each an embedding
+	return 768
+}
+
 // chatPrompt accepts a list of messages and returns the prompt and images that should be used for the next chat turn.
 // chatPrompt truncates any messages that exceed the context window of the model, making sure to always include 1) the
-// latest message and 2) system messages
-func chatPrompt(ctx context.Context, m *Model, tokenize tokenizeFunc, opts *api.Options, msgs []api.Message, tools []api.Tool) (prompt string, images []llm.ImageData, _ error) {
+// latest message and 2) system messages, and with the truncate_middle context overflow 3) the first message. With the
+// error context overflow nothing is truncated. The indexes of the truncated messages are returned.
+func chatPrompt(ctx context.Context, m *Model, tokenize tokenizeFunc, opts *api.Options, msgs []api.Message, tools []api.Tool) (prompt string, images []llm.ImageData, truncated []int, _ error) {
 	var system []api.Message
 
 	isMllama := checkMllamaModelFamily(m)
+	imageTokens := imageNumTokens(m)
 
-	var imageNumTokens int
-	// TODO: Ideally we would compute this from the projector metadata but some pieces are implementation dependent
-	if isMllama {
-		// Our mllama implementation packs all of the embeddings into a single token
-		imageNumTokens = 1
-	} else {
-		// Clip images are represented as 768 tokens, each an embedding
-		imageNumTokens = 768
+	// keep reports whether a message is kept even if the messages after it don't all fit
+	first := slices.IndexFunc(msgs, func(msg api.Message) bool { return msg.Role != "system" })
+	keep := func(i int) bool {
+		return msgs[i].Role == "system" || (i == first && opts.ContextOverflow == api.ContextOverflowTruncateMiddle)
 	}
 
 	n := len(msgs) - 1
 	// in reverse, find all messages that fit into context window
 	for i := n; i >= 0; i-- {
 		if isMllama && len(msgs[i].Images) > 1 {
-			return "", nil, errTooManyImages
+			return "", nil, nil, errTooManyImages
 		}
 
 		// always include the last message
@@ -49,27 +60,33 @@ func chatPrompt(ctx context.Context, m *Model, tokenize tokenizeFunc, opts *api.
 			continue
 		}
 
+		// the runner stops when the context window is full rather than anything being truncated
+		if opts.ContextOverflow == api.ContextOverflowError {
+			n = i
+			continue
+		}
+
 		system = make([]api.Message, 0)
 		for j := range i {
-			if msgs[j].Role == "system" {
+			if keep(j) {
 				system = append(system, msgs[j])
 			}
 		}
 
 		var b bytes.Buffer
 		if err := m.Template.Execute(&b, template.Values{Messages: append(system, msgs[i:]...), Tools: tools}); err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
 
 		s, err := tokenize(ctx, b.String())
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
 
 		ctxLen := len(s)
 		if m.ProjectorPaths != nil {
 			for _, m := range msgs[i:] {
-				ctxLen += imageNumTokens * len(m.Images)
+				ctxLen += imageTokens * len(m.Images)
 			}
 		}
 
@@ -83,6 +100,12 @@ func chatPrompt(ctx context.Context, m *Model, tokenize tokenizeFunc, opts *api.
 
 	currMsgIdx := n
 
+	for i := range currMsgIdx {
+		if !keep(i) {
+			truncated = append(truncated, i)
+		}
+	}
+
 	for cnt, msg := range msgs[currMsgIdx:] {
 		prefix := ""
 		imgPrompt := ""
@@ -94,18 +117,18 @@ func chatPrompt(ctx context.Context, m *Model, tokenize tokenizeFunc, opts *api.
 			if isMllama {
 				data, opts, err := mllama.Preprocess(bytes.NewReader(i))
 				if err != nil {
-					return "", nil, err
+					return "", nil, nil, err
 				}
 
 				buf := new(bytes.Buffer)
 				err = binary.Write(buf, binary.LittleEndian, data)
 				if err != nil {
-					return "", nil, err
+					return "", nil, nil, err
 				}
 
 				ar, ok := opts["aspectRatioIndex"].(int)
 				if !ok {
-					return "", nil, fmt.Errorf("missing aspect ratio for image")
+					return "", nil, nil, fmt.Errorf("missing aspect ratio for image")
 				}
 
 				imgData = llm.ImageData{
@@ -136,10 +159,85 @@ func chatPrompt(ctx context.Context, m *Model, tokenize tokenizeFunc, opts *api.
 	// truncate any messages that do not fit into the context window
 	var b bytes.Buffer
 	if err := m.Template.Execute(&b, template.Values{Messages: append(system, msgs[currMsgIdx:]...), Tools: tools}); err != nil {
-		return "", nil, err
+		return "", nil, nil, err
+	}
+
+	return b.String(), images, truncated, nil
+}
+
+// summaryPrompt asks the model to summarize the messages left out of a chat prompt
+const summaryPrompt = "Summarize the conversation below in a few sentences. Keep the facts, names, decisions and open questions needed to continue it. Reply with only the summary."
+
+// summarizeMessages has the model summarize the truncated messages in at most
+// maxTokens tokens
+func summarizeMessages(ctx context.Context, r llm.LlamaServer, m *Model, opts api.Options, adapters []llm.LoraAdapter, msgs []api.Message, truncated []int, maxTokens int) (string, error) {
+	var sb strings.Builder
+	for _, i := range truncated {
+		fmt.Fprintf(&sb, "%s: %s\n\n", msgs[i].Role, msgs[i].Content)
+	}
+
+	var b bytes.Buffer
+	if err := m.Template.Execute(&b, template.Values{Messages: []api.Message{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: sb.String()},
+	}}); err != nil {
+		return "", err
+	}
+
+	opts.NumPredict = maxTokens
+	opts.ContextOverflow = api.ContextOverflowShift
+
+	var summary strings.Builder
+	if err := r.Completion(ctx, llm.CompletionRequest{
+		Prompt:   b.String(),
+		Options:  &opts,
+		Adapters: adapters,
+	}, func(cr llm.CompletionResponse) {
+		summary.WriteString(cr.Content)
+	}); err != nil {
+		return "", err
+	}
+
+	slog.Debug("summarized truncated messages", "truncated", len(truncated), "summary", summary.String())
+	return strings.TrimSpace(summary.String()), nil
+}
+
+// withSummary returns msgs without the truncated messages and with the summary
+// of them added to the leading system message. Many templates only render a
+// system message at the start of the conversation.
+func withSummary(msgs []api.Message, truncated []int, summary string) []api.Message {
+	summarized := make([]api.Message, 0, len(msgs)-len(truncated)+1)
+	for i, msg := range msgs {
+		if !slices.Contains(truncated, i) {
+			summarized = append(summarized, msg)
+		}
+	}
+
+	content := "Summary of the earlier conversation: " + summary
+	if len(summarized) > 0 && summarized[0].Role == "system" {
+		summarized[0].Content += "\n\n" + content
+	} else {
+		summarized = append([]api.Message{{Role: "system", Content: content}}, summarized...)
+	}
+
+	return summarized
+}
+
+// promptInfo describes a rendered prompt and the tokens it and its images
+// take up for a dry run
+func promptInfo(ctx context.Context, tokenize tokenizeFunc, m *Model, opts *api.Options, prompt string, numImages int) (*api.PromptInfo, error) {
+	tokens, err := tokenize(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	info := api.PromptInfo{Prompt: prompt, ContextLength: opts.NumCtx}
+	if m.ProjectorPaths != nil {
+		info.ImageTokens = imageNumTokens(m) * numImages
 	}
+	info.Tokens = len(tokens) + info.ImageTokens
 
-	return b.String(), images, nil
+	return &info, nil
 }
 
 func checkMllamaModelFamily(m *Model) bool {
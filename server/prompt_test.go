@@ -5,6 +5,7 @@ import (
 	"context"
 	"image"
 	"image/png"
+	"slices"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -18,6 +19,7 @@ func TestChatPrompt(t *testing.T) {
 		prompt        string
 		images        [][]byte
 		aspectRatioID int
+		truncated     []int
 		error         error
 	}
 
@@ -82,7 +84,8 @@ func TestChatPrompt(t *testing.T) {
 				{Role: "user", Content: "A test. And a thumping good one at that, I'd wager."},
 			},
 			expect: expect{
-				prompt: "A test. And a thumping good one at that, I'd wager. ",
+				prompt:    "A test. And a thumping good one at that, I'd wager. ",
+				truncated: []int{0, 1},
 			},
 		},
 		{
@@ -95,7 +98,8 @@ func TestChatPrompt(t *testing.T) {
 				{Role: "user", Content: "A test. And a thumping good one at that, I'd wager.", Images: []api.ImageData{[]byte("something")}},
 			},
 			expect: expect{
-				prompt: "[img-0]A test. And a thumping good one at that, I'd wager. ",
+				prompt:    "[img-0]A test. And a thumping good one at that, I'd wager. ",
+				truncated: []int{0, 1},
 				images: [][]byte{
 					[]byte("something"),
 				},
@@ -111,7 +115,8 @@ func TestChatPrompt(t *testing.T) {
 				{Role: "user", Content: "A test. And a thumping good one at that, I'd wager.", Images: []api.ImageData{[]byte("somethingelse")}},
 			},
 			expect: expect{
-				prompt: "[img-0]A test. And a thumping good one at that, I'd wager. ",
+				prompt:    "[img-0]A test. And a thumping good one at that, I'd wager. ",
+				truncated: []int{0, 1},
 				images: [][]byte{
 					[]byte("somethingelse"),
 				},
@@ -182,7 +187,8 @@ func TestChatPrompt(t *testing.T) {
 				{Role: "user", Content: "A test. And a thumping good one at that, I'd wager."},
 			},
 			expect: expect{
-				prompt: "[img-0] I-I'm a what? A test. And a thumping good one at that, I'd wager. ",
+				prompt:    "[img-0] I-I'm a what? A test. And a thumping good one at that, I'd wager. ",
+				truncated: []int{0, 1},
 				images: [][]byte{
 					[]byte("somethingelse"),
 				},
@@ -318,7 +324,7 @@ func TestChatPrompt(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			model := tt.model
 			opts := api.Options{Runner: api.Runner{NumCtx: tt.limit}}
-			prompt, images, err := chatPrompt(context.TODO(), &model, mockRunner{}.Tokenize, &opts, tt.msgs, nil)
+			prompt, images, truncated, err := chatPrompt(context.TODO(), &model, mockRunner{}.Tokenize, &opts, tt.msgs, nil)
 			if tt.error == nil && err != nil {
 				t.Fatal(err)
 			} else if tt.error != nil && err != tt.error {
@@ -329,6 +335,10 @@ func TestChatPrompt(t *testing.T) {
 				t.Errorf("mismatch (-got +want):\n%s", diff)
 			}
 
+			if diff := cmp.Diff(truncated, tt.truncated); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+
 			if len(images) != len(tt.images) {
 				t.Fatalf("expected %d images, got %d", len(tt.images), len(images))
 			}
@@ -351,3 +361,64 @@ func TestChatPrompt(t *testing.T) {
 		})
 	}
 }
+
+func TestChatPromptContextOverflow(t *testing.T) {
+	tmpl, err := template.Parse(`
+{{- if .System }}{{ .System }} {{ end }}
+{{- if .Prompt }}{{ .Prompt }} {{ end }}
+{{- if .Response }}{{ .Response }} {{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []api.Message{
+		{Role: "user", Content: "You're a test, Harry!"},
+		{Role: "assistant", Content: "I-I'm a what?"},
+		{Role: "user", Content: "A test. And a thumping good one at that, I'd wager."},
+	}
+
+	cases := []struct {
+		overflow  string
+		prompt    string
+		truncated []int
+	}{
+		{
+			overflow:  api.ContextOverflowShift,
+			prompt:    "I-I'm a what? A test. And a thumping good one at that, I'd wager. ",
+			truncated: []int{0},
+		},
+		{
+			overflow:  api.ContextOverflowSummarize,
+			prompt:    "I-I'm a what? A test. And a thumping good one at that, I'd wager. ",
+			truncated: []int{0},
+		},
+		{
+			overflow:  api.ContextOverflowTruncateMiddle,
+			prompt:    "You're a test, Harry!\n\nA test. And a thumping good one at that, I'd wager. ",
+			truncated: []int{1},
+		},
+		{
+			overflow: api.ContextOverflowError,
+			prompt:   "You're a test, Harry! I-I'm a what? A test. And a thumping good one at that, I'd wager. ",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.overflow, func(t *testing.T) {
+			model := Model{Template: tmpl}
+			opts := api.Options{Runner: api.Runner{NumCtx: 15}, ContextOverflow: tt.overflow}
+			prompt, _, truncated, err := chatPrompt(context.TODO(), &model, mockRunner{}.Tokenize, &opts, slices.Clone(msgs), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(prompt, tt.prompt); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(truncated, tt.truncated); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
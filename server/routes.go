@@ -15,6 +15,7 @@ import (
 	"net"
 	"net/http"
 	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -44,6 +45,12 @@ var mode string = gin.DebugMode
 type Server struct {
 	addr  net.Addr
 	sched *Scheduler
+
+	// logs keeps the most recent log records for /api/logs
+	logs *logBuffer
+
+	// vocabs keeps model vocabularies loaded for /api/tokenize and /api/detokenize
+	vocabs *vocabCache
 }
 
 func init() {
@@ -61,6 +68,8 @@ func init() {
 var (
 	errRequired    = errors.New("is required")
 	errBadTemplate = errors.New("template error")
+	errBadAdapter  = errors.New("invalid adapter")
+	errBadOption   = errors.New("invalid option")
 )
 
 func modelOptions(model *Model, requestOpts map[string]interface{}) (api.Options, error) {
@@ -73,39 +82,191 @@ func modelOptions(model *Model, requestOpts map[string]interface{}) (api.Options
 		return api.Options{}, err
 	}
 
+	if err := validateOptions(opts); err != nil {
+		return api.Options{}, err
+	}
+
 	return opts, nil
 }
 
-// scheduleRunner schedules a runner after validating inputs such as capabilities and model options.
-// It returns the allocated runner, model instance, and consolidated options if successful and error otherwise.
-func (s *Server) scheduleRunner(ctx context.Context, name string, caps []Capability, requestOpts map[string]any, keepAlive *api.Duration) (llm.LlamaServer, *Model, *api.Options, error) {
+// validateOptions checks the options which only accept some values
+func validateOptions(opts api.Options) error {
+	switch opts.ContextOverflow {
+	case "", api.ContextOverflowShift, api.ContextOverflowError, api.ContextOverflowTruncateMiddle, api.ContextOverflowSummarize:
+	default:
+		return fmt.Errorf("%w: context_overflow must be one of %s, %s, %s or %s", errBadOption,
+			api.ContextOverflowShift, api.ContextOverflowError, api.ContextOverflowTruncateMiddle, api.ContextOverflowSummarize)
+	}
+
+	if opts.DryMultiplier < 0 {
+		return fmt.Errorf("%w: dry_multiplier must not be negative", errBadOption)
+	}
+
+	if opts.XTCProbability < 0 || opts.XTCProbability > 1 {
+		return fmt.Errorf("%w: xtc_probability must be between 0 and 1", errBadOption)
+	}
+
+	samplers := []string{
+		api.SamplerPenalties, api.SamplerDry, api.SamplerTopK, api.SamplerTypicalP,
+		api.SamplerTopP, api.SamplerMinP, api.SamplerXTC, api.SamplerTemperature,
+	}
+
+	for i, sampler := range opts.Samplers {
+		if !slices.Contains(samplers, sampler) {
+			return fmt.Errorf("%w: unknown sampler %q, samplers must be any of %s", errBadOption, sampler, strings.Join(samplers, ", "))
+		}
+
+		if slices.Contains(opts.Samplers[:i], sampler) {
+			return fmt.Errorf("%w: sampler %q is listed more than once", errBadOption, sampler)
+		}
+	}
+
+	return nil
+}
+
+// modelAdapters returns the LoRA adapters to apply for a request. Each requested
+// adapter must name a local model built on the same base model as m.
+func modelAdapters(m *Model, adapters []api.Adapter) ([]llm.LoraAdapter, error) {
+	if adapters == nil {
+		loras := make([]llm.LoraAdapter, 0, len(m.AdapterPaths))
+		for _, path := range m.AdapterPaths {
+			loras = append(loras, llm.LoraAdapter{Path: path, Scale: 1.0})
+		}
+
+		return loras, nil
+	}
+
+	loras := make([]llm.LoraAdapter, 0, len(adapters))
+	for _, a := range adapters {
+		name, err := getExistingName(model.ParseName(a.Model))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q not found", errBadAdapter, a.Model)
+		}
+
+		am, err := GetModel(name.String())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", errBadAdapter, a.Model, err)
+		}
+
+		if am.ModelPath != m.ModelPath {
+			return nil, fmt.Errorf("%w: %q is not based on %q", errBadAdapter, a.Model, m.ShortName)
+		}
+
+		if len(am.AdapterPaths) == 0 {
+			return nil, fmt.Errorf("%w: %q has no adapters", errBadAdapter, a.Model)
+		}
+
+		scale := a.Scale
+		if scale == 0 {
+			scale = 1.0
+		}
+
+		for _, path := range am.AdapterPaths {
+			loras = append(loras, llm.LoraAdapter{Path: path, Scale: scale})
+		}
+	}
+
+	return loras, nil
+}
+
+// resolveModel returns the model and options for a request, checking the
+// model has the capabilities the request needs
+func resolveModel(name string, caps []Capability, requestOpts map[string]any) (*Model, *api.Options, error) {
 	if name == "" {
-		return nil, nil, nil, fmt.Errorf("model %w", errRequired)
+		return nil, nil, fmt.Errorf("model %w", errRequired)
 	}
 
 	model, err := GetModel(name)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	if err := model.CheckCapabilities(caps...); err != nil {
-		return nil, nil, nil, fmt.Errorf("%s %w", name, err)
+		return nil, nil, fmt.Errorf("%s %w", name, err)
 	}
 
 	opts, err := modelOptions(model, requestOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return model, &opts, nil
+}
+
+// scheduleRunner schedules a runner after validating inputs such as capabilities and model options.
+// It returns the allocated runner, model instance, and consolidated options if successful and error otherwise.
+// If progress isn't nil it's called with the request's status when it changes
+// while waiting for the runner.
+func (s *Server) scheduleRunner(ctx context.Context, name string, caps []Capability, requestOpts map[string]any, keepAlive *api.Duration, progress func(api.Status)) (llm.LlamaServer, *Model, *api.Options, error) {
+	model, opts, err := resolveModel(name, caps, requestOpts)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	runnerCh, errCh := s.sched.GetRunner(ctx, model, opts, keepAlive)
-	var runner *runnerRef
-	select {
-	case runner = <-runnerCh:
-	case err = <-errCh:
+	runner, err := s.waitForRunner(ctx, model, opts, keepAlive, progress)
+	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	return runner.llama, model, &opts, nil
+	return runner, model, opts, nil
+}
+
+// waitForRunner queues a request for a runner of model and waits for it to be
+// allocated. If progress isn't nil it's called with the request's status when
+// it changes while waiting.
+func (s *Server) waitForRunner(ctx context.Context, model *Model, opts *api.Options, keepAlive *api.Duration, progress func(api.Status)) (llm.LlamaServer, error) {
+	req := s.sched.queueRequest(ctx, model, *opts, keepAlive)
+
+	var tick <-chan time.Time
+	if progress != nil {
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var last api.Status
+	for {
+		select {
+		case runner := <-req.successCh:
+			return runner.llama, nil
+		case err := <-req.errCh:
+			return nil, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-tick:
+			if status := s.sched.requestStatus(req); status != last {
+				progress(status)
+				last = status
+			}
+		}
+	}
+}
+
+// requestTimeout returns how long a request with the given timeout may run
+// for, or zero if there's no limit
+func requestTimeout(d *api.Duration) time.Duration {
+	timeout := envconfig.RequestTimeout()
+	if d != nil && d.Duration != 0 {
+		timeout = d.Duration
+	}
+
+	timeout = min(timeout, envconfig.MaxRequestTimeout())
+	if timeout == math.MaxInt64 {
+		return 0
+	}
+
+	return timeout
+}
+
+// fimPrompt builds a fill-in-the-middle prompt with the FIM tokens of the
+// model's tokenizer, for models whose template doesn't take a suffix
+func fimPrompt(m *Model, prompt, suffix string) (string, error) {
+	prefixToken, suffixToken, middleToken, ok := m.FIMTokens()
+	if !ok {
+		return "", fmt.Errorf("%s %w %w", m.ShortName, errCapabilities, errCapabilityInsert)
+	}
+
+	return prefixToken + prompt + suffixToken + suffix + middleToken, nil
 }
 
 func (s *Server) GenerateHandler(c *gin.Context) {
@@ -149,7 +310,7 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 	}
 
 	// expire the runner
-	if req.Prompt == "" && req.KeepAlive != nil && int(req.KeepAlive.Seconds()) == 0 {
+	if req.Prompt == "" && !req.DryRun && req.KeepAlive != nil && int(req.KeepAlive.Seconds()) == 0 {
 		s.sched.expireRunner(model)
 
 		c.JSON(http.StatusOK, api.GenerateResponse{
@@ -172,7 +333,14 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 		caps = append(caps, CapabilityInsert)
 	}
 
-	r, m, opts, err := s.scheduleRunner(c.Request.Context(), name.String(), caps, req.Options, req.KeepAlive)
+	var progress func(api.Status)
+	if req.Progress && (req.Stream == nil || *req.Stream) {
+		progress = func(status api.Status) {
+			streamStatus(c, api.GenerateResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Status: &status})
+		}
+	}
+
+	m, opts, err := resolveModel(name.String(), caps, req.Options)
 	if errors.Is(err, errCapabilityCompletion) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%q does not support generate", req.Model)})
 		return
@@ -181,10 +349,30 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 		return
 	}
 
+	// check the adapters before scheduling so a bad adapter doesn't load the model
+	adapters, err := modelAdapters(m, req.Adapters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var r llm.LlamaServer
+	var tok tokenizer
+	if req.DryRun {
+		tok = s.vocabs.tokenizer(m.ModelPath)
+	} else {
+		r, err = s.waitForRunner(c.Request.Context(), m, opts, req.KeepAlive, progress)
+		if err != nil {
+			handleScheduleError(c, req.Model, err)
+			return
+		}
+		tok = r
+	}
+
 	checkpointLoaded := time.Now()
 
 	// load the model
-	if req.Prompt == "" {
+	if req.Prompt == "" && !req.DryRun {
 		c.JSON(http.StatusOK, api.GenerateResponse{
 			Model:      req.Model,
 			CreatedAt:  time.Now().UTC(),
@@ -229,7 +417,15 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 	}
 
 	prompt := req.Prompt
-	if !req.Raw {
+	switch {
+	case req.Raw:
+	case req.Suffix != "" && req.Template == "" && !slices.Contains(m.Template.Vars(), "suffix"):
+		prompt, err = fimPrompt(m, req.Prompt, req.Suffix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	default:
 		tmpl := m.Template
 		if req.Template != "" {
 			tmpl, err = template.Parse(req.Template)
@@ -269,7 +465,7 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 		var b bytes.Buffer
 		if req.Context != nil {
 			slog.Warn("the context field is deprecated and will be removed in a future version of Ollama")
-			s, err := r.Detokenize(c.Request.Context(), req.Context)
+			s, err := tok.Detokenize(c.Request.Context(), req.Context)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -287,17 +483,42 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 
 	slog.Debug("generate request", "images", len(images), "prompt", prompt)
 
+	if req.DryRun {
+		info, err := promptInfo(c.Request.Context(), tok.Tokenize, m, opts, prompt, len(images))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, api.GenerateResponse{
+			Model:      req.Model,
+			CreatedAt:  time.Now().UTC(),
+			Done:       true,
+			DoneReason: "dry_run",
+			PromptInfo: info,
+		})
+		return
+	}
+
 	ch := make(chan any)
 	go func() {
 		// TODO (jmorganca): avoid building the response twice both here and below
 		var sb strings.Builder
 		defer close(ch)
 		if err := r.Completion(c.Request.Context(), llm.CompletionRequest{
-			Prompt:  prompt,
-			Images:  images,
-			Format:  req.Format,
-			Options: opts,
+			Prompt:   prompt,
+			Images:   images,
+			Format:   req.Format,
+			Options:  opts,
+			Adapters: adapters,
+			Progress: progress != nil,
+			Timeout:  requestTimeout(req.Timeout),
 		}, func(cr llm.CompletionResponse) {
+			if cr.Status != nil {
+				ch <- api.GenerateResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Status: cr.Status}
+				return
+			}
+
 			res := api.GenerateResponse{
 				Model:      req.Model,
 				CreatedAt:  time.Now().UTC(),
@@ -309,6 +530,9 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 					PromptEvalDuration: cr.PromptEvalDuration,
 					EvalCount:          cr.EvalCount,
 					EvalDuration:       cr.EvalDuration,
+
+					PromptCacheHitCount:      cr.PromptCacheHitCount,
+					PromptCacheSavedDuration: cr.PromptCacheSavedDuration,
 				},
 			}
 
@@ -319,6 +543,8 @@ func (s *Server) GenerateHandler(c *gin.Context) {
 			if cr.Done {
 				res.TotalDuration = time.Since(checkpointStart)
 				res.LoadDuration = checkpointLoaded.Sub(checkpointStart)
+				res.Seed = &cr.Seed
+				res.TruncatedTokens = cr.PromptTruncated
 
 				if !req.Raw {
 					tokens, err := r.Tokenize(c.Request.Context(), prompt+sb.String())
@@ -413,12 +639,18 @@ func (s *Server) EmbedHandler(c *gin.Context) {
 		return
 	}
 
-	r, m, opts, err := s.scheduleRunner(c.Request.Context(), name.String(), []Capability{}, req.Options, req.KeepAlive)
+	r, m, opts, err := s.scheduleRunner(c.Request.Context(), name.String(), []Capability{}, req.Options, req.KeepAlive, nil)
 	if err != nil {
 		handleScheduleError(c, req.Model, err)
 		return
 	}
 
+	adapters, err := modelAdapters(m, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	checkpointLoaded := time.Now()
 
 	if len(input) == 0 {
@@ -464,7 +696,7 @@ func (s *Server) EmbedHandler(c *gin.Context) {
 	embeddings := make([][]float32, len(input))
 	for i, text := range input {
 		g.Go(func() error {
-			embedding, err := r.Embedding(c.Request.Context(), text)
+			embedding, err := r.Embedding(c.Request.Context(), text, adapters)
 			if err != nil {
 				return err
 			}
@@ -522,19 +754,25 @@ func (s *Server) EmbeddingsHandler(c *gin.Context) {
 		return
 	}
 
-	r, _, _, err := s.scheduleRunner(c.Request.Context(), name.String(), []Capability{}, req.Options, req.KeepAlive)
+	r, m, _, err := s.scheduleRunner(c.Request.Context(), name.String(), []Capability{}, req.Options, req.KeepAlive, nil)
 	if err != nil {
 		handleScheduleError(c, req.Model, err)
 		return
 	}
 
+	adapters, err := modelAdapters(m, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// an empty request loads the model
 	if req.Prompt == "" {
 		c.JSON(http.StatusOK, api.EmbeddingResponse{Embedding: []float64{}})
 		return
 	}
 
-	embedding, err := r.Embedding(c.Request.Context(), req.Prompt)
+	embedding, err := r.Embedding(c.Request.Context(), req.Prompt, adapters)
 	if err != nil {
 		slog.Info(fmt.Sprintf("embedding generation failed: %v", err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Errorf("failed to generate embedding: %v", err)})
@@ -634,6 +872,7 @@ func (s *Server) PushHandler(c *gin.Context) {
 
 		regOpts := &registryOptions{
 			Insecure: req.Insecure,
+			Sign:     req.Sign,
 		}
 
 		ctx, cancel := context.WithCancel(c.Request.Context())
@@ -814,6 +1053,10 @@ func GetModelInfo(req api.ShowRequest) (*api.ShowResponse, error) {
 		ModifiedAt: manifest.fi.ModTime(),
 	}
 
+	for _, capability := range m.Capabilities() {
+		resp.Capabilities = append(resp.Capabilities, string(capability))
+	}
+
 	var params []string
 	cs := 30
 	for k, v := range m.Options {
@@ -1021,6 +1264,12 @@ func (s *Server) CreateBlobHandler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	default:
+		// the blob is about to be used, restart its prune grace period
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			slog.Warn("couldn't update blob modification time", "path", path, "error", err)
+		}
+
 		c.Status(http.StatusOK)
 		return
 	}
@@ -1147,14 +1396,21 @@ func (s *Server) GenerateRoutes() http.Handler {
 	r.POST("/api/chat", s.ChatHandler)
 	r.POST("/api/embed", s.EmbedHandler)
 	r.POST("/api/embeddings", s.EmbeddingsHandler)
+	r.POST("/api/tokenize", s.TokenizeHandler)
+	r.POST("/api/detokenize", s.DetokenizeHandler)
 	r.POST("/api/create", s.CreateHandler)
 	r.POST("/api/push", s.PushHandler)
 	r.POST("/api/copy", s.CopyHandler)
 	r.DELETE("/api/delete", s.DeleteHandler)
 	r.POST("/api/show", s.ShowHandler)
+	r.POST("/api/diff", s.DiffHandler)
+	r.GET("/api/du", s.DiskUsageHandler)
+	r.POST("/api/prune", s.PruneHandler)
 	r.POST("/api/blobs/:digest", s.CreateBlobHandler)
 	r.HEAD("/api/blobs/:digest", s.HeadBlobHandler)
 	r.GET("/api/ps", s.PsHandler)
+	r.GET("/api/status", s.StatusHandler)
+	r.POST("/api/logs", s.LogsHandler)
 
 	// Compatibility endpoints
 	r.POST("/v1/chat/completions", openai.ChatMiddleware(), s.ChatHandler)
@@ -1183,7 +1439,6 @@ func Serve(ln net.Listener) error {
 		level = slog.LevelDebug
 	}
 
-	slog.Info("server config", "env", envconfig.Values())
 	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level:     level,
 		AddSource: true,
@@ -1197,7 +1452,9 @@ func Serve(ln net.Listener) error {
 		},
 	})
 
-	slog.SetDefault(slog.New(handler))
+	logs := newLogBuffer(maxLogRecords)
+	slog.SetDefault(slog.New(&logBufferHandler{Handler: handler, buffer: logs}))
+	slog.Info("server config", "env", envconfig.Values())
 
 	blobsDir, err := GetBlobsPath("")
 	if err != nil {
@@ -1208,29 +1465,26 @@ func Serve(ln net.Listener) error {
 	}
 
 	if !envconfig.NoPrune() {
-		if _, err := Manifests(false); err != nil {
-			slog.Warn("corrupt manifests detected, skipping prune operation.  Re-pull or delete to clear", "error", err)
-		} else {
-			// clean up unused layers and manifests
-			if err := PruneLayers(); err != nil {
-				return err
-			}
+		// clean up unused layers and manifests, keeping the layers of
+		// corrupt manifests
+		if err := PruneLayers(); err != nil {
+			return err
+		}
 
-			manifestsPath, err := GetManifestPath()
-			if err != nil {
-				return err
-			}
+		manifestsPath, err := GetManifestPath()
+		if err != nil {
+			return err
+		}
 
-			if err := PruneDirectory(manifestsPath); err != nil {
-				return err
-			}
+		if err := PruneDirectory(manifestsPath); err != nil {
+			return err
 		}
 	}
 
 	ctx, done := context.WithCancel(context.Background())
 	schedCtx, schedDone := context.WithCancel(ctx)
 	sched := InitScheduler(schedCtx)
-	s := &Server{addr: ln.Addr(), sched: sched}
+	s := &Server{addr: ln.Addr(), sched: sched, logs: logs, vocabs: newVocabCache(maxVocabs)}
 
 	http.Handle("/", s.GenerateRoutes())
 
@@ -1329,6 +1583,30 @@ func streamResponse(c *gin.Context, ch chan any) {
 	})
 }
 
+// statusInterval is how often the status of a request waiting for a runner
+// is checked for changes
+var statusInterval = 250 * time.Millisecond
+
+// streamStatus sends a status response before the rest of a streamed response.
+// The first status starts the response, so any error after it is sent in the
+// stream rather than as the response's status code.
+func streamStatus(c *gin.Context, res any) {
+	c.Header("Content-Type", "application/x-ndjson")
+
+	bts, err := json.Marshal(res)
+	if err != nil {
+		slog.Info(fmt.Sprintf("streamStatus: json.Marshal failed with %s", err))
+		return
+	}
+
+	if _, err := c.Writer.Write(append(bts, '\n')); err != nil {
+		slog.Info(fmt.Sprintf("streamStatus: w.Write failed with %s", err))
+		return
+	}
+
+	c.Writer.Flush()
+}
+
 func (s *Server) PsHandler(c *gin.Context) {
 	models := []api.ProcessModelResponse{}
 
@@ -1370,6 +1648,43 @@ func (s *Server) PsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, api.ProcessResponse{Models: models})
 }
 
+func (s *Server) StatusHandler(c *gin.Context) {
+	resp := api.StatusResponse{
+		Version: version.Version,
+		Queued:  len(s.sched.pendingReqCh),
+		GPUs:    []api.GPUStatus{},
+		Env:     envconfig.Values(),
+	}
+
+	for _, gpu := range s.sched.getGpuFn() {
+		status := api.GPUStatus{
+			ID:          gpu.ID,
+			Name:        gpu.Name,
+			Library:     gpu.Library,
+			Variant:     gpu.Variant,
+			Compute:     gpu.Compute,
+			TotalMemory: gpu.TotalMemory,
+			FreeMemory:  gpu.FreeMemory,
+		}
+
+		if gpu.DriverMajor > 0 {
+			status.DriverVersion = fmt.Sprintf("%d.%d", gpu.DriverMajor, gpu.DriverMinor)
+		}
+
+		resp.GPUs = append(resp.GPUs, status)
+	}
+
+	// proxies may include credentials
+	for k, v := range resp.Env {
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			u.User = url.User("xxxxx")
+			resp.Env[k] = u.String()
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (s *Server) ChatHandler(c *gin.Context) {
 	checkpointStart := time.Now()
 
@@ -1383,7 +1698,7 @@ func (s *Server) ChatHandler(c *gin.Context) {
 	}
 
 	// expire the runner
-	if len(req.Messages) == 0 && req.KeepAlive != nil && int(req.KeepAlive.Seconds()) == 0 {
+	if len(req.Messages) == 0 && !req.DryRun && req.KeepAlive != nil && int(req.KeepAlive.Seconds()) == 0 {
 		model, err := GetModel(req.Model)
 		if err != nil {
 			switch {
@@ -1424,7 +1739,14 @@ func (s *Server) ChatHandler(c *gin.Context) {
 		return
 	}
 
-	r, m, opts, err := s.scheduleRunner(c.Request.Context(), name.String(), caps, req.Options, req.KeepAlive)
+	var progress func(api.Status)
+	if req.Progress && (req.Stream == nil || *req.Stream) {
+		progress = func(status api.Status) {
+			streamStatus(c, api.ChatResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Message: api.Message{Role: "assistant"}, Status: &status})
+		}
+	}
+
+	m, opts, err := resolveModel(name.String(), caps, req.Options)
 	if errors.Is(err, errCapabilityCompletion) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%q does not support chat", req.Model)})
 		return
@@ -1433,9 +1755,34 @@ func (s *Server) ChatHandler(c *gin.Context) {
 		return
 	}
 
+	// check the adapters before scheduling so a bad adapter doesn't load the model
+	adapters, err := modelAdapters(m, req.Adapters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var r llm.LlamaServer
+	var tok tokenizer
+	if req.DryRun {
+		tok = s.vocabs.tokenizer(m.ModelPath)
+	} else {
+		r, err = s.waitForRunner(c.Request.Context(), m, opts, req.KeepAlive, progress)
+		if err != nil {
+			handleScheduleError(c, req.Model, err)
+			return
+		}
+		tok = r
+	}
+
 	checkpointLoaded := time.Now()
 
 	if len(req.Messages) == 0 {
+		if req.DryRun {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "messages are required for a dry run"})
+			return
+		}
+
 		c.JSON(http.StatusOK, api.ChatResponse{
 			Model:      req.Model,
 			CreatedAt:  time.Now().UTC(),
@@ -1451,26 +1798,104 @@ func (s *Server) ChatHandler(c *gin.Context) {
 		msgs = append([]api.Message{{Role: "system", Content: m.System}}, msgs...)
 	}
 
-	prompt, images, err := chatPrompt(c.Request.Context(), m, r.Tokenize, opts, msgs, req.Tools)
+	// chatPrompt adds image tags to the messages it keeps
+	untagged := slices.Clone(msgs)
+
+	prompt, images, truncated, err := chatPrompt(c.Request.Context(), m, tok.Tokenize, opts, msgs, req.Tools)
 	if err != nil {
 		slog.Error("chat prompt error", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if len(truncated) > 0 && opts.ContextOverflow == api.ContextOverflowSummarize && !req.DryRun {
+		// the summary can take the space left by the kept messages, up to a
+		// quarter of the context
+		empty, _, _, err := chatPrompt(c.Request.Context(), m, r.Tokenize, opts, withSummary(untagged, truncated, ""), req.Tools)
+		if err != nil {
+			slog.Error("chat prompt error", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		tokens, err := r.Tokenize(c.Request.Context(), empty)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if maxTokens := min(opts.NumCtx/4, opts.NumCtx-len(tokens)); maxTokens > 0 {
+			summary, err := summarizeMessages(c.Request.Context(), r, m, *opts, adapters, untagged, truncated, maxTokens)
+			if err != nil {
+				slog.Error("chat summary error", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			summarizedPrompt, summarizedImages, retruncated, err := chatPrompt(c.Request.Context(), m, r.Tokenize, opts, withSummary(untagged, truncated, summary), req.Tools)
+			if err != nil {
+				slog.Error("chat prompt error", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// keep the prompt without the summary rather than lose more messages
+			if len(retruncated) == 0 {
+				prompt, images = summarizedPrompt, summarizedImages
+			} else {
+				slog.Warn("conversation summary doesn't fit in the context, leaving it out", "retruncated", len(retruncated))
+			}
+		}
+	}
+
+	// the model's system prompt and messages come before the request's
+	var truncatedMessages []int
+	for _, i := range truncated {
+		if i -= len(msgs) - len(req.Messages); i >= 0 {
+			truncatedMessages = append(truncatedMessages, i)
+		}
+	}
+
 	slog.Debug("chat request", "images", len(images), "prompt", prompt)
 
+	if req.DryRun {
+		info, err := promptInfo(c.Request.Context(), tok.Tokenize, m, opts, prompt, len(images))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, api.ChatResponse{
+			Model:             req.Model,
+			CreatedAt:         time.Now().UTC(),
+			Message:           api.Message{Role: "assistant"},
+			Done:              true,
+			DoneReason:        "dry_run",
+			TruncatedMessages: truncatedMessages,
+			PromptInfo:        info,
+		})
+		return
+	}
+
 	ch := make(chan any)
 	go func() {
 		defer close(ch)
 		var sb strings.Builder
 		var toolCallIndex int = 0
 		if err := r.Completion(c.Request.Context(), llm.CompletionRequest{
-			Prompt:  prompt,
-			Images:  images,
-			Format:  req.Format,
-			Options: opts,
+			Prompt:   prompt,
+			Images:   images,
+			Format:   req.Format,
+			Options:  opts,
+			Adapters: adapters,
+			Progress: progress != nil,
+			Timeout:  requestTimeout(req.Timeout),
 		}, func(r llm.CompletionResponse) {
+			if r.Status != nil {
+				ch <- api.ChatResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Message: api.Message{Role: "assistant"}, Status: r.Status}
+				return
+			}
+
 			res := api.ChatResponse{
 				Model:      req.Model,
 				CreatedAt:  time.Now().UTC(),
@@ -1482,12 +1907,18 @@ func (s *Server) ChatHandler(c *gin.Context) {
 					PromptEvalDuration: r.PromptEvalDuration,
 					EvalCount:          r.EvalCount,
 					EvalDuration:       r.EvalDuration,
+
+					PromptCacheHitCount:      r.PromptCacheHitCount,
+					PromptCacheSavedDuration: r.PromptCacheSavedDuration,
 				},
 			}
 
 			if r.Done {
 				res.TotalDuration = time.Since(checkpointStart)
 				res.LoadDuration = checkpointLoaded.Sub(checkpointStart)
+				res.TruncatedMessages = truncatedMessages
+				res.TruncatedTokens = r.PromptTruncated
+				res.Seed = &r.Seed
 			}
 
 			// TODO: tool call checking and filtering should be moved outside of this callback once streaming
@@ -1566,7 +1997,7 @@ func (s *Server) ChatHandler(c *gin.Context) {
 
 func handleScheduleError(c *gin.Context, name string, err error) {
 	switch {
-	case errors.Is(err, errCapabilities), errors.Is(err, errRequired):
+	case errors.Is(err, errCapabilities), errors.Is(err, errRequired), errors.Is(err, errBadOption):
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 	case errors.Is(err, context.Canceled):
 		c.JSON(499, gin.H{"error": "request canceled"})
@@ -416,6 +416,36 @@ func TestCreateMergeParameters(t *testing.T) {
 	}
 }
 
+func TestCreateInvalidParameters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("OLLAMA_MODELS", t.TempDir())
+	var s Server
+
+	cases := map[string]any{
+		"samplers":         []string{"top_k", "greedy"},
+		"xtc_probability":  2,
+		"dry_multiplier":   -0.5,
+		"context_overflow": "drop",
+	}
+
+	for k, v := range cases {
+		t.Run(k, func(t *testing.T) {
+			_, digest := createBinFile(t, nil, nil)
+			w := createRequest(t, s.CreateHandler, api.CreateRequest{
+				Name:       "test",
+				Files:      map[string]string{"test.gguf": digest},
+				Parameters: map[string]any{k: v},
+				Stream:     &stream,
+			})
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status code 400, actual %d", w.Code)
+			}
+		})
+	}
+}
+
 func TestCreateReplacesMessages(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -748,6 +778,17 @@ func TestDetectModelTypeFromFiles(t *testing.T) {
 		}
 	})
 
+	t.Run("pytorch shards", func(t *testing.T) {
+		files := map[string]string{
+			"pytorch_model-00001-of-00002.bin": "sha256:abc123",
+		}
+
+		modelType := detectModelTypeFromFiles(files)
+		if modelType != "safetensors" {
+			t.Fatalf("expected model type 'safetensors', got %q", modelType)
+		}
+	})
+
 	t.Run("unsupported file type", func(t *testing.T) {
 		p := t.TempDir()
 		t.Setenv("OLLAMA_MODELS", p)
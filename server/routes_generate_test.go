@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -84,6 +85,12 @@ func TestGenerateChat(t *testing.T) {
 				}
 			},
 		},
+		vocabs: &vocabCache{
+			size: 1,
+			loadFn: func(string) (tokenizer, error) {
+				return &mockVocab{}, nil
+			},
+		},
 	}
 
 	go s.sched.Run(context.TODO())
@@ -356,6 +363,409 @@ func TestGenerateChat(t *testing.T) {
 		checkChatResponse(t, w.Body, "test-system", "Abra kadabra!")
 	})
 
+	t.Run("messages truncated", func(t *testing.T) {
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model: "test",
+			Messages: []api.Message{
+				{Role: "user", Content: "Hello!"},
+				{Role: "assistant", Content: "I can help you with that."},
+				{Role: "user", Content: "Help me write tests."},
+			},
+			Options: map[string]any{"num_ctx": 8},
+			Stream:  &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+
+		if diff := cmp.Diff(mock.CompletionRequest.Prompt, "user: Help me write tests.\n"); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+
+		var resp api.ChatResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(resp.TruncatedMessages, []int{0, 1}); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("messages summarized", func(t *testing.T) {
+		var prompts []string
+		mock.CompletionFn = func(_ context.Context, r llm.CompletionRequest, fn func(llm.CompletionResponse)) error {
+			prompts = append(prompts, r.Prompt)
+			if len(prompts) == 1 {
+				fn(llm.CompletionResponse{Content: "Harry is a test."})
+			}
+			fn(llm.CompletionResponse{Done: true, DoneReason: "stop"})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model: "test",
+			Messages: []api.Message{
+				{Role: "user", Content: "Hello! I need help writing tests for a new feature in my project today."},
+				{Role: "assistant", Content: "I can help you with that."},
+				{Role: "user", Content: "Help me write tests."},
+			},
+			Options: map[string]any{"num_ctx": 24, "context_overflow": "summarize"},
+			Stream:  &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		want := []string{
+			"system: " + summaryPrompt + "\nuser: user: Hello! I need help writing tests for a new feature in my project today.\n\n\n",
+			"system: Summary of the earlier conversation: Harry is a test.\nassistant: I can help you with that.\nuser: Help me write tests.\n",
+		}
+		if diff := cmp.Diff(prompts, want); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+
+		var resp api.ChatResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(resp.TruncatedMessages, []int{0}); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("messages summarized into system message", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			summary string
+			want    string
+		}{
+			{
+				name:    "fits",
+				summary: "Harry is a test.",
+				want:    "system: Be brief.\n\nSummary of the earlier conversation: Harry is a test.\nassistant: I can help.\nuser: Help me write tests.\n",
+			},
+			{
+				// the summary is left out rather than truncating more messages
+				name:    "too long",
+				summary: strings.Repeat("Harry is a test. ", 10),
+				want:    "system: Be brief.\nassistant: I can help.\nuser: Help me write tests.\n",
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				var prompts []string
+				mock.CompletionFn = func(_ context.Context, r llm.CompletionRequest, fn func(llm.CompletionResponse)) error {
+					prompts = append(prompts, r.Prompt)
+					if len(prompts) == 1 {
+						fn(llm.CompletionResponse{Content: tt.summary})
+					}
+					fn(llm.CompletionResponse{Done: true, DoneReason: "stop"})
+					return nil
+				}
+				t.Cleanup(func() { mock.CompletionFn = nil })
+
+				w := createRequest(t, s.ChatHandler, api.ChatRequest{
+					Model: "test",
+					Messages: []api.Message{
+						{Role: "system", Content: "Be brief."},
+						{Role: "user", Content: "Hello! I need help writing tests for a new feature in my project today."},
+						{Role: "assistant", Content: "I can help."},
+						{Role: "user", Content: "Help me write tests."},
+					},
+					Options: map[string]any{"num_ctx": 24, "context_overflow": "summarize"},
+					Stream:  &stream,
+				})
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+
+				if len(prompts) != 2 {
+					t.Fatalf("expected a summary and a response, got %q", prompts)
+				}
+
+				if diff := cmp.Diff(prompts[1], tt.want); diff != "" {
+					t.Errorf("mismatch (-got +want):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("prompt truncated by runner", func(t *testing.T) {
+		mock.CompletionFn = func(_ context.Context, r llm.CompletionRequest, fn func(llm.CompletionResponse)) error {
+			fn(llm.CompletionResponse{Done: true, DoneReason: "stop", PromptTruncated: 5})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Stream:   &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.ChatResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.TruncatedTokens != 5 {
+			t.Errorf("expected 5 truncated tokens, got %d", resp.TruncatedTokens)
+		}
+	})
+
+	t.Run("invalid context overflow", func(t *testing.T) {
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Options:  map[string]any{"context_overflow": "drop"},
+			Stream:   &stream,
+		})
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("sampler options", func(t *testing.T) {
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Options: map[string]any{
+				"dry_multiplier":  0.8,
+				"xtc_probability": 0.5,
+				"samplers":        []string{"dry", "xtc", "temperature"},
+			},
+			Stream: &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		opts := mock.CompletionRequest.Options
+		if diff := cmp.Diff(opts.Samplers, []string{"dry", "xtc", "temperature"}); diff != "" {
+			t.Errorf("samplers mismatch (-got +want):\n%s", diff)
+		}
+
+		if opts.DryMultiplier != 0.8 || opts.DryBase != 1.75 || opts.XTCProbability != 0.5 || opts.XTCThreshold != 0.1 {
+			t.Errorf("unexpected sampler options %+v", opts)
+		}
+	})
+
+	t.Run("deterministic with seed", func(t *testing.T) {
+		mock.CompletionFn = func(ctx context.Context, r llm.CompletionRequest, fn func(r llm.CompletionResponse)) error {
+			fn(llm.CompletionResponse{Content: "Hi!", Done: true, DoneReason: "stop", Seed: 1234})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Options:  map[string]any{"deterministic": true},
+			Stream:   &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if !mock.CompletionRequest.Options.Deterministic {
+			t.Error("expected deterministic option to be set")
+		}
+
+		var resp api.ChatResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Seed == nil || *resp.Seed != 1234 {
+			t.Errorf("expected seed %d, got %v", 1234, resp.Seed)
+		}
+	})
+
+	t.Run("seed 0", func(t *testing.T) {
+		mock.CompletionFn = func(ctx context.Context, r llm.CompletionRequest, fn func(r llm.CompletionResponse)) error {
+			fn(llm.CompletionResponse{Content: "Hi!", Done: true, DoneReason: "stop", Seed: r.Options.Seed})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Options:  map[string]any{"seed": 0},
+			Stream:   &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if seed, ok := resp["seed"]; !ok || seed != float64(0) {
+			t.Errorf("expected seed 0, got %v", resp["seed"])
+		}
+	})
+
+	t.Run("invalid sampler options", func(t *testing.T) {
+		cases := []map[string]any{
+			{"samplers": []string{"top_k", "greedy"}},
+			{"samplers": []string{"top_k", "top_k"}},
+			{"xtc_probability": 1.5},
+			{"dry_multiplier": -1},
+		}
+
+		for _, opts := range cases {
+			w := createRequest(t, s.ChatHandler, api.ChatRequest{
+				Model:    "test",
+				Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+				Options:  opts,
+				Stream:   &stream,
+			})
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%v: expected status 400, got %d", opts, w.Code)
+			}
+		}
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		mock.CompletionFn = func(ctx context.Context, r llm.CompletionRequest, fn func(r llm.CompletionResponse)) error {
+			fn(llm.CompletionResponse{Status: &api.Status{Status: api.StatusProcessing, Completed: 1, Total: 2}})
+			fn(llm.CompletionResponse{Content: "Hi!", Done: true, DoneReason: "stop"})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		streaming := true
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Progress: true,
+			Stream:   &streaming,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if !mock.CompletionRequest.Progress {
+			t.Error("expected progress to be requested from the runner")
+		}
+
+		var statuses []api.Status
+		var content string
+		decoder := json.NewDecoder(w.Body)
+		for {
+			var resp api.ChatResponse
+			if err := decoder.Decode(&resp); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Status != nil {
+				if content != "" {
+					t.Errorf("unexpected status %+v after content", resp.Status)
+				}
+				statuses = append(statuses, *resp.Status)
+			}
+			content += resp.Message.Content
+		}
+
+		if diff := cmp.Diff(statuses, []api.Status{{Status: api.StatusProcessing, Completed: 1, Total: 2}}); diff != "" {
+			t.Errorf("statuses mismatch (-got +want):\n%s", diff)
+		}
+
+		if content != "Hi!" {
+			t.Errorf("expected content %q, got %q", "Hi!", content)
+		}
+	})
+
+	t.Run("progress without streaming", func(t *testing.T) {
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model:    "test",
+			Messages: []api.Message{{Role: "user", Content: "Hello!"}},
+			Progress: true,
+			Stream:   &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if mock.CompletionRequest.Progress {
+			t.Error("expected no progress for a non-streaming response")
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		mock.CompletionRequest = llm.CompletionRequest{}
+
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{
+			Model: "test-system",
+			Messages: []api.Message{
+				{Role: "user", Content: "Hello!"},
+				{Role: "assistant", Content: "I can help you with that."},
+				{Role: "user", Content: "Help me write tests."},
+			},
+			Options: map[string]any{"num_ctx": 18},
+			DryRun:  true,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if mock.CompletionRequest.Prompt != "" {
+			t.Errorf("expected no completion for a dry run, got %q", mock.CompletionRequest.Prompt)
+		}
+
+		var resp api.ChatResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if !resp.Done || resp.DoneReason != "dry_run" {
+			t.Errorf("expected a done dry run response, got %+v", resp)
+		}
+
+		// the model's system prompt isn't one of the request's messages
+		if diff := cmp.Diff(resp.TruncatedMessages, []int{0}); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+
+		want := &api.PromptInfo{
+			Prompt:        "system: You are a helpful assistant.\nassistant: I can help you with that.\nuser: Help me write tests.\n",
+			Tokens:        18,
+			ContextLength: 18,
+		}
+		if diff := cmp.Diff(resp.PromptInfo, want); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("dry run without messages", func(t *testing.T) {
+		w := createRequest(t, s.ChatHandler, api.ChatRequest{Model: "test", DryRun: true})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
 	t.Run("messages with tools (non-streaming)", func(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Fatalf("failed to create test-system model: %d", w.Code)
@@ -601,6 +1011,7 @@ func TestGenerate(t *testing.T) {
 		},
 	}
 
+	var loads atomic.Int32
 	s := Server{
 		sched: &Scheduler{
 			pendingReqCh:  make(chan *LlmRequest, 1),
@@ -613,6 +1024,7 @@ func TestGenerate(t *testing.T) {
 			getCpuFn:      discover.GetCPUInfo,
 			reschedDelay:  250 * time.Millisecond,
 			loadFn: func(req *LlmRequest, ggml *llm.GGML, gpus discover.GpuInfoList, numParallel int) {
+				loads.Add(1)
 				// add small delay to simulate loading
 				time.Sleep(time.Millisecond)
 				req.successCh <- &runnerRef{
@@ -620,6 +1032,12 @@ func TestGenerate(t *testing.T) {
 				}
 			},
 		},
+		vocabs: &vocabCache{
+			size: 1,
+			loadFn: func(string) (tokenizer, error) {
+				return &mockVocab{}, nil
+			},
+		},
 	}
 
 	go s.sched.Run(context.TODO())
@@ -829,6 +1247,66 @@ func TestGenerate(t *testing.T) {
 		checkGenerateResponse(t, w.Body, "test", "Hi!")
 	})
 
+	t.Run("prompt with timeout", func(t *testing.T) {
+		mock.CompletionFn = func(ctx context.Context, r llm.CompletionRequest, fn func(r llm.CompletionResponse)) error {
+			fn(llm.CompletionResponse{Content: "Hi", Done: true, DoneReason: "timeout", EvalCount: 1, EvalDuration: 1})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		t.Setenv("OLLAMA_MAX_REQUEST_TIMEOUT", "10s")
+		w := createRequest(t, s.GenerateHandler, api.GenerateRequest{
+			Model:   "test",
+			Prompt:  "Hello!",
+			Stream:  &stream,
+			Timeout: &api.Duration{Duration: time.Minute},
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		if mock.CompletionRequest.Timeout != 10*time.Second {
+			t.Errorf("expected timeout %s, got %s", 10*time.Second, mock.CompletionRequest.Timeout)
+		}
+
+		var resp api.GenerateResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Response != "Hi" || resp.DoneReason != "timeout" || resp.EvalCount != 1 {
+			t.Errorf("expected partial response with done reason timeout, got %+v", resp)
+		}
+	})
+
+	t.Run("prompt truncated by runner", func(t *testing.T) {
+		mock.CompletionFn = func(ctx context.Context, r llm.CompletionRequest, fn func(r llm.CompletionResponse)) error {
+			fn(llm.CompletionResponse{Done: true, DoneReason: "stop", PromptTruncated: 5})
+			return nil
+		}
+		t.Cleanup(func() { mock.CompletionFn = nil })
+
+		w := createRequest(t, s.GenerateHandler, api.GenerateRequest{
+			Model:  "test",
+			Prompt: "Hello!",
+			Stream: &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		var resp api.GenerateResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.TruncatedTokens != 5 {
+			t.Errorf("expected 5 truncated tokens, got %d", resp.TruncatedTokens)
+		}
+	})
+
 	w = createRequest(t, s.CreateHandler, api.CreateRequest{
 		Model:  "test-system",
 		From:   "test",
@@ -942,6 +1420,60 @@ func TestGenerate(t *testing.T) {
 		}
 	})
 
+	t.Run("prompt with suffix using fim tokens", func(t *testing.T) {
+		_, digest := createBinFile(t, llm.KV{
+			"general.architecture":           "llama",
+			"llama.block_count":              uint32(1),
+			"llama.context_length":           uint32(8192),
+			"llama.embedding_length":         uint32(4096),
+			"llama.attention.head_count":     uint32(32),
+			"llama.attention.head_count_kv":  uint32(8),
+			"tokenizer.ggml.tokens":          []string{"", "<PRE>", "<SUF>", "<MID>"},
+			"tokenizer.ggml.scores":          []float32{0, 0, 0, 0},
+			"tokenizer.ggml.token_type":      []int32{0, 3, 3, 3},
+			"tokenizer.ggml.prefix_token_id": uint32(1),
+			"tokenizer.ggml.suffix_token_id": uint32(2),
+			"tokenizer.ggml.middle_token_id": uint32(3),
+		}, []llm.Tensor{
+			{Name: "token_embd.weight", Shape: []uint64{1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+			{Name: "output.weight", Shape: []uint64{1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+		})
+
+		w := createRequest(t, s.CreateHandler, api.CreateRequest{
+			Model:    "test-fim",
+			Files:    map[string]string{"file.gguf": digest},
+			Template: `{{ .Prompt }}`,
+			Stream:   &stream,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		w = createRequest(t, s.GenerateHandler, api.GenerateRequest{
+			Model:  "test-fim",
+			Prompt: "def add(",
+			Suffix: "    return c",
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if diff := cmp.Diff(mock.CompletionRequest.Prompt, "<PRE>def add(<SUF>    return c<MID>"); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+
+		resp, err := GetModelInfo(api.ShowRequest{Model: "test-fim"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(resp.Capabilities, []string{"completion", "insert"}); diff != "" {
+			t.Errorf("capabilities mismatch (-got +want):\n%s", diff)
+		}
+	})
+
 	t.Run("raw", func(t *testing.T) {
 		w := createRequest(t, s.GenerateHandler, api.GenerateRequest{
 			Model:  "test-system",
@@ -958,4 +1490,62 @@ func TestGenerate(t *testing.T) {
 			t.Errorf("mismatch (-got +want):\n%s", diff)
 		}
 	})
+
+	t.Run("dry run", func(t *testing.T) {
+		mock.CompletionRequest = llm.CompletionRequest{}
+
+		w := createRequest(t, s.GenerateHandler, api.GenerateRequest{
+			Model:  "test-system",
+			Prompt: "Help me write tests.",
+			DryRun: true,
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if mock.CompletionRequest.Prompt != "" {
+			t.Errorf("expected no completion for a dry run, got %q", mock.CompletionRequest.Prompt)
+		}
+
+		var resp api.GenerateResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if !resp.Done || resp.DoneReason != "dry_run" || resp.Response != "" {
+			t.Errorf("expected a done dry run response, got %+v", resp)
+		}
+
+		want := &api.PromptInfo{
+			Prompt:        "System: You are a helpful assistant. User: Help me write tests. ",
+			Tokens:        11,
+			ContextLength: 2048,
+		}
+		if diff := cmp.Diff(resp.PromptInfo, want); diff != "" {
+			t.Errorf("mismatch (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid adapter", func(t *testing.T) {
+		before := loads.Load()
+		for _, adapter := range []string{"does-not-exist", "test-system"} {
+			w := createRequest(t, s.GenerateHandler, api.GenerateRequest{
+				Model:    "test",
+				Prompt:   "Hello!",
+				Adapters: []api.Adapter{{Model: adapter}},
+				// a different context size would need the model reloaded
+				Options: map[string]any{"num_ctx": 1024},
+				Stream:  &stream,
+			})
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", adapter, w.Code)
+			}
+		}
+
+		if after := loads.Load(); after != before {
+			t.Errorf("expected no model loads for invalid adapters, got %d", after-before)
+		}
+	})
 }
@@ -18,6 +18,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/ollama/ollama/api"
@@ -718,3 +719,33 @@ func TestNormalize(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestTimeout(t *testing.T) {
+	cases := []struct {
+		name     string
+		timeout  *api.Duration
+		envDef   string
+		envMax   string
+		expected time.Duration
+	}{
+		{name: "none", expected: 0},
+		{name: "request", timeout: &api.Duration{Duration: 30 * time.Second}, expected: 30 * time.Second},
+		{name: "default", envDef: "1m", expected: time.Minute},
+		{name: "zero uses default", timeout: &api.Duration{}, envDef: "1m", expected: time.Minute},
+		{name: "request overrides default", timeout: &api.Duration{Duration: 30 * time.Second}, envDef: "1m", expected: 30 * time.Second},
+		{name: "infinite request", timeout: &api.Duration{Duration: math.MaxInt64}, envDef: "1m", expected: 0},
+		{name: "max limits request", timeout: &api.Duration{Duration: 30 * time.Second}, envMax: "10s", expected: 10 * time.Second},
+		{name: "max limits infinite", timeout: &api.Duration{Duration: math.MaxInt64}, envMax: "10s", expected: 10 * time.Second},
+		{name: "max limits default", envDef: "1m", envMax: "10s", expected: 10 * time.Second},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OLLAMA_REQUEST_TIMEOUT", tt.envDef)
+			t.Setenv("OLLAMA_MAX_REQUEST_TIMEOUT", tt.envMax)
+			if got := requestTimeout(tt.timeout); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
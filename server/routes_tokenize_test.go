@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/llm"
+)
+
+// mockVocab splits text on whitespace and numbers the words
+type mockVocab struct {
+	closed bool
+}
+
+func (*mockVocab) Tokenize(_ context.Context, s string) (tokens []int, err error) {
+	for range strings.Fields(s) {
+		tokens = append(tokens, len(tokens))
+	}
+
+	return
+}
+
+func (*mockVocab) Detokenize(_ context.Context, tokens []int) (string, error) {
+	var words []string
+	for _, token := range tokens {
+		if token < 0 || token > 9 {
+			return "", fmt.Errorf("%w: %d", errTokenOutOfRange, token)
+		}
+		words = append(words, fmt.Sprintf("t%d", token))
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+func (v *mockVocab) Close() error {
+	v.closed = true
+	return nil
+}
+
+func TestTokenize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var loaded []string
+	s := Server{
+		vocabs: &vocabCache{
+			size: 1,
+			loadFn: func(path string) (tokenizer, error) {
+				loaded = append(loaded, path)
+				return &mockVocab{}, nil
+			},
+		},
+	}
+
+	_, digest := createBinFile(t, llm.KV{
+		"general.architecture":          "llama",
+		"llama.block_count":             uint32(1),
+		"llama.context_length":          uint32(8192),
+		"llama.embedding_length":        uint32(4096),
+		"llama.attention.head_count":    uint32(32),
+		"llama.attention.head_count_kv": uint32(8),
+		"tokenizer.ggml.tokens":         []string{""},
+		"tokenizer.ggml.scores":         []float32{0},
+		"tokenizer.ggml.token_type":     []int32{0},
+	}, []llm.Tensor{
+		{Name: "token_embd.weight", Shape: []uint64{1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+		{Name: "output.weight", Shape: []uint64{1}, WriterTo: bytes.NewReader(make([]byte, 4))},
+	})
+
+	w := createRequest(t, s.CreateHandler, api.CreateRequest{
+		Model:    "test",
+		Files:    map[string]string{"file.gguf": digest},
+		Template: "{{ range .Messages }}{{ .Role }}: {{ .Content }} {{ end }}",
+		System:   "be brief",
+		Stream:   &stream,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	t.Run("missing body", func(t *testing.T) {
+		w := createRequest(t, s.TokenizeHandler, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("missing model", func(t *testing.T) {
+		w := createRequest(t, s.TokenizeHandler, api.TokenizeRequest{Content: "hello"})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("model not found", func(t *testing.T) {
+		w := createRequest(t, s.TokenizeHandler, api.TokenizeRequest{Model: "missing", Content: "hello"})
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("content and messages", func(t *testing.T) {
+		w := createRequest(t, s.TokenizeHandler, api.TokenizeRequest{Model: "test", Content: "hello", Messages: []api.Message{{Role: "user", Content: "hello"}}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	tokenize := func(t *testing.T, req api.TokenizeRequest, expect []int) {
+		t.Helper()
+
+		w := createRequest(t, s.TokenizeHandler, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.TokenizeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(api.TokenizeResponse{Model: "test", Tokens: expect}, resp); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	t.Run("tokenize", func(t *testing.T) {
+		tokenize(t, api.TokenizeRequest{Model: "test", Content: "why is the sky blue?"}, []int{0, 1, 2, 3, 4})
+		tokenize(t, api.TokenizeRequest{Model: "test"}, []int{})
+	})
+
+	t.Run("messages", func(t *testing.T) {
+		// "system: be brief user: hello there"
+		tokenize(t, api.TokenizeRequest{Model: "test", Messages: []api.Message{{Role: "user", Content: "hello there"}}}, []int{0, 1, 2, 3, 4, 5})
+
+		// "system: no user: hi"
+		tokenize(t, api.TokenizeRequest{Model: "test", Messages: []api.Message{{Role: "system", Content: "no"}, {Role: "user", Content: "hi"}}}, []int{0, 1, 2, 3})
+	})
+
+	t.Run("detokenize", func(t *testing.T) {
+		w := createRequest(t, s.DetokenizeHandler, api.DetokenizeRequest{Model: "test", Tokens: []int{0, 1, 2}})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.DetokenizeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(api.DetokenizeResponse{Model: "test", Content: "t0 t1 t2"}, resp); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("detokenize out of range", func(t *testing.T) {
+		w := createRequest(t, s.DetokenizeHandler, api.DetokenizeRequest{Model: "test", Tokens: []int{0, 100}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("detokenize model not found", func(t *testing.T) {
+		w := createRequest(t, s.DetokenizeHandler, api.DetokenizeRequest{Model: "missing", Tokens: []int{0}})
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	// the vocabulary is only loaded once
+	if len(loaded) != 1 {
+		t.Errorf("expected the vocabulary to be loaded once, got %d", len(loaded))
+	}
+}
+
+func TestVocabCache(t *testing.T) {
+	vocabs := map[string]*mockVocab{}
+	c := &vocabCache{
+		size: 2,
+		loadFn: func(path string) (tokenizer, error) {
+			if path == "bad" {
+				return nil, errors.New("unable to load model")
+			}
+
+			vocabs[path] = &mockVocab{}
+			return vocabs[path], nil
+		},
+	}
+
+	use := func(path string) error {
+		return c.with(path, func(tokenizer) error { return nil })
+	}
+
+	for _, path := range []string{"a", "b", "a", "c"} {
+		if err := use(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b is the least recently used
+	if !vocabs["b"].closed || vocabs["a"].closed || vocabs["c"].closed {
+		t.Errorf("expected only b to be evicted")
+	}
+
+	var paths []string
+	for _, e := range c.entries {
+		paths = append(paths, e.path)
+	}
+
+	if diff := cmp.Diff([]string{"a", "c"}, paths); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := use("bad"); err == nil {
+		t.Error("expected an error")
+	}
+
+	if len(c.entries) != 2 {
+		t.Errorf("expected a failed load to leave the cache unchanged, got %d entries", len(c.entries))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -41,6 +42,10 @@ type Scheduler struct {
 	loaded   map[string]*runnerRef
 	loadedMu sync.Mutex
 
+	// requests waiting to be scheduled, in the order they were made
+	queue   []*LlmRequest
+	queueMu sync.Mutex
+
 	loadFn       func(req *LlmRequest, ggml *llm.GGML, gpus discover.GpuInfoList, numParallel int)
 	newServerFn  func(gpus discover.GpuInfoList, model string, ggml *llm.GGML, adapters []string, projectors []string, opts api.Options, numParallel int) (llm.LlamaServer, error)
 	getGpuFn     func() discover.GpuInfoList
@@ -79,6 +84,13 @@ func InitScheduler(ctx context.Context) *Scheduler {
 
 // context must be canceled to decrement ref count and release the runner
 func (s *Scheduler) GetRunner(c context.Context, model *Model, opts api.Options, sessionDuration *api.Duration) (chan *runnerRef, chan error) {
+	req := s.queueRequest(c, model, opts, sessionDuration)
+	return req.successCh, req.errCh
+}
+
+// queueRequest queues a request for a runner, which is sent to the request's
+// successCh once it's ready
+func (s *Scheduler) queueRequest(c context.Context, model *Model, opts api.Options, sessionDuration *api.Duration) *LlmRequest {
 	if opts.NumCtx < 4 {
 		opts.NumCtx = 4
 	}
@@ -88,16 +100,70 @@ func (s *Scheduler) GetRunner(c context.Context, model *Model, opts api.Options,
 		model:           model,
 		opts:            opts,
 		sessionDuration: sessionDuration,
-		successCh:       make(chan *runnerRef),
+		successCh:       make(chan *runnerRef, 1),
 		errCh:           make(chan error, 1),
 	}
 
+	s.enqueue(req)
 	select {
 	case s.pendingReqCh <- req:
 	default:
+		s.dequeue(req)
 		req.errCh <- ErrMaxQueue
 	}
-	return req.successCh, req.errCh
+	return req
+}
+
+// enqueue adds req to the end of the queue of requests waiting to be scheduled
+func (s *Scheduler) enqueue(req *LlmRequest) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.queue = append(s.queue, req)
+}
+
+// dequeue removes req from the queue once it's being scheduled
+func (s *Scheduler) dequeue(req *LlmRequest) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.queue = slices.DeleteFunc(s.queue, func(r *LlmRequest) bool { return r == req })
+}
+
+// requestStatus returns the progress of req while it waits for a runner. It's
+// queued while it's waiting to be scheduled or for a runner which is already
+// loaded, and loading only while its runner loads. Requests which were
+// canceled while queued don't count towards the position of the requests
+// behind them.
+func (s *Scheduler) requestStatus(req *LlmRequest) api.Status {
+	s.queueMu.Lock()
+	var position int
+	for _, r := range s.queue {
+		if r.ctx.Err() != nil {
+			continue
+		}
+
+		position++
+		if r == req {
+			s.queueMu.Unlock()
+			return api.Status{Status: api.StatusQueued, Position: position}
+		}
+	}
+	s.queueMu.Unlock()
+
+	s.loadedMu.Lock()
+	defer s.loadedMu.Unlock()
+	runner, ok := s.loaded[req.model.ModelPath]
+	if ok && runner.llama != nil && !runner.loading {
+		// the model is already loaded, so req is waiting for the runner to
+		// be free rather than for it to load
+		return api.Status{Status: api.StatusQueued}
+	}
+
+	status := api.Status{Status: api.StatusLoading, Total: 100}
+	if ok && runner.llama != nil {
+		status.Completed = int(runner.llama.LoadProgress() * 100)
+	}
+
+	return status
 }
 
 // Returns immediately, spawns go routines for the scheduler which will shutdown when ctx is done
@@ -119,6 +185,8 @@ func (s *Scheduler) processPending(ctx context.Context) {
 			slog.Debug("shutting down scheduler pending loop")
 			return
 		case pending := <-s.pendingReqCh:
+			s.dequeue(pending)
+
 			// Block other requests until we get this pending request running
 			pending.schedAttempts++
 			if pending.origNumCtx == 0 {
@@ -264,6 +332,7 @@ func (s *Scheduler) processPending(ctx context.Context) {
 								// Process in a go routine to avoid deadlocking
 								// the scheduler if our queue is full
 								slog.Debug("delaying scheduling while other models finish loading", "attempts", pending.schedAttempts, "model", pending.model.ModelPath)
+								s.enqueue(pending)
 								time.Sleep(s.reschedDelay)
 								s.pendingReqCh <- pending
 							}()
@@ -598,8 +667,8 @@ func (runner *runnerRef) needsReload(ctx context.Context, req *LlmRequest) bool
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	if !reflect.DeepEqual(runner.model.AdapterPaths, req.model.AdapterPaths) || // have the adapters changed?
-		!reflect.DeepEqual(runner.model.ProjectorPaths, req.model.ProjectorPaths) || // have the projectors changed?
+	// adapters are selected per request by the runner so they don't require a reload
+	if !reflect.DeepEqual(runner.model.ProjectorPaths, req.model.ProjectorPaths) || // have the projectors changed?
 		!reflect.DeepEqual(optsExisting, optsNew) || // have the runner options changed?
 		runner.llama.Ping(ctx) != nil {
 		return true
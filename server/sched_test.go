@@ -238,7 +238,7 @@ func TestRequestsSimpleReloadSameModel(t *testing.T) {
 
 	// Trigger a reload
 	s.newServerFn = b.newServer
-	b.req.model.AdapterPaths = []string{"new"}
+	b.req.model.ProjectorPaths = []string{"new"}
 	slog.Info("b")
 	s.pendingReqCh <- b.req
 	// finish first two requests, so model can reload
@@ -638,10 +638,11 @@ func TestNeedsReload(t *testing.T) {
 	}
 	resp := runner.needsReload(ctx, req)
 	require.True(t, resp)
-	req.model.AdapterPaths = runner.model.AdapterPaths
-	resp = runner.needsReload(ctx, req)
-	require.True(t, resp)
 	req.model.ProjectorPaths = runner.model.ProjectorPaths
+	// adapters are switched per request without reloading
+	resp = runner.needsReload(ctx, req)
+	require.False(t, resp)
+	req.model.AdapterPaths = runner.model.AdapterPaths
 	runner.loading = true
 	req.opts.NumBatch = 1234
 	resp = runner.needsReload(ctx, req)
@@ -764,6 +765,7 @@ type mockLlm struct {
 	estimatedVRAM      uint64
 	estimatedTotal     uint64
 	estimatedVRAMByGPU map[string]uint64
+	loadProgress       float32
 }
 
 func (s *mockLlm) Ping(ctx context.Context) error             { return s.pingResp }
@@ -772,7 +774,7 @@ func (s *mockLlm) Completion(ctx context.Context, req llm.CompletionRequest, fn
 	return s.completionResp
 }
 
-func (s *mockLlm) Embedding(ctx context.Context, input string) ([]float32, error) {
+func (s *mockLlm) Embedding(ctx context.Context, input string, adapters []llm.LoraAdapter) ([]float32, error) {
 	return s.embeddingResp, s.embeddingRespErr
 }
 
@@ -791,3 +793,49 @@ func (s *mockLlm) Close() error {
 func (s *mockLlm) EstimatedVRAM() uint64                  { return s.estimatedVRAM }
 func (s *mockLlm) EstimatedTotal() uint64                 { return s.estimatedTotal }
 func (s *mockLlm) EstimatedVRAMByGPU(gpuid string) uint64 { return s.estimatedVRAMByGPU[gpuid] }
+func (s *mockLlm) LoadProgress() float32                  { return s.loadProgress }
+
+func TestRequestStatus(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer done()
+
+	a := newScenarioRequest(t, ctx, "ollama-model-1a", 10, nil)
+	b := newScenarioRequest(t, ctx, "ollama-model-1b", 10, nil)
+	c := newScenarioRequest(t, ctx, "ollama-model-1c", 10, nil)
+	s := InitScheduler(ctx)
+
+	reqA := s.queueRequest(a.ctx, a.req.model, a.req.opts, a.req.sessionDuration)
+	reqB := s.queueRequest(b.ctx, b.req.model, b.req.opts, b.req.sessionDuration)
+	reqC := s.queueRequest(c.ctx, c.req.model, c.req.opts, c.req.sessionDuration)
+	require.Equal(t, api.Status{Status: api.StatusQueued, Position: 1}, s.requestStatus(reqA))
+	require.Equal(t, api.Status{Status: api.StatusQueued, Position: 2}, s.requestStatus(reqB))
+	require.Equal(t, api.Status{Status: api.StatusQueued, Position: 3}, s.requestStatus(reqC))
+
+	// canceled requests no longer hold a place in the queue
+	a.ctxDone()
+	require.Equal(t, api.Status{Status: api.StatusQueued, Position: 1}, s.requestStatus(reqB))
+	require.Equal(t, api.Status{Status: api.StatusQueued, Position: 2}, s.requestStatus(reqC))
+
+	// scheduled before its runner starts loading
+	s.dequeue(reqB)
+	require.Equal(t, api.Status{Status: api.StatusLoading, Total: 100}, s.requestStatus(reqB))
+	require.Equal(t, api.Status{Status: api.StatusQueued, Position: 1}, s.requestStatus(reqC))
+
+	// its runner is loading
+	b.srv.loadProgress = 0.5
+	runner := &runnerRef{llama: b.srv, loading: true}
+	s.loadedMu.Lock()
+	s.loaded[b.req.model.ModelPath] = runner
+	s.loadedMu.Unlock()
+	require.Equal(t, api.Status{Status: api.StatusLoading, Completed: 50, Total: 100}, s.requestStatus(reqB))
+
+	// its runner is loaded, but busy with other requests
+	b.srv.loadProgress = 1
+	s.loadedMu.Lock()
+	runner.loading = false
+	s.loadedMu.Unlock()
+	require.Equal(t, api.Status{Status: api.StatusQueued}, s.requestStatus(reqB))
+
+	b.ctxDone()
+	c.ctxDone()
+}
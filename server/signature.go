@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/auth"
+	"github.com/ollama/ollama/envconfig"
+)
+
+const mediaTypeSignature = "application/vnd.ollama.image.signature"
+
+var (
+	errUnsignedModel    = errors.New("model is not signed")
+	errUntrustedSigner  = errors.New("model is signed by an untrusted key")
+	errInvalidSignature = errors.New("model signature does not match manifest")
+)
+
+// manifestDigest returns the hex encoded sha256 of a manifest's bytes exactly
+// as they are sent to and received from the registry. Signatures are stored
+// under this digest.
+func manifestDigest(manifest []byte) string {
+	sum := sha256.Sum256(manifest)
+	return hex.EncodeToString(sum[:])
+}
+
+// signedMessage returns what a manifest's signature is made over: the
+// repository the model is pushed to followed by the manifest's bytes, so the
+// signature can't be attached to the same manifest under another name
+func signedMessage(mp ModelPath, manifest []byte) []byte {
+	return fmt.Appendf(nil, "%s\n%s", strings.ToLower(mp.Registry+"/"+mp.GetNamespaceRepository()), manifest)
+}
+
+// signatureTag is the tag in the model's repository that the signature for the
+// manifest with the given digest is pushed to
+func signatureTag(digest string) string {
+	return fmt.Sprintf("sha256-%s.sig", digest)
+}
+
+func GetSignaturesPath() (string, error) {
+	path := filepath.Join(envconfig.Models(), "signatures")
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// GetSignaturePath returns the path of the local copy of the signature for the
+// manifest with the given digest
+func GetSignaturePath(digest string) (string, error) {
+	path, err := GetSignaturesPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(path, "sha256-"+digest), nil
+}
+
+// signManifest signs the manifest, as it was pushed, with the local key and
+// pushes the signature to the registry next to the manifest
+func signManifest(ctx context.Context, mp ModelPath, manifest []byte, regOpts *registryOptions, fn func(api.ProgressResponse)) error {
+	fn(api.ProgressResponse{Status: "signing manifest"})
+
+	digest := manifestDigest(manifest)
+	signature, err := auth.Sign(ctx, signedMessage(mp, manifest))
+	if err != nil {
+		return err
+	}
+
+	fp, err := GetSignaturePath(digest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(fp, []byte(signature), 0o644); err != nil {
+		return err
+	}
+
+	layer, err := NewLayer(strings.NewReader(signature), mediaTypeSignature)
+	if err != nil {
+		return err
+	}
+	defer removeSignatureBlob(layer.Digest)
+
+	if err := uploadBlob(ctx, mp, layer, regOpts, fn); err != nil {
+		return err
+	}
+
+	fn(api.ProgressResponse{Status: "pushing signature"})
+
+	manifestJSON, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.docker.distribution.manifest.v2+json",
+		Config:        layer,
+	})
+	if err != nil {
+		return err
+	}
+
+	requestURL := mp.BaseURL().JoinPath("v2", mp.GetNamespaceRepository(), "manifests", signatureTag(digest))
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+	resp, err := makeRequestWithRetry(ctx, http.MethodPut, requestURL, headers, bytes.NewReader(manifestJSON), regOpts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// signatureRequired reports whether models pulled from registry must be signed
+func signatureRequired(registry string) bool {
+	return slices.ContainsFunc(envconfig.RequireSignatures(), func(s string) bool {
+		return s == "*" || strings.EqualFold(s, registry)
+	})
+}
+
+// verifyManifest fetches the signature for the manifest, as it was pulled,
+// from the registry and checks that it was made over the manifest and the
+// model's name by a trusted key. The signature is kept next to the local
+// manifests once verified.
+func verifyManifest(ctx context.Context, mp ModelPath, manifest []byte, regOpts *registryOptions) error {
+	digest := manifestDigest(manifest)
+
+	sigmp := mp
+	sigmp.Tag = signatureTag(digest)
+
+	sigManifest, _, err := pullModelManifest(ctx, sigmp, regOpts)
+	if errors.Is(err, os.ErrNotExist) {
+		return errUnsignedModel
+	} else if err != nil {
+		return err
+	}
+
+	if sigManifest.Config.MediaType != mediaTypeSignature {
+		return errUnsignedModel
+	}
+
+	if _, err := downloadBlob(ctx, downloadOpts{
+		mp:      mp,
+		digest:  sigManifest.Config.Digest,
+		regOpts: regOpts,
+		fn:      func(api.ProgressResponse) {},
+	}); err != nil {
+		return err
+	}
+	defer removeSignatureBlob(sigManifest.Config.Digest)
+
+	if err := verifyBlob(sigManifest.Config.Digest); err != nil {
+		return err
+	}
+
+	blob, err := GetBlobsPath(sigManifest.Config.Digest)
+	if err != nil {
+		return err
+	}
+
+	signature, err := os.ReadFile(blob)
+	if err != nil {
+		return err
+	}
+
+	signer, err := auth.Verify(signedMessage(mp, manifest), string(signature))
+	if err != nil {
+		slog.Debug("signature verification failed", "digest", digest, "error", err)
+		return errInvalidSignature
+	}
+
+	trusted, err := trustedKeys()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(trusted, signer) {
+		return fmt.Errorf("%w: %s", errUntrustedSigner, signer)
+	}
+
+	fp, err := GetSignaturePath(digest)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fp, signature, 0o644)
+}
+
+// trustedKeys reads the public keys that are trusted to sign models
+func trustedKeys() ([]string, error) {
+	f, err := os.Open(envconfig.TrustedKeys())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", envconfig.TrustedKeys(), err)
+		}
+
+		keys = append(keys, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))))
+	}
+
+	return keys, scanner.Err()
+}
+
+// removeSignatureBlob removes a signature blob once it has been transferred.
+// Signatures are not referenced by any model so they would otherwise be pruned
+// on the next start.
+func removeSignatureBlob(digest string) {
+	fp, err := GetBlobsPath(digest)
+	if err != nil {
+		return
+	}
+
+	if err := os.Remove(fp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Info(fmt.Sprintf("couldn't remove signature blob '%s': %v", fp, err))
+	}
+}
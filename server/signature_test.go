@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/ollama/ollama/auth"
+)
+
+// fakeRegistry serves manifests and blobs from memory. Blob requests are
+// redirected to a different host name, the same way the registry does.
+type fakeRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.Contains(r.URL.Path, "/manifests/"):
+		bts, ok := f.manifests[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write(bts) //nolint:errcheck
+	case strings.Contains(r.URL.Path, "/blobs/"):
+		digest := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		bts, ok := f.blobs[digest]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", fmt.Sprint(len(bts)))
+			return
+		}
+
+		if r.Host != "localhost" && !strings.HasPrefix(r.Host, "localhost:") {
+			u := url.URL{Scheme: "http", Host: strings.Replace(r.Host, "127.0.0.1", "localhost", 1), Path: r.URL.Path}
+			http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+			return
+		}
+
+		http.ServeContent(w, r, digest, time.Time{}, bytes.NewReader(bts))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeRegistry) addSignature(t *testing.T, manifest []byte, signature string) {
+	t.Helper()
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(signature)))
+	f.blobs[digest] = []byte(signature)
+
+	bts, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		Config:        Layer{MediaType: mediaTypeSignature, Digest: digest, Size: int64(len(signature))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.manifests[signatureTag(manifestDigest(manifest))] = bts
+}
+
+// newSigningKey writes a new ed25519 key to the location auth uses and
+// returns the public key in authorized_keys format
+func newSigningKey(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(home, ".ollama"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".ollama", "id_ed25519"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := auth.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return publicKey
+}
+
+func TestVerifyManifest(t *testing.T) {
+	t.Setenv("OLLAMA_MODELS", t.TempDir())
+
+	trustedKeys := filepath.Join(t.TempDir(), "trusted_keys")
+	t.Setenv("OLLAMA_TRUSTED_KEYS", trustedKeys)
+
+	registry := &fakeRegistry{}
+	srv := httptest.NewServer(registry)
+	t.Cleanup(srv.Close)
+
+	mp := ParseModelPath("http://" + strings.TrimPrefix(srv.URL, "http://") + "/library/test:latest")
+
+	other := mp
+	other.Repository = "other"
+
+	// fields the manifest type doesn't have are signed too
+	m := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json",` +
+		`"layers":[{"mediaType":"application/vnd.ollama.image.model","digest":"sha256:` + strings.Repeat("a", 64) + `","size":1}],` +
+		`"annotations":{"org.opencontainers.image.title":"test"}}`)
+
+	sign := func(t *testing.T, mp ModelPath, manifest []byte) string {
+		t.Helper()
+
+		signature, err := auth.Sign(context.TODO(), signedMessage(mp, manifest))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signature
+	}
+
+	newSigningKey(t)
+	untrustedSignature := sign(t, mp, m)
+
+	trusted := newSigningKey(t)
+	signature := sign(t, mp, m)
+	otherSignature := sign(t, other, m)
+
+	if err := os.WriteFile(trustedKeys, []byte("# comment\n"+trusted+" test\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Replace(m, []byte(strings.Repeat("a", 64)), []byte(strings.Repeat("b", 64)), 1)
+
+	cases := []struct {
+		name      string
+		signature string
+		signed    []byte
+		manifest  []byte
+		err       error
+	}{
+		{name: "unsigned", manifest: m, err: errUnsignedModel},
+		{name: "trusted", signature: signature, signed: m, manifest: m},
+		{name: "untrusted", signature: untrustedSignature, signed: m, manifest: m, err: errUntrustedSigner},
+		{name: "other model", signature: otherSignature, signed: m, manifest: m, err: errInvalidSignature},
+		{name: "tampered", signature: signature, signed: tampered, manifest: tampered, err: errInvalidSignature},
+		{name: "tampered unsigned", signature: signature, signed: m, manifest: tampered, err: errUnsignedModel},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			registry.manifests = make(map[string][]byte)
+			registry.blobs = make(map[string][]byte)
+			if tt.signature != "" {
+				registry.addSignature(t, tt.signed, tt.signature)
+			}
+
+			err := verifyManifest(context.TODO(), mp, tt.manifest, &registryOptions{Insecure: true})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if tt.err == nil {
+				fp, err := GetSignaturePath(manifestDigest(tt.manifest))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if bts, err := os.ReadFile(fp); err != nil || string(bts) != tt.signature {
+					t.Errorf("expected signature to be stored, got %q %v", bts, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureRequired(t *testing.T) {
+	cases := []struct {
+		env      string
+		registry string
+		expect   bool
+	}{
+		{env: "", registry: "registry.ollama.ai", expect: false},
+		{env: "*", registry: "registry.ollama.ai", expect: true},
+		{env: "example.com, registry.ollama.ai", registry: "registry.ollama.ai", expect: true},
+		{env: "example.com", registry: "registry.ollama.ai", expect: false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("OLLAMA_REQUIRE_SIGNATURES", tt.env)
+			if actual := signatureRequired(tt.registry); actual != tt.expect {
+				t.Errorf("expected %v, got %v", tt.expect, actual)
+			}
+		})
+	}
+}
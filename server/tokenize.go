@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/llama"
+	"github.com/ollama/ollama/template"
+)
+
+// maxVocabs is the number of model vocabularies kept loaded for tokenizing
+const maxVocabs = 4
+
+var errTokenOutOfRange = errors.New("token is out of range")
+
+// tokenizer splits text into a model's tokens and joins them back
+type tokenizer interface {
+	Tokenize(context.Context, string) ([]int, error)
+	Detokenize(context.Context, []int) (string, error)
+}
+
+// vocabOnly tokenizes with a model loaded without its weights
+type vocabOnly struct {
+	model *llama.Model
+}
+
+func loadVocabOnly(path string) (tokenizer, error) {
+	m, err := llama.LoadModelFromFile(path, llama.ModelParams{VocabOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &vocabOnly{model: m}, nil
+}
+
+func (v *vocabOnly) Tokenize(_ context.Context, s string) ([]int, error) {
+	return v.model.Tokenize(s, false, true)
+}
+
+func (v *vocabOnly) Detokenize(_ context.Context, tokens []int) (string, error) {
+	n := v.model.NumVocab()
+
+	var sb strings.Builder
+	for _, token := range tokens {
+		if token < 0 || token >= n {
+			return "", fmt.Errorf("%w: %d is not between 0 and %d", errTokenOutOfRange, token, n-1)
+		}
+		sb.WriteString(v.model.TokenToPiece(token))
+	}
+
+	return sb.String(), nil
+}
+
+func (v *vocabOnly) Close() error {
+	llama.FreeModel(v.model)
+	return nil
+}
+
+// vocabCache keeps the vocabularies of the most recently tokenized models
+// loaded. Tokenizing doesn't need the model's weights so it never waits for,
+// or takes, a runner.
+type vocabCache struct {
+	mu   sync.Mutex
+	size int
+
+	// entries are ordered from least to most recently used
+	entries []vocabEntry
+
+	loadFn func(path string) (tokenizer, error)
+}
+
+type vocabEntry struct {
+	path      string
+	tokenizer tokenizer
+}
+
+func newVocabCache(size int) *vocabCache {
+	return &vocabCache{size: size, loadFn: loadVocabOnly}
+}
+
+// with calls fn with the tokenizer of the model at path, loading its
+// vocabulary if it isn't already. The tokenizer is only valid until fn returns.
+func (c *vocabCache) with(path string, fn func(tokenizer) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := slices.IndexFunc(c.entries, func(e vocabEntry) bool { return e.path == path })
+	if i < 0 {
+		t, err := c.loadFn(path)
+		if err != nil {
+			return err
+		}
+
+		for len(c.entries) >= max(c.size, 1) {
+			if closer, ok := c.entries[0].tokenizer.(io.Closer); ok {
+				closer.Close()
+			}
+			c.entries = c.entries[1:]
+		}
+
+		c.entries = append(c.entries, vocabEntry{path: path, tokenizer: t})
+	} else {
+		e := c.entries[i]
+		c.entries = append(slices.Delete(c.entries, i, i+1), e)
+	}
+
+	return fn(c.entries[len(c.entries)-1].tokenizer)
+}
+
+// tokenizer returns a tokenizer for the model at path which loads its
+// vocabulary through the cache on each use
+func (c *vocabCache) tokenizer(path string) tokenizer {
+	return cachedVocab{cache: c, path: path}
+}
+
+type cachedVocab struct {
+	cache *vocabCache
+	path  string
+}
+
+func (v cachedVocab) Tokenize(ctx context.Context, s string) (tokens []int, err error) {
+	err = v.cache.with(v.path, func(t tokenizer) error {
+		tokens, err = t.Tokenize(ctx, s)
+		return err
+	})
+	return tokens, err
+}
+
+func (v cachedVocab) Detokenize(ctx context.Context, tokens []int) (s string, err error) {
+	err = v.cache.with(v.path, func(t tokenizer) error {
+		s, err = t.Detokenize(ctx, tokens)
+		return err
+	})
+	return s, err
+}
+
+func (s *Server) TokenizeHandler(c *gin.Context) {
+	var req api.TokenizeRequest
+	if err := c.ShouldBindJSON(&req); errors.Is(err, io.EOF) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Content != "" && len(req.Messages) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "only one of content or messages can be set"})
+		return
+	}
+
+	m, err := tokenizerModel(req.Model)
+	if err != nil {
+		handleScheduleError(c, req.Model, err)
+		return
+	}
+
+	content := req.Content
+	if len(req.Messages) > 0 {
+		msgs := slices.Concat(m.Messages, req.Messages)
+		if req.Messages[0].Role != "system" && m.System != "" {
+			msgs = append([]api.Message{{Role: "system", Content: m.System}}, msgs...)
+		}
+
+		var b bytes.Buffer
+		if err := m.Template.Execute(&b, template.Values{Messages: msgs, Tools: req.Tools}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		content = b.String()
+	}
+
+	tokens, err := s.vocabs.tokenizer(m.ModelPath).Tokenize(c.Request.Context(), content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tokens == nil {
+		tokens = []int{}
+	}
+
+	c.JSON(http.StatusOK, api.TokenizeResponse{Model: req.Model, Tokens: tokens})
+}
+
+func (s *Server) DetokenizeHandler(c *gin.Context) {
+	var req api.DetokenizeRequest
+	if err := c.ShouldBindJSON(&req); errors.Is(err, io.EOF) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing request body"})
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m, err := tokenizerModel(req.Model)
+	if err != nil {
+		handleScheduleError(c, req.Model, err)
+		return
+	}
+
+	content, err := s.vocabs.tokenizer(m.ModelPath).Detokenize(c.Request.Context(), req.Tokens)
+	if errors.Is(err, errTokenOutOfRange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.DetokenizeResponse{Model: req.Model, Content: content})
+}
+
+// tokenizerModel returns the model whose tokenizer /api/tokenize and
+// /api/detokenize use. The model isn't loaded.
+func tokenizerModel(name string) (*Model, error) {
+	if name == "" {
+		return nil, fmt.Errorf("model %w", errRequired)
+	}
+
+	return GetModel(name)
+}